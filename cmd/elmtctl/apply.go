@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	"github.com/opsdata/elmt-sdk/tools/apply"
+)
+
+func runApply(ctx context.Context, args []string) error {
+	var files listFlag
+
+	fs, o := newFlagSet("apply")
+	fs.Var(&files, "f", "manifest files or directories, repeated or comma separated, - for the standard input")
+	prune := fs.Bool("prune", false, "delete the objects applied before which are no longer in the manifests")
+	dryRun := fs.Bool("dry-run", false, "only print the changes")
+	showDiff := fs.Bool("diff", false, "print the changed fields")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 || len(files) == 0 {
+		return usageErrorf("apply only takes manifest files, given with -f")
+	}
+
+	objects, err := apply.Load(files...)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	applier := apply.New(clientset.Elmt().APIV1(), apply.Options{DryRun: *dryRun, Prune: *prune})
+
+	changes, applyErr := applier.Apply(ctx, objects)
+
+	// a dry run always shows the diff, it is what it is run for
+	if err := apply.PrintChanges(o.out, changes, *showDiff || *dryRun); err != nil {
+		return err
+	}
+
+	return applyErr
+}
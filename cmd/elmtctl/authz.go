@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/tools/manifest"
+	"github.com/opsdata/elmt-sdk/tools/printers"
+)
+
+// contextFlag collects the KEY=VALUE entries of a ladon context, KEY:=VALUE giving a JSON value.
+type contextFlag ladon.Context
+
+func (c contextFlag) String() string {
+	data, _ := json.Marshal(c)
+
+	return string(data)
+}
+
+func (c contextFlag) Set(entry string) error {
+	if i := strings.Index(entry, ":="); i > 0 {
+		var value interface{}
+		if err := json.Unmarshal([]byte(entry[i+2:]), &value); err != nil {
+			return fmt.Errorf("invalid JSON value for %s: %v", entry[:i], err)
+		}
+
+		c[entry[:i]] = value
+
+		return nil
+	}
+
+	i := strings.Index(entry, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q is not of the form KEY=VALUE or KEY:=JSON", entry)
+	}
+
+	c[entry[:i]] = entry[i+1:]
+
+	return nil
+}
+
+func runAuthz(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return usageErrorf("usage: elmtctl authz check [FLAGS]")
+	}
+
+	var files listFlag
+
+	requestContext := contextFlag{}
+
+	fs, o := newFlagSet("authz check")
+	subject := fs.String("subject", "", "subject of the request")
+	action := fs.String("action", "", "action of the request")
+	resource := fs.String("resource", "", "resource of the request")
+	fs.Var(requestContext, "context", "context entry of the request, KEY=VALUE or KEY:=JSON, repeated")
+	fs.Var(&files, "f", "files of requests, each document being a request or an array of requests")
+
+	args, err := parse(fs, o, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return usageErrorf("authz check takes no argument")
+	}
+
+	var requests []*ladon.Request
+
+	if len(files) > 0 {
+		if requests, err = loadRequests(files); err != nil {
+			return err
+		}
+	}
+
+	if len(*subject) > 0 || len(*action) > 0 || len(*resource) > 0 || len(requestContext) > 0 {
+		requests = append(requests, &ladon.Request{
+			Subject:  *subject,
+			Action:   *action,
+			Resource: *resource,
+			Context:  ladon.Context(requestContext),
+		})
+	}
+
+	if len(requests) == 0 {
+		return usageErrorf("a request is required, given with -subject, -action and -resource, or -f")
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	authz := clientset.Elmt().AuthzV1().Authz()
+
+	var responses []*authzv1.Response
+
+	if len(requests) == 1 {
+		response, err := authz.Authorize(ctx, requests[0], metav1.AuthorizeOptions{})
+		if err != nil {
+			return err
+		}
+
+		responses = []*authzv1.Response{response}
+	} else if responses, err = authz.AuthorizeBatch(ctx, requests, metav1.AuthorizeOptions{}); err != nil {
+		return err
+	}
+
+	results := make([]printers.AuthzDecision, len(requests))
+	denied := false
+
+	for i := range requests {
+		results[i] = printers.AuthzDecision{Request: requests[i], Response: responses[i]}
+		denied = denied || !responses[i].Allowed
+	}
+
+	if err := o.print(results); err != nil {
+		return err
+	}
+
+	if denied {
+		return errDenied
+	}
+
+	return nil
+}
+
+// loadRequests reads the ladon requests of files.
+func loadRequests(files []string) ([]*ladon.Request, error) {
+	docs, err := manifest.ReadFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []*ladon.Request
+
+	for _, doc := range docs {
+		if strings.HasPrefix(strings.TrimSpace(string(doc.Data)), "[") {
+			var items []*ladon.Request
+			if err := json.Unmarshal(doc.Data, &items); err != nil {
+				return nil, fmt.Errorf("%s: %v", doc, err)
+			}
+
+			requests = append(requests, items...)
+
+			continue
+		}
+
+		request := &ladon.Request{}
+		if err := json.Unmarshal(doc.Data, request); err != nil {
+			return nil, fmt.Errorf("%s: %v", doc, err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/tools/printers"
+)
+
+// describe writes the fields of the objects of a list returned by getObjects, one object after the other.
+func describe(w io.Writer, list interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(tw, "%s:\t%v\n", name, value)
+	}
+
+	switch l := list.(type) {
+	case *v1.UserList:
+		for i, u := range l.Items {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+
+			field("Name", u.Name)
+			field("Instance ID", u.InstanceID)
+			field("UID", u.UID)
+			field("Admin", yesNo(u.IsAdmin == 1))
+			field("Activated", yesNo(u.Activated == 1))
+			field("Policies", u.TotalPolicy)
+			field("Logined At", formatTime(u.LoginedAt))
+			field("Created", formatTime(u.CreatedAt))
+			field("Updated", formatTime(u.UpdatedAt))
+		}
+	case *v1.SecretList:
+		for i, s := range l.Items {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+
+			field("Name", s.Name)
+			field("Instance ID", s.InstanceID)
+			field("Username", s.Username)
+			field("Secret ID", s.SecretID)
+			field("Expires", printers.FormatExpires(s.Expires))
+			field("Description", s.Description)
+			field("Created", formatTime(s.CreatedAt))
+			field("Updated", formatTime(s.UpdatedAt))
+		}
+	case *v1.PolicyList:
+		for i, p := range l.Items {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+
+			conditions, _ := json.Marshal(p.Policy.Conditions)
+
+			field("Name", p.Name)
+			field("Instance ID", p.InstanceID)
+			field("Username", p.Username)
+			field("Description", p.Policy.Description)
+			field("Effect", p.Policy.Effect)
+			field("Subjects", strings.Join(p.Policy.Subjects, ", "))
+			field("Actions", strings.Join(p.Policy.Actions, ", "))
+			field("Resources", strings.Join(p.Policy.Resources, ", "))
+			field("Conditions", string(conditions))
+			field("Created", formatTime(p.CreatedAt))
+			field("Updated", formatTime(p.UpdatedAt))
+		}
+	}
+
+	return tw.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// formatTime formats a time for describe, - standing for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return printers.FormatTime(t)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/tools/clientcmd"
+	"github.com/opsdata/elmt-sdk/tools/login"
+)
+
+func runLogin(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("login")
+	username := fs.String("username", "", "user to log in as, the one of the elmtconfig by default")
+	secretID := fs.String("secret-id", "", "secret ID to log in with instead of a username, asking for the secret key")
+	secretStdin := fs.Bool("password-stdin", false, "read the password, or the secret key, from the standard input")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return usageErrorf("login takes no argument")
+	}
+
+	if len(*username) > 0 && len(*secretID) > 0 {
+		return usageErrorf("-username and -secret-id cannot be both given")
+	}
+
+	elmtconfig, err := o.loadElmtconfig()
+	if err != nil {
+		return err
+	}
+
+	creds, err := loginCredentials(elmtconfig.AuthInfo, *username, *secretID, *secretStdin)
+	if err != nil {
+		return err
+	}
+
+	// the server is reached with the settings of the elmtconfig, without its credentials
+	server := *elmtconfig
+	server.AuthInfo = &clientcmd.AuthInfo{}
+
+	config, err := clientcmd.NewClientConfigFromConfig(&server).ClientConfig()
+	if err != nil {
+		return err
+	}
+
+	token, err := login.Login(ctx, config, creds)
+	if err != nil {
+		return err
+	}
+
+	// the token replaces the credentials, the username is kept to ask for the password again
+	elmtconfig.AuthInfo.Username = creds.Username
+	elmtconfig.AuthInfo.Password = ""
+	elmtconfig.AuthInfo.SecretID = ""
+	elmtconfig.AuthInfo.SecretKey = ""
+	elmtconfig.AuthInfo.Token = token.AccessToken
+	elmtconfig.AuthInfo.TokenExpiry = token.Expiry
+
+	if err := clientcmd.WriteToFile(elmtconfig, o.elmtconfig); err != nil {
+		return err
+	}
+
+	who := creds.Username
+	if len(who) == 0 {
+		who = "secret " + creds.SecretID
+	}
+
+	if token.Expiry.IsZero() {
+		fmt.Fprintf(o.out, "Logged in as %s, the token never expires\n", who)
+	} else {
+		fmt.Fprintf(o.out, "Logged in as %s, the token expires at %s\n", who, token.Expiry.Local().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// loginCredentials returns the credentials given on the command line, or else the ones of the
+// elmtconfig, asking for the password or the secret key when it is not known.
+func loginCredentials(authInfo *clientcmd.AuthInfo, username, secretID string,
+	secretStdin bool) (login.Credentials, error) {
+	var creds login.Credentials
+
+	switch {
+	case len(username) > 0:
+		creds.Username = username
+	case len(secretID) > 0:
+		creds.SecretID = secretID
+	case len(authInfo.SecretID) > 0:
+		creds.SecretID, creds.SecretKey = authInfo.SecretID, authInfo.SecretKey
+	case len(authInfo.Username) > 0:
+		creds.Username, creds.Password = authInfo.Username, authInfo.Password
+	default:
+		name, err := prompt("Username", false)
+		if err != nil {
+			return creds, err
+		}
+
+		creds.Username = name
+	}
+
+	label, secret := "Password", &creds.Password
+	if len(creds.SecretID) > 0 {
+		label, secret = "Secret key", &creds.SecretKey
+	}
+
+	if len(*secret) > 0 {
+		return creds, nil
+	}
+
+	var err error
+
+	if secretStdin {
+		*secret, err = readLine(os.Stdin)
+	} else {
+		*secret, err = prompt(label, true)
+	}
+
+	if err == nil && len(*secret) == 0 {
+		err = fmt.Errorf("the %s is empty", strings.ToLower(label))
+	}
+
+	return creds, err
+}
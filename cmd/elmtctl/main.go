@@ -0,0 +1,163 @@
+// Command elmtctl works with the resources of an ELMT server from the command line.
+//
+// Usage:
+//
+//	elmtctl list users|secrets|policies [-offset N] [-limit N]
+//	elmtctl get users|secrets|policies [NAME...]
+//	elmtctl describe users|secrets|policies NAME...
+//	elmtctl create -f FILE...
+//	elmtctl update -f FILE...
+//	elmtctl delete users|secrets|policies NAME...
+//	elmtctl apply -f FILE... [-prune] [-dry-run] [-diff]
+//	elmtctl authz check -subject S -action A -resource R [-context KEY=VALUE...]
+//	elmtctl zbx item [NAME...] [-host H] [-group G] [-tag T] [-key PATTERN] [-offset N] [-limit N]
+//	elmtctl zbx host [NAME] [-group G] [-tag T] [-offset N] [-limit N]
+//	elmtctl zbx history ITEMID [-since DURATION] [-limit N]
+//	elmtctl login [-username NAME | -secret-id ID] [-password-stdin]
+//	elmtctl proxy [-address HOST:PORT] [-allow-paths PREFIX...] [-allow-methods METHOD...]
+//
+// Every command accepts -elmtconfig, -server, -no-headers, -show-secrets and
+// -o table|wide|json|yaml|jsonpath=TEMPLATE|template=TEMPLATE, before or after its arguments.
+// The passwords and the secret keys are redacted unless -show-secrets is given.
+//
+// Exit codes:
+//
+//	0 the command succeeded
+//	1 the command failed, such as when the server returned an error
+//	2 the command line is invalid
+//	3 authz check denied at least one request
+//
+// The named Zabbix items and hosts are looked up on the ELMT server, the listings and the history
+// are read from the Zabbix API configured in the zabbix section of the elmtconfig.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes of elmtctl.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+	exitDenied = 3
+)
+
+// errDenied is returned by authz check when a request is denied.
+var errDenied = errors.New("denied")
+
+// usageError is an invalid command line.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "list", usage: "list the users, secrets or policies", run: runList},
+		{name: "get", usage: "get users, secrets or policies by name, or list them", run: runGet},
+		{name: "describe", usage: "show the details of users, secrets or policies", run: runDescribe},
+		{name: "create", usage: "create the objects of manifest files", run: runCreate},
+		{name: "update", usage: "update the objects of manifest files", run: runUpdate},
+		{name: "delete", usage: "delete users, secrets or policies by name", run: runDelete},
+		{name: "apply", usage: "create, update and prune the objects of manifest files", run: runApply},
+		{name: "authz", usage: "check authorization requests", run: runAuthz},
+		{name: "zbx", usage: "look up Zabbix items and hosts, and the history of an item", run: runZbx},
+		{name: "login", usage: "log in and store a short-lived token in the elmtconfig", run: runLogin},
+		{name: "proxy", usage: "serve the ELMT API locally, with the credentials of the elmtconfig", run: runProxy},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stderr)
+
+		if len(args) == 0 {
+			return exitUsage
+		}
+
+		return exitOK
+	}
+
+	var cmd *command
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+
+	if cmd == nil {
+		fmt.Fprintf(stderr, "error: unknown command %q\n\n", args[0])
+		printUsage(stderr)
+
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return exitCode(cmd.run(ctx, args[1:]), stderr)
+}
+
+// exitCode reports the error of a command and returns the matching exit code.
+func exitCode(err error, stderr io.Writer) int {
+	var usageErr *usageError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errDenied):
+		return exitDenied
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	fmt.Fprintf(stderr, "error: %v\n", err)
+
+	return exitFailed
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "elmtctl works with the resources of an ELMT server.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: elmtctl COMMAND [ARGS] [FLAGS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.usage)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "elmtctl COMMAND -h" for the flags of a command.`)
+}
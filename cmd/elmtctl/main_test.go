@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/tools/clientcmd"
+)
+
+// newELMTServer stands in for the ELMT server: it has the users alice and bob, and allows the
+// authorization requests whose action is get.
+func newELMTServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	users := map[string]*v1.User{
+		"alice": {ObjectMeta: metav1.ObjectMeta{Name: "alice", InstanceID: "user-a"}, UID: 1, Password: "alice-hash",
+			IsAdmin: 1},
+		"bob": {ObjectMeta: metav1.ObjectMeta{Name: "bob", InstanceID: "user-b"}, UID: 2, Password: "bob-hash"},
+	}
+
+	decide := func(request *ladon.Request) *authzv1.Response {
+		if request.Action == "get" {
+			return &authzv1.Response{Allowed: true}
+		}
+
+		return &authzv1.Response{Denied: true, Reason: "no policy allows " + request.Action}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := func(status int, v interface{}) {
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(v)
+		}
+
+		switch path := r.URL.Path; {
+		case strings.HasSuffix(path, "/users"):
+			reply(http.StatusOK, &v1.UserList{ListMeta: metav1.ListMeta{TotalCount: 2},
+				Items: []*v1.User{users["alice"], users["bob"]}})
+		case strings.Contains(path, "/users/"):
+			if user, ok := users[path[strings.LastIndex(path, "/")+1:]]; ok {
+				reply(http.StatusOK, user)
+				return
+			}
+
+			reply(http.StatusNotFound, map[string]interface{}{"code": 110001, "message": "User was not found"})
+		case strings.HasSuffix(path, "/authz/batch"):
+			var body struct {
+				Requests []*ladon.Request `json:"requests"`
+			}
+
+			_ = json.NewDecoder(r.Body).Decode(&body)
+
+			responses := make([]*authzv1.Response, len(body.Requests))
+			for i, request := range body.Requests {
+				responses[i] = decide(request)
+			}
+
+			reply(http.StatusOK, map[string]interface{}{"responses": responses})
+		case strings.HasSuffix(path, "/authz"):
+			var request ladon.Request
+
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			reply(http.StatusOK, decide(&request))
+		default:
+			reply(http.StatusNotFound, map[string]interface{}{"message": "not found"})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func writeTestFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// runTest runs elmtctl with args, and returns its exit code and what it printed.
+func runTest(t *testing.T, args ...string) (code int, out, errOut string) {
+	t.Helper()
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	saved := stdout
+	stdout = &stdoutBuf
+
+	defer func() { stdout = saved }()
+
+	code = run(args, &stderrBuf)
+
+	return code, stdoutBuf.String(), stderrBuf.String()
+}
+
+func TestRun(t *testing.T) {
+	server := newELMTServer(t)
+	dir := t.TempDir()
+
+	config := writeTestFile(t, dir, "elmtconfig", "server:\n  address: "+server.URL+"\n")
+	unreachable := writeTestFile(t, dir, "unreachable", "server:\n  address: http://127.0.0.1:1\n")
+	requests := writeTestFile(t, dir, "requests.yaml",
+		"- {subject: 'users:alice', action: get, resource: 'hosts:1'}\n"+
+			"- {subject: 'users:alice', action: delete, resource: 'hosts:1'}\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+		notStdout  []string
+		wantStderr string
+	}{
+		{
+			name:       "no command",
+			wantCode:   exitUsage,
+			wantStderr: "Usage: elmtctl COMMAND",
+		},
+		{
+			name:       "help",
+			args:       []string{"help"},
+			wantCode:   exitOK,
+			wantStderr: "Commands:",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"frobnicate"},
+			wantCode:   exitUsage,
+			wantStderr: `error: unknown command "frobnicate"`,
+		},
+		{
+			name:       "list",
+			args:       []string{"list", "users", "-elmtconfig", config},
+			wantCode:   exitOK,
+			wantStdout: []string{"NAME", "alice", "bob"},
+			notStdout:  []string{"alice-hash", "INSTANCE ID"},
+		},
+		{
+			name:       "list wide without headers",
+			args:       []string{"list", "users", "-o", "wide", "-no-headers", "-elmtconfig", config},
+			wantCode:   exitOK,
+			wantStdout: []string{"user-a", "user-b"},
+			notStdout:  []string{"NAME"},
+		},
+		{
+			name:       "flags before the arguments",
+			args:       []string{"list", "-elmtconfig", config, "-o", "json", "users"},
+			wantCode:   exitOK,
+			wantStdout: []string{`"name": "alice"`},
+			notStdout:  []string{"alice-hash"},
+		},
+		{
+			name:       "secrets shown",
+			args:       []string{"get", "users", "alice", "-o", "json", "-show-secrets", "-elmtconfig", config},
+			wantCode:   exitOK,
+			wantStdout: []string{"alice-hash"},
+		},
+		{
+			name:       "jsonpath",
+			args:       []string{"get", "users", "bob", "-o", "jsonpath={.uid}", "-elmtconfig", config},
+			wantCode:   exitOK,
+			wantStdout: []string{"2"},
+		},
+		{
+			name:       "server flag",
+			args:       []string{"get", "users", "alice", "-elmtconfig", unreachable, "-server", server.URL},
+			wantCode:   exitOK,
+			wantStdout: []string{"alice"},
+		},
+		{
+			name:       "not found",
+			args:       []string{"get", "users", "carol", "-elmtconfig", config},
+			wantCode:   exitFailed,
+			wantStderr: "error: ",
+		},
+		{
+			name:       "unknown resource",
+			args:       []string{"list", "widgets", "-elmtconfig", config},
+			wantCode:   exitUsage,
+			wantStderr: `unknown resource "widgets"`,
+		},
+		{
+			name:       "unknown output",
+			args:       []string{"list", "users", "-o", "xml", "-elmtconfig", config},
+			wantCode:   exitUsage,
+			wantStderr: "error: ",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"list", "users", "-limits", "1"},
+			wantCode:   exitUsage,
+			wantStderr: "flag provided but not defined: -limits",
+		},
+		{
+			name:       "missing elmtconfig",
+			args:       []string{"list", "users", "-elmtconfig", filepath.Join(dir, "missing")},
+			wantCode:   exitFailed,
+			wantStderr: "no such file or directory",
+		},
+		{
+			name: "authz allowed",
+			args: []string{"authz", "check", "-subject", "users:alice", "-action", "get", "-resource", "hosts:1",
+				"-elmtconfig", config},
+			wantCode: exitOK,
+		},
+		{
+			name: "authz denied",
+			args: []string{"authz", "check", "-subject", "users:alice", "-action", "delete", "-resource", "hosts:1",
+				"-elmtconfig", config},
+			wantCode: exitDenied,
+		},
+		{
+			name:       "authz batch",
+			args:       []string{"authz", "check", "-f", requests, "-o", "json", "-elmtconfig", config},
+			wantCode:   exitDenied,
+			wantStdout: []string{`"allowed": true`, "no policy allows delete"},
+		},
+		{
+			name:       "authz without request",
+			args:       []string{"authz", "check", "-elmtconfig", config},
+			wantCode:   exitUsage,
+			wantStderr: "a request is required",
+		},
+		{
+			name:       "proxy argument",
+			args:       []string{"proxy", "users", "-elmtconfig", config},
+			wantCode:   exitUsage,
+			wantStderr: "proxy takes no argument",
+		},
+		{
+			name:       "proxy relative path",
+			args:       []string{"proxy", "-allow-paths", "/v1/users,v1/secrets", "-elmtconfig", config},
+			wantCode:   exitFailed,
+			wantStderr: `allowed path "v1/secrets" must start with /`,
+		},
+		{
+			name:       "proxy invalid address",
+			args:       []string{"proxy", "-address", "127.0.0.1:http-alt-bogus", "-elmtconfig", config},
+			wantCode:   exitFailed,
+			wantStderr: "error: ",
+		},
+		{
+			name:       "authz invalid context",
+			args:       []string{"authz", "check", "-context", "ip", "-elmtconfig", config},
+			wantCode:   exitUsage,
+			wantStderr: "is not of the form KEY=VALUE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, errOut := runTest(t, tt.args...)
+
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.wantCode, out, errOut)
+			}
+
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(out, want) {
+					t.Errorf("stdout = %s, want it to contain %q", out, want)
+				}
+			}
+
+			for _, notWant := range tt.notStdout {
+				if strings.Contains(out, notWant) {
+					t.Errorf("stdout = %s, want it without %q", out, notWant)
+				}
+			}
+
+			if !strings.Contains(errOut, tt.wantStderr) {
+				t.Errorf("stderr = %s, want it to contain %q", errOut, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestContextFlag(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    interface{}
+		wantErr bool
+	}{
+		{entry: "ip=10.0.0.1", want: "10.0.0.1"},
+		{entry: "ip=a=b", want: "a=b"},
+		{entry: "ip:=5", want: float64(5)},
+		{entry: `ip:=["a"]`, want: []interface{}{"a"}},
+		{entry: "ip:=nope", wantErr: true},
+		{entry: "=value", wantErr: true},
+		{entry: "ip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c := contextFlag{}
+
+		err := c.Set(tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, want error %v", tt.entry, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil {
+			got, _ := json.Marshal(c["ip"])
+			want, _ := json.Marshal(tt.want)
+
+			if string(got) != string(want) {
+				t.Errorf("Set(%q) = %s, want %s", tt.entry, got, want)
+			}
+		}
+	}
+}
+
+// testToken returns an unsigned JWT expiring in lifetime.
+func testToken(name string, lifetime time.Duration) string {
+	payload := fmt.Sprintf(`{"sub":%q,"exp":%d}`, name, time.Now().Add(lifetime).Unix())
+
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+/*
+ * loginServer:
+ * - stand in for the ELMT server issuing tokens: alice logs in with the password passw0rd, for a
+ * token expiring in a minute, which is refreshed for a token expiring in an hour
+ * - the users are listed with a valid token, the Authorization header of the last request is kept
+ */
+
+type loginServer struct {
+	*httptest.Server
+
+	mu                sync.Mutex
+	issued            map[string]bool
+	lastAuthorization string
+}
+
+func newLoginServer(t *testing.T) *loginServer {
+	t.Helper()
+
+	s := &loginServer{issued: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *loginServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	authorization := r.Header.Get("Authorization")
+	s.lastAuthorization = authorization
+	bearer := strings.TrimPrefix(authorization, "Bearer ")
+
+	var token string
+
+	switch r.URL.Path {
+	case "/login":
+		if user, password, ok := r.BasicAuth(); ok && user == "alice" && password == "passw0rd" {
+			token = testToken("alice", time.Minute)
+		}
+	case "/refresh":
+		if s.issued[bearer] {
+			token = testToken("alice-refreshed", time.Hour)
+		}
+	default:
+		if s.issued[bearer] && strings.HasSuffix(r.URL.Path, "/users") {
+			_ = json.NewEncoder(w).Encode(&v1.UserList{Items: []*v1.User{{ObjectMeta: metav1.ObjectMeta{Name: "alice"}}}})
+			return
+		}
+	}
+
+	if len(token) == 0 {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":100208,"message":"Authorization failed"}`))
+
+		return
+	}
+
+	s.issued[token] = true
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// withStdin runs fn with input as the standard input.
+func withStdin(t *testing.T, input string, fn func()) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	saved := os.Stdin
+	os.Stdin = r
+
+	defer func() { os.Stdin = saved }()
+
+	fn()
+}
+
+func TestLogin(t *testing.T) {
+	server := newLoginServer(t)
+	elmtconfig := writeTestFile(t, t.TempDir(), "elmtconfig", "server:\n  address: "+server.URL+"\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "wrong password",
+			args:       []string{"login", "-username", "alice", "-password-stdin"},
+			stdin:      "guess\n",
+			wantCode:   exitFailed,
+			wantStderr: "unable to log in",
+		},
+		{
+			name:       "empty password",
+			args:       []string{"login", "-username", "alice", "-password-stdin"},
+			stdin:      "\n",
+			wantCode:   exitFailed,
+			wantStderr: "the password is empty",
+		},
+		{
+			name:       "username and secret",
+			args:       []string{"login", "-username", "alice", "-secret-id", "id"},
+			wantCode:   exitUsage,
+			wantStderr: "-username and -secret-id cannot be both given",
+		},
+		{
+			name:       "logged in",
+			args:       []string{"login", "-username", "alice", "-password-stdin"},
+			stdin:      "passw0rd\n",
+			wantCode:   exitOK,
+			wantStdout: "Logged in as alice, the token expires at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			var out, errOut string
+
+			withStdin(t, tt.stdin, func() {
+				code, out, errOut = runTest(t, append(tt.args, "-elmtconfig", elmtconfig)...)
+			})
+
+			if code != tt.wantCode || !strings.Contains(out, tt.wantStdout) || !strings.Contains(errOut, tt.wantStderr) {
+				t.Errorf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.wantCode, out, errOut)
+			}
+		})
+	}
+
+	loggedIn, err := clientcmd.LoadFromFile(elmtconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth := loggedIn.AuthInfo; auth.Username != "alice" || len(auth.Password) > 0 || len(auth.Token) == 0 ||
+		time.Until(auth.TokenExpiry) > time.Minute {
+		t.Errorf("elmtconfig user = %+v, want alice and the token expiring in a minute", auth)
+	}
+
+	// the token about to expire is refreshed for the command, and stored
+	code, out, errOut := runTest(t, "list", "users", "-elmtconfig", elmtconfig)
+	if code != exitOK || !strings.Contains(out, "alice") {
+		t.Fatalf("list exit code = %d\nstdout: %s\nstderr: %s", code, out, errOut)
+	}
+
+	refreshed, err := clientcmd.LoadFromFile(elmtconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token := refreshed.AuthInfo.Token; token == loggedIn.AuthInfo.Token ||
+		server.lastAuthorization != "Bearer "+token || time.Until(refreshed.AuthInfo.TokenExpiry) < 50*time.Minute {
+		t.Errorf("elmtconfig token = %s expiring at %v, sent %s, want the refreshed one", token,
+			refreshed.AuthInfo.TokenExpiry, server.lastAuthorization)
+	}
+
+	if info, err := os.Stat(elmtconfig); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("elmtconfig mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/opsdata/elmt-sdk/rest"
+	"github.com/opsdata/elmt-sdk/tools/clientcmd"
+	"github.com/opsdata/elmt-sdk/tools/login"
+	"github.com/opsdata/elmt-sdk/tools/printers"
+	"github.com/opsdata/elmt-sdk/wyvern"
+)
+
+// stdout is where the commands print their results.
+var stdout io.Writer = os.Stdout
+
+// options are the flags shared by every command.
+type options struct {
+	elmtconfig  string
+	server      string
+	output      string
+	noHeaders   bool
+	showSecrets bool
+
+	out     io.Writer
+	printer printers.Printer
+}
+
+// newFlagSet returns the flag set of a command, with the shared flags.
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	o := &options{out: stdout}
+
+	fs := flag.NewFlagSet("elmtctl "+name, flag.ContinueOnError)
+	fs.StringVar(&o.elmtconfig, "elmtconfig", clientcmd.RecommendedHomeFile, "path to the elmtconfig file")
+	fs.StringVar(&o.server, "server", "", "address of the ELMT server, overriding the elmtconfig one")
+	fs.StringVar(&o.output, "o", printers.OutputTable,
+		"output format: table, wide, json, yaml, jsonpath=TEMPLATE or template=TEMPLATE")
+	fs.BoolVar(&o.noHeaders, "no-headers", false, "omit the headers of the tables")
+	fs.BoolVar(&o.showSecrets, "show-secrets", false, "print the passwords and the secret keys instead of redacting them")
+
+	return fs, o
+}
+
+// parse parses the flags wherever they are in args, and returns the other arguments.
+func parse(fs *flag.FlagSet, o *options, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+
+			return nil, &usageError{msg: err.Error()}
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	printer, err := printers.NewPrinter(o.output, printers.Options{
+		NoHeaders:     o.noHeaders,
+		ShowSensitive: o.showSecrets,
+	})
+	if err != nil {
+		return nil, &usageError{msg: err.Error()}
+	}
+
+	o.printer = printer
+
+	return positional, nil
+}
+
+// print writes an object with the printer of the -o flag.
+func (o *options) print(obj interface{}) error {
+	return o.printer.PrintObj(obj, o.out)
+}
+
+// restConfig loads the elmtconfig file, overridden by the -server flag. The token of a user
+// logged in is renewed before it expires, and stored in the file again.
+func (o *options) restConfig() (*rest.Config, error) {
+	elmtconfig, err := o.loadElmtconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.NewClientConfigFromConfig(elmtconfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// only the tokens issued by elmtctl login, which expire, are renewed
+	expiry, err := login.ParseExpiry(config.BearerToken)
+	if len(config.BearerToken) == 0 || err != nil || expiry.IsZero() {
+		return config, nil
+	}
+
+	source := login.NewTokenSource(config, &login.Token{AccessToken: config.BearerToken, Expiry: expiry})
+	source.OnToken = o.storeToken
+
+	if username := elmtconfig.AuthInfo.Username; len(username) > 0 && isTerminal(os.Stdin) {
+		source.Prompt = func(ctx context.Context) (login.Credentials, error) {
+			fmt.Fprintf(os.Stderr, "The token of %s has expired.\n", username)
+
+			password, err := prompt("Password", true)
+
+			return login.Credentials{Username: username, Password: password}, err
+		}
+	}
+
+	config.WrapTransport = rest.Wrappers(config.WrapTransport, source.WrapTransport)
+
+	return config, nil
+}
+
+// loadElmtconfig reads the elmtconfig file, and applies the -server flag to it.
+func (o *options) loadElmtconfig() (*clientcmd.Config, error) {
+	elmtconfig, err := clientcmd.LoadFromFile(o.elmtconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.server) > 0 {
+		elmtconfig.Server.Address = o.server
+	}
+
+	return elmtconfig, nil
+}
+
+// storeToken writes a renewed token to the elmtconfig file, a failure only being reported as
+// the command can go on with the token.
+func (o *options) storeToken(token *login.Token) {
+	elmtconfig, err := clientcmd.LoadFromFile(o.elmtconfig)
+	if err == nil {
+		elmtconfig.AuthInfo.Token = token.AccessToken
+		elmtconfig.AuthInfo.TokenExpiry = token.Expiry
+		err = clientcmd.WriteToFile(elmtconfig, o.elmtconfig)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to store the renewed token in %s: %v\n", o.elmtconfig, err)
+	}
+}
+
+// clientset creates a clientset from the elmtconfig file and the -server flag.
+func (o *options) clientset() (*wyvern.Clientset, error) {
+	config, err := o.restConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return wyvern.NewForConfig(config)
+}
+
+// listFlag collects the values of a repeated flag, each value being a comma separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			*l = append(*l, v)
+		}
+	}
+
+	return nil
+}
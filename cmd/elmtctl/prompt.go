@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prompt writes label to the standard error and reads a line of the standard input, without
+// echoing it if secret.
+func prompt(label string, secret bool) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("unable to ask for the %s, the standard input is not a terminal", strings.ToLower(label))
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+
+	if !secret {
+		return readLine(os.Stdin)
+	}
+
+	line, err := readSecret(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+
+	return line, err
+}
+
+// readLine reads a line of r byte by byte, so that nothing after the line is consumed.
+func readLine(r io.Reader) (string, error) {
+	var (
+		line []byte
+		b    [1]byte
+	)
+
+	for {
+		n, err := r.Read(b[:])
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+
+			line = append(line, b[0])
+		}
+
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
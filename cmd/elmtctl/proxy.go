@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/tools/proxy"
+)
+
+// shutdownTimeout is how long the proxy waits for the requests in progress when it is stopped.
+const shutdownTimeout = 5 * time.Second
+
+func runProxy(ctx context.Context, args []string) error {
+	var paths, methods, hosts listFlag
+
+	fs, o := newFlagSet("proxy")
+	address := fs.String("address", proxy.DefaultAddress, "address to listen on, HOST:PORT")
+	fs.Var(&paths, "allow-paths", "path prefixes forwarded, repeated or comma separated, every path if none")
+	fs.Var(&methods, "allow-methods", "HTTP methods forwarded, repeated or comma separated, every method if none")
+	fs.Var(&hosts, "accept-hosts", "regular expressions of the hosts accepted in the Host header, "+
+		"repeated or comma separated, localhost, 127.0.0.1 and [::1] if none")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return usageErrorf("proxy takes no argument")
+	}
+
+	config, err := o.restConfig()
+	if err != nil {
+		return err
+	}
+
+	opts := proxy.Options{
+		AllowedPaths:   paths,
+		AllowedMethods: methods,
+		ErrorLog:       log.New(os.Stderr, "proxy: ", log.LstdFlags),
+	}
+	if len(hosts) > 0 {
+		opts.AcceptHosts = hosts
+	}
+
+	handler, err := proxy.New(config, opts)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: handler, ErrorLog: opts.ErrorLog}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Forwarding http://%s to %s\n", listener.Addr(), config.Host)
+
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	utilerrors "github.com/opsdata/errors"
+
+	"github.com/opsdata/elmt-sdk/tools/apply"
+	"github.com/opsdata/elmt-sdk/tools/printers"
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+)
+
+// resourceKinds maps the resource names accepted on the command line to the kinds of the manifests.
+var resourceKinds = map[string]string{
+	"user":     apply.KindUser,
+	"users":    apply.KindUser,
+	"secret":   apply.KindSecret,
+	"secrets":  apply.KindSecret,
+	"policy":   apply.KindPolicy,
+	"policies": apply.KindPolicy,
+}
+
+func resourceKind(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", usageErrorf("a resource is required: users, secrets or policies")
+	}
+
+	kind, ok := resourceKinds[args[0]]
+	if !ok {
+		return "", usageErrorf("unknown resource %q, it must be users, secrets or policies", args[0])
+	}
+
+	return kind, nil
+}
+
+func runList(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("list")
+	offset := fs.Int64("offset", 0, "number of objects to skip")
+	limit := fs.Int64("limit", 0, "maximum number of objects, 0 for the server default")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	kind, err := resourceKind(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		return usageErrorf("list takes no name, use get")
+	}
+
+	opts := metav1.ListOptions{}
+	if *offset > 0 {
+		opts.Offset = offset
+	}
+
+	if *limit > 0 {
+		opts.Limit = limit
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	list, err := listObjects(ctx, clientset.Elmt().APIV1(), kind, opts)
+	if err != nil {
+		return err
+	}
+
+	return o.print(list)
+}
+
+func runGet(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("get")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	kind, err := resourceKind(args)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	client := clientset.Elmt().APIV1()
+
+	if len(args) == 1 {
+		list, err := listObjects(ctx, client, kind, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		return o.print(list)
+	}
+
+	list, err := getObjects(ctx, client, kind, args[1:])
+	if err != nil {
+		return err
+	}
+
+	// a single object is printed as is, not as a list of one item
+	if len(args) == 2 {
+		return o.print(firstItem(list))
+	}
+
+	return o.print(list)
+}
+
+func runDescribe(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("describe")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	kind, err := resourceKind(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		return usageErrorf("describe requires at least one name")
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	list, err := getObjects(ctx, clientset.Elmt().APIV1(), kind, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if o.output != printers.OutputTable && o.output != printers.OutputWide {
+		return o.print(list)
+	}
+
+	return describe(o.out, list)
+}
+
+func runCreate(ctx context.Context, args []string) error {
+	return runManifestCommand(ctx, "create", args)
+}
+
+func runUpdate(ctx context.Context, args []string) error {
+	return runManifestCommand(ctx, "update", args)
+}
+
+// runManifestCommand creates or updates the objects of manifest files, see apply.Load.
+func runManifestCommand(ctx context.Context, verb string, args []string) error {
+	var files listFlag
+
+	fs, o := newFlagSet(verb)
+	fs.Var(&files, "f", "manifest files or directories, repeated or comma separated, - for the standard input")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 || len(files) == 0 {
+		return usageErrorf("%s only takes manifest files, given with -f", verb)
+	}
+
+	objects, err := apply.Load(files...)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	client := clientset.Elmt().APIV1()
+
+	var errs []error
+
+	for _, object := range objects {
+		if err := writeObject(ctx, client, verb, object); err != nil {
+			errs = append(errs, fmt.Errorf("unable to %s %s: %v", verb, object, err))
+			continue
+		}
+
+		fmt.Fprintf(o.out, "%s %sd\n", object, verb)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func runDelete(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("delete")
+
+	args, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	kind, err := resourceKind(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		return usageErrorf("delete requires at least one name")
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	client := clientset.Elmt().APIV1()
+
+	var errs []error
+
+	for _, name := range args[1:] {
+		if err := deleteObject(ctx, client, kind, name); err != nil {
+			errs = append(errs, fmt.Errorf("unable to delete %s/%s: %v", kind, name, err))
+			continue
+		}
+
+		fmt.Fprintf(o.out, "%s/%s deleted\n", kind, name)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func listObjects(ctx context.Context, client apiv1.APIV1Interface, kind string,
+	opts metav1.ListOptions) (interface{}, error) {
+	switch kind {
+	case apply.KindUser:
+		return client.Users().List(ctx, opts)
+	case apply.KindSecret:
+		return client.Secrets().List(ctx, opts)
+	default:
+		return client.Policies().List(ctx, opts)
+	}
+}
+
+// getObjects gets the named objects, and returns them as a list of their kind.
+func getObjects(ctx context.Context, client apiv1.APIV1Interface, kind string, names []string) (interface{}, error) {
+	switch kind {
+	case apply.KindUser:
+		list := &v1.UserList{}
+
+		for _, name := range names {
+			user, err := client.Users().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get %s/%s: %v", kind, name, err)
+			}
+
+			list.Items = append(list.Items, user)
+		}
+
+		list.TotalCount = int64(len(list.Items))
+
+		return list, nil
+	case apply.KindSecret:
+		list := &v1.SecretList{}
+
+		for _, name := range names {
+			secret, err := client.Secrets().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get %s/%s: %v", kind, name, err)
+			}
+
+			list.Items = append(list.Items, secret)
+		}
+
+		list.TotalCount = int64(len(list.Items))
+
+		return list, nil
+	default:
+		list := &v1.PolicyList{}
+
+		for _, name := range names {
+			policy, err := client.Policies().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get %s/%s: %v", kind, name, err)
+			}
+
+			list.Items = append(list.Items, policy)
+		}
+
+		list.TotalCount = int64(len(list.Items))
+
+		return list, nil
+	}
+}
+
+// firstItem returns the first item of a list returned by getObjects.
+func firstItem(list interface{}) interface{} {
+	switch l := list.(type) {
+	case *v1.UserList:
+		return l.Items[0]
+	case *v1.SecretList:
+		return l.Items[0]
+	case *v1.PolicyList:
+		return l.Items[0]
+	}
+
+	return list
+}
+
+// writeObject creates or updates an object of a manifest.
+func writeObject(ctx context.Context, client apiv1.APIV1Interface, verb string, object *apply.Object) error {
+	data, err := json.Marshal(object.Fields)
+	if err != nil {
+		return err
+	}
+
+	switch object.Kind {
+	case apply.KindUser:
+		user := &v1.User{}
+		if err := json.Unmarshal(data, user); err != nil {
+			return err
+		}
+
+		if verb == "create" {
+			_, err = client.Users().Create(ctx, user, metav1.CreateOptions{})
+		} else {
+			_, err = client.Users().Update(ctx, user, metav1.UpdateOptions{})
+		}
+	case apply.KindSecret:
+		secret := &v1.Secret{}
+		if err := json.Unmarshal(data, secret); err != nil {
+			return err
+		}
+
+		if verb == "create" {
+			_, err = client.Secrets().Create(ctx, secret, metav1.CreateOptions{})
+		} else {
+			_, err = client.Secrets().Update(ctx, secret, metav1.UpdateOptions{})
+		}
+	default:
+		policy := &v1.Policy{}
+		if err := json.Unmarshal(data, policy); err != nil {
+			return err
+		}
+
+		if verb == "create" {
+			_, err = client.Policies().Create(ctx, policy, metav1.CreateOptions{})
+		} else {
+			_, err = client.Policies().Update(ctx, policy, metav1.UpdateOptions{})
+		}
+	}
+
+	return err
+}
+
+func deleteObject(ctx context.Context, client apiv1.APIV1Interface, kind, name string) error {
+	switch kind {
+	case apply.KindUser:
+		return client.Users().Delete(ctx, name, metav1.DeleteOptions{})
+	case apply.KindSecret:
+		return client.Secrets().Delete(ctx, name, metav1.DeleteOptions{})
+	default:
+		return client.Policies().Delete(ctx, name, metav1.DeleteOptions{})
+	}
+}
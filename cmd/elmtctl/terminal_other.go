@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package main
+
+import "os"
+
+// isTerminal tells whether f is a terminal, only known on linux and darwin, where the
+// other files are taken for terminals.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readSecret reads a line of f, the echo of the terminal cannot be turned off on this system.
+func readSecret(f *os.File) (string, error) {
+	return readLine(f)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
+)
+
+func runZbx(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("usage: elmtctl zbx item|host|history [NAME...] [FLAGS]")
+	}
+
+	switch args[0] {
+	case "item", "items":
+		return runZbxItem(ctx, args[1:])
+	case "host", "hosts":
+		return runZbxHost(ctx, args[1:])
+	case "history":
+		return runZbxHistory(ctx, args[1:])
+	}
+
+	return usageErrorf("unknown zbx command %q, it must be item, host or history", args[0])
+}
+
+// runZbxItem gets the indicators of the named items from the ELMT server, or lists the items of
+// the Zabbix API.
+func runZbxItem(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("zbx item")
+	host := fs.String("host", "", "only list the items of this host")
+	group := fs.String("group", "", "only list the items of the hosts of this host group")
+	tag := fs.String("tag", "", "only list the items with this tag, name or name=value")
+	key := fs.String("key", "", "only list the items whose key matches this pattern, * matching any string")
+	offset := fs.Int("offset", 0, "number of items to skip")
+	limit := fs.Int("limit", 0, "maximum number of items, 0 for all of them")
+
+	names, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		items, err := clientset.Zabbix().Items().List(ctx, zabbix.ListOptions{
+			HostName:   *host,
+			HostGroup:  *group,
+			Tag:        *tag,
+			KeyPattern: *key,
+			Offset:     *offset,
+			Limit:      *limit,
+		})
+		if err != nil {
+			return err
+		}
+
+		return o.print(items)
+	}
+
+	cmd := clientset.Elmt().APIV1().ZbxCmd()
+
+	if len(names) == 1 {
+		indicator, err := cmd.GetZbxItem(ctx, names[0], metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		return o.print(indicator)
+	}
+
+	indicators := make([]*v1.Indicator, 0, len(names))
+
+	for _, name := range names {
+		indicator, err := cmd.GetZbxItem(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		indicators = append(indicators, indicator)
+	}
+
+	return o.print(indicators)
+}
+
+// runZbxHost gets a host from the ELMT server, or lists the hosts of the Zabbix API.
+func runZbxHost(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("zbx host")
+	group := fs.String("group", "", "only list the hosts of this host group")
+	tag := fs.String("tag", "", "only list the hosts with this tag, name or name=value")
+	offset := fs.Int("offset", 0, "number of hosts to skip")
+	limit := fs.Int("limit", 0, "maximum number of hosts, 0 for all of them")
+
+	names, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(names) > 1 {
+		return usageErrorf("zbx host takes at most one name")
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 1 {
+		host, err := clientset.Elmt().APIV1().ZbxCmd().GetZbxHost(ctx, names[0], metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		return o.print(host)
+	}
+
+	hosts, err := clientset.Zabbix().Hosts().List(ctx, zabbix.ListOptions{
+		HostGroup: *group,
+		Tag:       *tag,
+		Offset:    *offset,
+		Limit:     *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.print(hosts)
+}
+
+// runZbxHistory prints the values of a Zabbix item.
+func runZbxHistory(ctx context.Context, args []string) error {
+	fs, o := newFlagSet("zbx history")
+	since := fs.Duration("since", time.Hour, "only print the values collected in this last duration, 0 for all of them")
+	limit := fs.Int("limit", 0, "maximum number of values, 0 for all of them")
+
+	ids, err := parse(fs, o, args)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) != 1 {
+		return usageErrorf("zbx history takes the id of a single item")
+	}
+
+	clientset, err := o.clientset()
+	if err != nil {
+		return err
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	history, err := clientset.Zabbix().History().GetItemHistory(ctx, ids[0], from, time.Time{}, *limit)
+	if err != nil {
+		return err
+	}
+
+	return o.print(history)
+}
@@ -0,0 +1,103 @@
+// Command policytest evaluates policy files against test cases, without an ELMT server.
+//
+// Usage:
+//
+//	policytest -policies policies/ -tests policies/tests.yaml [-v] [-o json]
+//
+// The exit code is 0 when every test passed, 1 when a test failed or a policy is invalid,
+// and 2 when the files could not be read.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/opsdata/elmt-sdk/tools/policytest"
+)
+
+// Exit codes of policytest.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+// pathsFlag collects the values of a repeated flag, each value being a comma separated list.
+type pathsFlag []string
+
+func (p *pathsFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathsFlag) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			*p = append(*p, path)
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var policies, tests pathsFlag
+
+	fs := flag.NewFlagSet("policytest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Var(&policies, "policies", "policy files or directories, repeated or comma separated")
+	fs.Var(&tests, "tests", "test case files or directories, repeated or comma separated")
+	verbose := fs.Bool("v", false, "also list the tests which passed")
+	output := fs.String("o", "text", "output format, text or json")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		return exitError
+	}
+
+	if len(policies) == 0 || len(tests) == 0 || (*output != "text" && *output != "json") {
+		fs.Usage()
+		return exitError
+	}
+
+	list, err := policytest.LoadPolicies(policies...)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	suite, err := policytest.LoadSuite(tests...)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	report := policytest.Run(list, suite)
+
+	if *output == "json" {
+		err = report.PrintJSON(stdout)
+	} else {
+		err = report.Print(stdout, *verbose)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	if !report.OK() {
+		return exitFailed
+	}
+
+	return exitOK
+}
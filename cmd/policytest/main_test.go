@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testPolicies = `
+metadata:
+  name: read-hosts
+policy:
+  subjects: ["users:<.+>"]
+  actions: [get, list]
+  resources: ["hosts:<.+>"]
+  effect: allow
+`
+
+	passingTests = `
+tests:
+- name: users read the hosts
+  request: {subject: "users:alice", action: get, resource: "hosts:web-1"}
+  expect: allow
+  policy: read-hosts
+`
+
+	failingTests = `
+tests:
+- name: users write the hosts
+  request: {subject: "users:alice", action: update, resource: "hosts:web-1"}
+  expect: allow
+`
+
+	invalidPolicy = `
+metadata:
+  name: no-effect
+policy:
+  subjects: ["users:<.+>"]
+  actions: [get]
+  resources: ["hosts:<.+>"]
+`
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	policies := write("policies.yaml", testPolicies)
+	invalid := write("invalid.yaml", invalidPolicy)
+	passing := write("passing.yaml", passingTests)
+	failing := write("failing.yaml", failingTests)
+	undecodable := write("undecodable.yaml", "tests: [")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "every test passes",
+			args:       []string{"-policies", policies, "-tests", passing},
+			wantCode:   exitOK,
+			wantStdout: "PASS: 1 tests",
+		},
+		{
+			name:       "verbose",
+			args:       []string{"-policies", policies, "-tests", passing, "-v"},
+			wantCode:   exitOK,
+			wantStdout: "--- PASS: users read the hosts",
+		},
+		{
+			name:       "json",
+			args:       []string{"-o", "json", "-policies", policies, "-tests", passing},
+			wantCode:   exitOK,
+			wantStdout: `"passed": 1`,
+		},
+		{
+			name:       "a test fails",
+			args:       []string{"-policies", policies, "-tests", passing + "," + failing},
+			wantCode:   exitFailed,
+			wantStdout: "FAIL: 1 of 2 tests failed",
+		},
+		{
+			name:       "a policy is invalid",
+			args:       []string{"-policies", policies, "-policies", invalid, "-tests", passing},
+			wantCode:   exitFailed,
+			wantStdout: "--- INVALID: policy no-effect",
+		},
+		{
+			name:       "missing file",
+			args:       []string{"-policies", filepath.Join(dir, "missing.yaml"), "-tests", passing},
+			wantCode:   exitError,
+			wantStderr: "error: ",
+		},
+		{
+			name:       "undecodable tests",
+			args:       []string{"-policies", policies, "-tests", undecodable},
+			wantCode:   exitError,
+			wantStderr: "error: unable to decode",
+		},
+		{
+			name:       "no tests",
+			args:       []string{"-policies", policies},
+			wantCode:   exitError,
+			wantStderr: "Usage of policytest",
+		},
+		{
+			name:       "unknown output",
+			args:       []string{"-policies", policies, "-tests", passing, "-o", "xml"},
+			wantCode:   exitError,
+			wantStderr: "Usage of policytest",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"-policy", policies},
+			wantCode:   exitError,
+			wantStderr: "flag provided but not defined: -policy",
+		},
+		{
+			name:       "help",
+			args:       []string{"-h"},
+			wantCode:   exitOK,
+			wantStderr: "Usage of policytest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if code := run(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run() = %d, want %d\nstdout: %s\nstderr: %s", code, tt.wantCode, stdout.String(),
+					stderr.String())
+			}
+
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("stdout = %s, want it to contain %q", stdout.String(), tt.wantStdout)
+			}
+
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %s, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package rest
 
 import (
+	"encoding/base64"
+	"fmt"
 	"net/url"
 	"strings"
 
+	"github.com/opsdata/common-base/pkg/auth"
 	"github.com/opsdata/common-base/pkg/runtime"
 	"github.com/opsdata/common-base/pkg/scheme"
 
@@ -21,6 +24,7 @@ type Interface interface {
 }
 
 // TLSConfig holds the information needed to set up a TLS transport.
+// Config uses TLSClientConfig instead, whose files are reloaded when ReloadInterval is set.
 type TLSConfig struct {
 	CAFile         string // Path of the PEM-encoded server trusted root certificates.
 	CertFile       string // Path of the PEM-encoded client certificate.
@@ -78,6 +82,46 @@ func (c *ClientContentConfig) HasKeyAuth() bool {
 	return len(c.SecretID) != 0 && len(c.SecretKey) != 0
 }
 
+// AuthorizationHeader
+// - return the Authorization header of the requests sent to the services of an API group, or an
+// empty string without credentials
+// - 只能设置其中一个
+// 1.basic auth
+// 2.bearer token
+// 3.secretID, secretKey, signed into a JWT for the group which expires after a minute, so a new
+// header must be made for every request
+func (c *ClientContentConfig) AuthorizationHeader(group string) (string, error) {
+	authMethod := 0
+	for _, fn := range []func() bool{c.HasBasicAuth, c.HasTokenAuth, c.HasKeyAuth} {
+		if fn() {
+			authMethod++
+		}
+	}
+
+	if authMethod > 1 {
+		return "", fmt.Errorf(
+			"username/password or bearer token or secretID/secretKey may be set, but should use only one of them",
+		)
+	}
+
+	switch {
+	case c.HasBasicAuth():
+		return "Basic " + basicAuth(c.Username, c.Password), nil
+	case c.HasTokenAuth():
+		return fmt.Sprintf("Bearer %s", c.BearerToken), nil
+	case c.HasKeyAuth():
+		tokenString := auth.Sign(c.SecretID, c.SecretKey, "elmt-sdk", group+".elmt")
+		return fmt.Sprintf("Bearer %s", tokenString), nil
+	}
+
+	return "", nil
+}
+
+func basicAuth(username, password string) string {
+	auth := username + ":" + password
+	return base64.StdEncoding.EncodeToString([]byte(auth))
+}
+
 // RESTClient
 // - impose common ELMT API conventions on a set of resource paths
 type RESTClient struct {
@@ -93,6 +137,19 @@ type RESTClient struct {
 	// describe how a RESTClient encodes and decodes responses
 	content ClientContentConfig
 
+	// throttle the requests, nil means no throttling
+	rateLimiter RateLimiter
+
+	// receive the telemetry of every request
+	metrics MetricsProvider
+
+	// start a client span for every request
+	tracer Tracer
+
+	// log the requests up to the given level
+	verbosity int
+	logger    Logger
+
 	Client *gorequest.SuperAgent
 }
 
@@ -118,6 +175,9 @@ func NewRESTClient(baseURL *url.URL, versionedAPIPath string,
 		group:            config.GroupVersion.Group,
 		versionedAPIPath: versionedAPIPath,
 		content:          config,
+		metrics:          NoopMetrics,
+		tracer:           NoopTracer,
+		logger:           defaultLogger,
 		Client:           client,
 	}, nil
 }
@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizationHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content ClientContentConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no credentials",
+		},
+		{
+			name:    "basic auth",
+			content: ClientContentConfig{Username: "alice", Password: "passw0rd"},
+			want:    "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:passw0rd")),
+		},
+		{
+			name:    "bearer token",
+			content: ClientContentConfig{BearerToken: "t0ken"},
+			want:    "Bearer t0ken",
+		},
+		{
+			name:    "secret",
+			content: ClientContentConfig{SecretID: "id", SecretKey: "key"},
+			want:    "Bearer ey",
+		},
+		{
+			name:    "incomplete secret",
+			content: ClientContentConfig{SecretID: "id"},
+		},
+		{
+			name:    "basic auth and bearer token",
+			content: ClientContentConfig{Username: "alice", Password: "passw0rd", BearerToken: "t0ken"},
+			wantErr: true,
+		},
+		{
+			name:    "bearer token and secret",
+			content: ClientContentConfig{BearerToken: "t0ken", SecretID: "id", SecretKey: "key"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.content.AuthorizationHeader("api")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: AuthorizationHeader() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if !strings.HasPrefix(got, tt.want) || (len(tt.want) == 0) != (len(got) == 0) {
+			t.Errorf("%s: AuthorizationHeader() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizationHeaderAudience(t *testing.T) {
+	content := ClientContentConfig{SecretID: "id", SecretKey: "key"}
+
+	for _, group := range []string{"api", "elmt.authz"} {
+		header, err := content.AuthorizationHeader(group)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts := strings.Split(strings.TrimPrefix(header, "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Fatalf("AuthorizationHeader(%q) = %q, want a JWT", group, header)
+		}
+
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(claims), `"aud":"`+group+`.elmt"`) {
+			t.Errorf("claims = %s, want the audience %s.elmt", claims, group)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package rest
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -23,6 +25,8 @@ import (
 // Config holds the common attributes that can be passed to a IAM client on
 // initialization.
 type Config struct {
+	// Host is a host, host:port or URL of the server, or unix:///path/to.sock to reach it through
+	// a unix domain socket.
 	Host    string
 	APIPath string
 	ContentConfig
@@ -42,6 +46,23 @@ type Config struct {
 	// TLSClientConfig contains settings to enable transport layer security
 	TLSClientConfig
 
+	// ProxyURL is the url of the proxy used to reach the server: http, https and socks5 schemes are
+	// supported, optionally with user info. If empty, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored.
+	ProxyURL string
+
+	// Transport may be used for custom HTTP behavior. This attribute may not be specified with the
+	// TLS client certificate options or the proxy url, use WrapTransport to keep them.
+	Transport http.RoundTripper
+
+	// WrapTransport will be invoked for custom HTTP behavior after the underlying transport is
+	// initialized, either from the TLS, proxy and timeout settings or from Transport. It sees every
+	// attempt of a request, after the SDK has set the auth headers and with the retries unrolled.
+	WrapTransport WrapperFunc
+
+	// Dial specifies the dial function for creating unencrypted TCP connections, or the connections
+	// to the unix domain socket of Host, in which case it is called with the "unix" network.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
 	// UserAgent is an optional field that specifies the caller of this request
 	UserAgent string
 
@@ -52,10 +73,60 @@ type Config struct {
 	MaxRetries    int
 	RetryInterval time.Duration
 
-	// JSON-RPC API information for Zabbix
-	ZabbixApiUrl  string
-	ZabbixApiUser string
-	ZabbixApiPass string
+	// Connection pool and transport tuning. Zero values fall back to the DefaultTransport values of
+	// net/http, see configureTransport.
+
+	// DialTimeout is the maximum amount of time a dial waits for a connect to complete.
+	DialTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes of the active connections.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout is the maximum amount of time waiting for a TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// MaxIdleConns controls the maximum number of idle connections across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost controls the maximum idle connections to keep per host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the total number of connections per host, zero means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is the maximum amount of time an idle connection remains idle before closing itself.
+	IdleConnTimeout time.Duration
+	// ResponseHeaderTimeout is the amount of time to wait for the response headers once the request is written.
+	ResponseHeaderTimeout time.Duration
+	// DisableKeepAlives opens a new connection for every request. Connections are kept alive and
+	// reused by default.
+	DisableKeepAlives bool
+	// EnableHTTP2 attempts HTTP/2 over TLS when TLS settings are given, the server may still pick
+	// http/1.1 through NextProtos. Without TLS settings HTTP/2 is attempted as net/http does.
+	EnableHTTP2 bool
+
+	// RateLimiter throttles the requests sent to the server, and the time spent waiting on it is
+	// reported to Metrics. No throttling if nil.
+	RateLimiter RateLimiter
+
+	// Metrics receives the telemetry of every request. Defaults to NoopMetrics.
+	Metrics MetricsProvider
+
+	// Tracer starts a client span for every request and the W3C trace context of that span is
+	// sent to the server. Defaults to NoopTracer, which neither records spans nor injects headers.
+	Tracer Tracer
+
+	// Verbosity sets what the RESTClient logs, from LogLevelOff to LogLevelBodies. Credentials are
+	// always redacted.
+	Verbosity int
+
+	// Logger receives the request logs. Defaults to a logger writing to stderr.
+	Logger Logger
+
+	// JSON-RPC API information for Zabbix, authenticated either by user.login with
+	// ZabbixApiUser and ZabbixApiPass or by the API token ZabbixApiToken
+	ZabbixApiUrl   string
+	ZabbixApiUser  string
+	ZabbixApiPass  string
+	ZabbixApiToken string
+
+	// ZabbixBatchSize is the maximum number of calls sent in a single JSON-RPC batch request,
+	// zabbix.DefaultBatchSize if zero
+	ZabbixBatchSize int
 }
 
 // ContentConfig defines config for content.
@@ -97,6 +168,18 @@ func (c *Config) String() string {
 		cc.SecretKey = "--- REDACTED ---"
 	}
 
+	if cc.ProxyURL != "" {
+		cc.ProxyURL = redactURL(cc.ProxyURL)
+	}
+
+	if cc.ZabbixApiPass != "" {
+		cc.ZabbixApiPass = "--- REDACTED ---"
+	}
+
+	if cc.ZabbixApiToken != "" {
+		cc.ZabbixApiToken = "--- REDACTED ---"
+	}
+
 	return fmt.Sprintf("%#v", cc)
 }
 
@@ -127,13 +210,36 @@ func RESTClientFor(config *Config) (*RESTClient, error) {
 		return nil, err
 	}
 
+	proxy, err := proxyFuncFor(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Only retry when get a server side error
 	client := gorequest.New().TLSClientConfig(tlsConfig).Timeout(config.Timeout).
 		Retry(config.MaxRetries, config.RetryInterval, http.StatusInternalServerError)
+	client.Transport.Proxy = proxy
+	configureTransport(config, client.Transport)
+
+	rt, err := roundTripperFor(config, client.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	// The agent is shared by the requests of the RESTClient: installing the round tripper once keeps
+	// gorequest from setting its transport on the shared http.Client before each request
+	if rt == nil {
+		rt = client.Transport
+	}
+
+	client.SetRoundTripper(rt)
 
 	// NOTICE: must set DoNotClearSuperAgent to true, or the client will clean header befor http.Do
 	client.DoNotClearSuperAgent = true
 
+	// gorequest dumps requests verbatim, credentials included, so logging is left to the RESTClient
+	client.SetDebug(false).SetCurlCommand(false)
+
 	var gv scheme.GroupVersion
 
 	if config.GroupVersion != nil {
@@ -154,7 +260,27 @@ func RESTClientFor(config *Config) (*RESTClient, error) {
 		Negotiator:         config.Negotiator,
 	}
 
-	return NewRESTClient(baseURL, versionedAPIPath, clientContent, client)
+	restClient, err := NewRESTClient(baseURL, versionedAPIPath, clientContent, client)
+	if err != nil {
+		return nil, err
+	}
+
+	restClient.rateLimiter = config.RateLimiter
+
+	if config.Metrics != nil {
+		restClient.metrics = config.Metrics
+	}
+
+	if config.Tracer != nil {
+		restClient.tracer = config.Tracer
+	}
+
+	restClient.verbosity = config.Verbosity
+	if config.Logger != nil {
+		restClient.logger = config.Logger
+	}
+
+	return restClient, nil
 }
 
 // TLSConfigFor
@@ -162,7 +288,7 @@ func RESTClientFor(config *Config) (*RESTClient, error) {
 // by the provided Config
 // - it will return nil if no transport level security is requested
 func TLSConfigFor(c *Config) (*tls.Config, error) {
-	if !(c.HasCA() || c.HasCertAuth() || c.Insecure || len(c.ServerName) > 0) {
+	if !c.hasTLSOptions() {
 		return nil, nil
 	}
 
@@ -170,37 +296,82 @@ func TLSConfigFor(c *Config) (*tls.Config, error) {
 		return nil, fmt.Errorf("specifying a root certificates file with the insecure flag is not allowed")
 	}
 
+	// Only files can be reloaded, data always takes precedence over them
+	reloadCert := c.ReloadInterval > 0 && len(c.CertData) == 0 && len(c.KeyData) == 0 &&
+		len(c.CertFile) > 0 && len(c.KeyFile) > 0
+	if len(c.PKCS12Data) > 0 || len(c.PKCS12File) > 0 {
+		reloadCert = c.ReloadInterval > 0 && len(c.PKCS12Data) == 0
+	}
+
+	reloadCA := c.ReloadInterval > 0 && len(c.CAData) == 0 && len(c.CAFile) > 0
+
+	// Can't use SSLv3 because of POODLE and BEAST
+	// Can't use TLSv1.0 because of POODLE and BEAST using CBC cipher
+	// Can't use TLSv1.1 because of RC4 cipher usage
+	if c.MinVersion != 0 && c.MinVersion < tls.VersionTLS12 {
+		return nil, fmt.Errorf("TLS versions older than 1.2 are not allowed")
+	}
+
+	pins, err := parsePublicKeyPins(c.PinnedPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// LoadTLSFiles replaces the data fields by the decoded data and the content of the files, which
+	// is done on a copy so that the config of the caller keeps its files and can be used again
+	cc := *c
+	c = &cc
+
 	if err := LoadTLSFiles(c); err != nil {
 		return nil, err
 	}
 
 	tlsConfig := &tls.Config{
-		// Can't use SSLv3 because of POODLE and BEAST
-		// Can't use TLSv1.0 because of POODLE and BEAST using CBC cipher
-		// Can't use TLSv1.1 because of RC4 cipher usage
 		MinVersion: tls.VersionTLS12,
 		//nolint: gosec
 		InsecureSkipVerify: c.Insecure,
 		ServerName:         c.ServerName,
 		NextProtos:         c.NextProtos,
+		CipherSuites:       c.CipherSuites,
+	}
+
+	if c.MinVersion != 0 {
+		tlsConfig.MinVersion = c.MinVersion
 	}
 
 	if c.HasCA() {
-		tlsConfig.RootCAs = rootCertPool(c.CAData)
+		tlsConfig.RootCAs, err = rootCertPool(c.CAData, c.AppendSystemRoots)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load root certificates: %v", err)
+		}
 	}
 
-	var staticCert *tls.Certificate
+	if pins != nil {
+		tlsConfig.VerifyConnection = pins.verifyConnection
+	}
+
+	var (
+		staticCert *tls.Certificate
+		passphrase []byte
+	)
 
 	// Treat cert as static if either key or cert was data, not a file
 	if c.HasCertAuth() {
+		passphrase, err = keyPassphraseFor(&c.TLSClientConfig)
+		if err != nil {
+			return nil, err
+		}
+
 		// If key/cert were provided, verify them before setting up
 		// tlsConfig.GetClientCertificate.
-		cert, err := tls.X509KeyPair(c.CertData, c.KeyData)
+		staticCert, err = clientCertificate(c.CertData, c.KeyData, c.PKCS12Data, passphrase)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		staticCert = &cert
+	if reloadCert || reloadCA {
+		return withTLSFileReloader(c, tlsConfig, staticCert, passphrase, pins, reloadCert, reloadCA)
 	}
 
 	if c.HasCertAuth() {
@@ -221,19 +392,92 @@ func TLSConfigFor(c *Config) (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
+// withTLSFileReloader
+// - make tlsConfig pick up the client certificate and root CAs from the files of the config
+// each time they change on disk, polling them at most every ReloadInterval
+// - the files were already checked by TLSConfigFor, so a failure here means they changed meanwhile
+func withTLSFileReloader(c *Config, tlsConfig *tls.Config, staticCert *tls.Certificate, passphrase []byte,
+	pins spkiPins, reloadCert, reloadCA bool) (*tls.Config, error) {
+	reloader := &tlsFileReloader{
+		interval:          c.ReloadInterval,
+		onError:           c.OnReloadError,
+		passphrase:        passphrase,
+		appendSystemRoots: c.AppendSystemRoots,
+	}
+
+	switch {
+	case reloadCert && len(c.PKCS12File) > 0:
+		reloader.pkcs12File = c.PKCS12File
+	case reloadCert:
+		reloader.certFile, reloader.keyFile = c.CertFile, c.KeyFile
+	}
+
+	if reloadCA {
+		reloader.caFile = c.CAFile
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case reloadCert:
+		tlsConfig.GetClientCertificate = reloader.clientCertificate
+	case staticCert != nil:
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return staticCert, nil
+		}
+	}
+
+	if reloadCA {
+		serverName := c.ServerName
+		if len(serverName) == 0 {
+			baseURL, _, err := defaultServerURLFor(c)
+			if err != nil {
+				return nil, err
+			}
+
+			serverName = baseURL.Hostname()
+		}
+
+		// crypto/tls only knows the pool it was given, so the chain is verified by hand against
+		// the current one
+		//nolint: gosec
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.RootCAs = nil
+		tlsConfig.VerifyConnection = verifyPeerWithRoots(serverName, reloader.roots, pins)
+	}
+
+	return tlsConfig, nil
+}
+
 // rootCertPool
 // - return nil if caData is empty: when passed along, this will mean "use system CAs"
-// - when caData is not empty, it will be the ONLY information used in the CertPool
-func rootCertPool(caData []byte) *x509.CertPool {
+// - when caData is not empty, it will be the ONLY information used in the CertPool,
+// unless appendSystemRoots is set
+// - caData without any valid PEM certificate is an error
+func rootCertPool(caData []byte, appendSystemRoots bool) (*x509.CertPool, error) {
 	if len(caData) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// If we have caData, use it
 	certPool := x509.NewCertPool()
-	certPool.AppendCertsFromPEM(caData)
 
-	return certPool
+	if appendSystemRoots {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the system root certificates: %v", err)
+		}
+
+		certPool = systemPool
+	}
+
+	// If we have caData, use it
+	if !certPool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no valid PEM certificate found")
+	}
+
+	return certPool, nil
 }
 
 // LoadTLSFiles
@@ -258,6 +502,11 @@ func LoadTLSFiles(c *Config) error {
 		return err
 	}
 
+	c.PKCS12Data, err = dataFromSliceOrFile(c.PKCS12Data, c.PKCS12File)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -364,8 +613,38 @@ func CopyConfig(config *Config) *Config {
 		SecretKey:       config.SecretKey,
 		BearerToken:     config.BearerToken,
 		BearerTokenFile: config.BearerTokenFile,
+		ProxyURL:        config.ProxyURL,
+		Transport:       config.Transport,
+		WrapTransport:   config.WrapTransport,
+		Dial:            config.Dial,
 		UserAgent:       config.UserAgent,
 		Timeout:         config.Timeout,
+		MaxRetries:      config.MaxRetries,
+		RetryInterval:   config.RetryInterval,
+
+		DialTimeout:           config.DialTimeout,
+		KeepAlive:             config.KeepAlive,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		DisableKeepAlives:     config.DisableKeepAlives,
+		EnableHTTP2:           config.EnableHTTP2,
+
+		RateLimiter: config.RateLimiter,
+		Metrics:     config.Metrics,
+		Tracer:      config.Tracer,
+		Verbosity:   config.Verbosity,
+		Logger:      config.Logger,
+
+		ZabbixApiUrl:   config.ZabbixApiUrl,
+		ZabbixApiUser:  config.ZabbixApiUser,
+		ZabbixApiPass:  config.ZabbixApiPass,
+		ZabbixApiToken: config.ZabbixApiToken,
+
+		ZabbixBatchSize: config.ZabbixBatchSize,
 
 		TLSClientConfig: TLSClientConfig{
 			Insecure:   config.TLSClientConfig.Insecure,
@@ -377,6 +656,20 @@ func CopyConfig(config *Config) *Config {
 			KeyData:    config.TLSClientConfig.KeyData,
 			CAData:     config.TLSClientConfig.CAData,
 			NextProtos: config.TLSClientConfig.NextProtos,
+
+			PKCS12File:        config.TLSClientConfig.PKCS12File,
+			PKCS12Data:        config.TLSClientConfig.PKCS12Data,
+			KeyPassphrase:     config.TLSClientConfig.KeyPassphrase,
+			KeyPassphraseEnv:  config.TLSClientConfig.KeyPassphraseEnv,
+			KeyPassphraseFile: config.TLSClientConfig.KeyPassphraseFile,
+
+			AppendSystemRoots: config.TLSClientConfig.AppendSystemRoots,
+			PinnedPublicKeys:  config.TLSClientConfig.PinnedPublicKeys,
+			MinVersion:        config.TLSClientConfig.MinVersion,
+			CipherSuites:      config.TLSClientConfig.CipherSuites,
+
+			ReloadInterval: config.TLSClientConfig.ReloadInterval,
+			OnReloadError:  config.TLSClientConfig.OnReloadError,
 		},
 	}
 }
@@ -1,6 +1,9 @@
 package rest
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // TLSClientConfig contains settings to enable transport layer security.
 type TLSClientConfig struct {
@@ -26,12 +29,51 @@ type TLSClientConfig struct {
 	// CAData takes precedence over CAFile
 	CAData []byte
 
+	// PKCS12File is a PKCS#12 (.p12, .pfx) bundle holding the client certificate, its chain
+	// and its key, used instead of CertFile and KeyFile.
+	PKCS12File string
+	// PKCS12Data holds the base64 encoded content of a PKCS#12 bundle. PKCS12Data takes precedence over PKCS12File
+	PKCS12Data []byte
+
+	// KeyPassphrase decrypts the PKCS#12 bundle, or the client key when it is an encrypted PEM key.
+	// It takes precedence over KeyPassphraseEnv, which takes precedence over KeyPassphraseFile.
+	KeyPassphrase string
+	// KeyPassphraseEnv is the name of an environment variable holding the passphrase.
+	KeyPassphraseEnv string
+	// KeyPassphraseFile is a file whose first line is the passphrase.
+	KeyPassphraseFile string
+
+	// AppendSystemRoots adds the certificates of CAData or CAFile to the system trust store,
+	// instead of trusting them alone.
+	AppendSystemRoots bool
+
+	// PinnedPublicKeys, when set, requires the server certificate or one of its CAs to have one
+	// of these public keys, each given as sha256/ followed by the base64 encoded SHA-256 digest
+	// of the DER-encoded SubjectPublicKeyInfo. See PublicKeyPin.
+	PinnedPublicKeys []string
+
+	// MinVersion is the minimum TLS version accepted, tls.VersionTLS12 if zero.
+	MinVersion uint16
+
+	// CipherSuites is the list of TLS 1.2 cipher suites to use, the defaults of crypto/tls if empty.
+	// TLS 1.3 cipher suites are not configurable.
+	CipherSuites []uint16
+
 	// NextProtos is a list of supported application level protocols, in order of preference.
 	// Used to populate tls.Config.NextProtos.
 	// To indicate to the server http/1.1 is preferred over http/2, set to ["http/1.1", "h2"] (though the server is free
 	// to ignore that preference).
 	// To use only http/1.1, set to ["http/1.1"].
 	NextProtos []string
+
+	// ReloadInterval, when positive, makes new connections pick up the changes made on disk to
+	// CertFile, KeyFile and CAFile, which are checked at most once per interval. It has no effect
+	// on the certificates given as data.
+	ReloadInterval time.Duration
+
+	// OnReloadError is called when the files changed but could not be loaded, the previous
+	// certificates are kept in use.
+	OnReloadError func(error)
 }
 
 var (
@@ -61,6 +103,20 @@ func (c TLSClientConfig) String() string {
 		KeyData:    c.KeyData,
 		CAData:     c.CAData,
 		NextProtos: c.NextProtos,
+
+		PKCS12File:        c.PKCS12File,
+		PKCS12Data:        c.PKCS12Data,
+		KeyPassphrase:     c.KeyPassphrase,
+		KeyPassphraseEnv:  c.KeyPassphraseEnv,
+		KeyPassphraseFile: c.KeyPassphraseFile,
+
+		AppendSystemRoots: c.AppendSystemRoots,
+		PinnedPublicKeys:  c.PinnedPublicKeys,
+		MinVersion:        c.MinVersion,
+		CipherSuites:      c.CipherSuites,
+
+		ReloadInterval: c.ReloadInterval,
+		OnReloadError:  c.OnReloadError,
 	}
 
 	if len(cc.CertData) != 0 {
@@ -71,6 +127,14 @@ func (c TLSClientConfig) String() string {
 		cc.KeyData = []byte("--- REDACTED ---")
 	}
 
+	if len(cc.PKCS12Data) != 0 {
+		cc.PKCS12Data = []byte("--- REDACTED ---")
+	}
+
+	if len(cc.KeyPassphrase) != 0 {
+		cc.KeyPassphrase = "--- REDACTED ---"
+	}
+
 	return fmt.Sprintf("%#v", cc)
 }
 
@@ -79,7 +143,14 @@ func (c TLSClientConfig) HasCA() bool {
 	return len(c.CAData) > 0 || len(c.CAFile) > 0
 }
 
+// hasTLSOptions returns whether the configuration changes the TLS settings of the transport.
+func (c TLSClientConfig) hasTLSOptions() bool {
+	return c.HasCA() || c.HasCertAuth() || c.Insecure || len(c.ServerName) > 0 ||
+		len(c.PinnedPublicKeys) > 0 || c.MinVersion != 0 || len(c.CipherSuites) > 0
+}
+
 // HasCertAuth returns whether the configuration has certificate authentication or not.
 func (c TLSClientConfig) HasCertAuth() bool {
-	return (len(c.CertData) != 0 || len(c.CertFile) != 0) && (len(c.KeyData) != 0 || len(c.KeyFile) != 0)
+	return (len(c.CertData) != 0 || len(c.CertFile) != 0) && (len(c.KeyData) != 0 || len(c.KeyFile) != 0) ||
+		len(c.PKCS12Data) != 0 || len(c.PKCS12File) != 0
 }
@@ -0,0 +1,288 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"moul.io/http2curl"
+)
+
+// Verbosity levels understood by the RESTClient logger.
+const (
+	// LogLevelOff disables request logging.
+	LogLevelOff = 0
+
+	// LogLevelRequest logs the request and response lines, latency and retries.
+	LogLevelRequest = 1
+
+	// LogLevelHeaders also logs the request and response headers.
+	LogLevelHeaders = 2
+
+	// LogLevelBodies also logs the request and response bodies, truncated to MaxLoggedBodyLength.
+	LogLevelBodies = 3
+)
+
+// MaxLoggedBodyLength is the number of bytes of a body that are logged at LogLevelBodies.
+var MaxLoggedBodyLength = 1024
+
+const redacted = "--- REDACTED ---"
+
+// Logger is a structured logger: every message comes with key/value pairs.
+type Logger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Error(err error, msg string, keysAndValues ...interface{})
+}
+
+// stdLogger writes key=value lines through a standard library logger.
+type stdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger returns a Logger writing one key=value line per message to w.
+func NewStdLogger(w io.Writer) Logger {
+	return &stdLogger{l: log.New(w, "[elmt-sdk] ", log.LstdFlags|log.Lmicroseconds)}
+}
+
+func (s *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.l.Print(formatLogLine(msg, keysAndValues))
+}
+
+func (s *stdLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.l.Print(formatLogLine(msg, append(keysAndValues, "error", err)))
+}
+
+func formatLogLine(msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+
+	b.WriteString(msg)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "<missing>"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+
+		fmt.Fprintf(&b, " %v=%q", keysAndValues[i], fmt.Sprint(value))
+	}
+
+	return b.String()
+}
+
+// defaultLogger is used when a verbosity is set without a Logger.
+var defaultLogger = NewStdLogger(os.Stderr)
+
+// sensitiveHeaders are never logged nor written in curl commands.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveFields are the body fields whose values are never logged, compared case insensitively
+// once '_' and '-' are removed.
+var sensitiveFields = map[string]bool{
+	"password":    true,
+	"secretkey":   true,
+	"token":       true,
+	"bearertoken": true,
+	"apipass":     true,
+	"auth":        true,
+}
+
+// RedactHeaders returns a copy of h with the credentials replaced.
+func RedactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	if out == nil {
+		return http.Header{}
+	}
+
+	for _, key := range sensitiveHeaders {
+		if len(out.Values(key)) > 0 {
+			out.Set(key, redacted)
+		}
+	}
+
+	return out
+}
+
+// RedactBody returns body with the values of sensitive JSON fields, such as password and secretKey, replaced.
+// Bodies which are not JSON objects or arrays are returned unchanged.
+func RedactBody(body []byte) []byte {
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return body
+	}
+
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return body
+	}
+
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return body
+	}
+
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, value := range t {
+			key := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(k))
+			if sensitiveFields[key] {
+				if s, ok := value.(string); !ok || len(s) > 0 {
+					t[k] = redacted
+				}
+
+				continue
+			}
+
+			t[k] = redactValue(value)
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+	}
+
+	return v
+}
+
+func truncateBody(body []byte) string {
+	if len(body) > MaxLoggedBodyLength {
+		return string(body[:MaxLoggedBodyLength]) + fmt.Sprintf("...(%d bytes truncated)", len(body)-MaxLoggedBodyLength)
+	}
+
+	return string(body)
+}
+
+// requestBody returns the body of the request as it is sent on the wire, or nil when there is none.
+func (r *Request) requestBody() []byte {
+	switch body := r.body.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(body)
+	case []byte:
+		return body
+	}
+
+	data, err := json.Marshal(r.body)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// logRequest logs the outgoing request according to the client verbosity.
+func (r *Request) logRequest(url string) {
+	if r.c.verbosity < LogLevelRequest {
+		return
+	}
+
+	kv := []interface{}{"verb", r.verb, "url", url}
+
+	if r.c.verbosity >= LogLevelHeaders {
+		kv = append(kv, "headers", formatHeaders(RedactHeaders(r.headers)))
+	}
+
+	if body := r.requestBody(); r.c.verbosity >= LogLevelBodies && len(body) > 0 {
+		kv = append(kv, "body", truncateBody(RedactBody(body)))
+	}
+
+	r.c.logger.Info("HTTP request", kv...)
+}
+
+// logResponse logs the response, or the error, according to the client verbosity.
+func (r *Request) logResponse(url string, resp *http.Response, body []byte, m RequestMetrics, err error) {
+	if r.c.verbosity < LogLevelRequest {
+		return
+	}
+
+	kv := []interface{}{
+		"verb", r.verb,
+		"url", url,
+		"status", m.StatusCode,
+		"latency", m.Latency.Round(time.Microsecond),
+		"retries", m.Retries,
+	}
+
+	if resp != nil && r.c.verbosity >= LogLevelHeaders {
+		kv = append(kv, "headers", formatHeaders(RedactHeaders(resp.Header)))
+	}
+
+	if r.c.verbosity >= LogLevelBodies && len(body) > 0 {
+		kv = append(kv, "body", truncateBody(RedactBody(body)))
+	}
+
+	if err != nil {
+		r.c.logger.Error(err, "HTTP response", kv...)
+		return
+	}
+
+	r.c.logger.Info("HTTP response", kv...)
+}
+
+func formatHeaders(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+": "+strings.Join(h[k], ", "))
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+// CurlCommand
+// - return a copy-pasteable curl command reproducing the request, to be attached to bug reports
+// - credential headers and sensitive body fields are redacted
+func (r *Request) CurlCommand() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+
+	client := r.newAgent()
+	client.Header = RedactHeaders(r.headers)
+
+	req, err := client.CustomMethod(r.verb, r.URL().String()).Send(r.body).MakeRequest()
+	if err != nil {
+		return "", err
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+
+		body = RedactBody(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	cmd, err := http2curl.GetCurlCommand(req)
+	if err != nil {
+		return "", err
+	}
+
+	return cmd.String(), nil
+}
@@ -0,0 +1,319 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, formatLogLine(msg, keysAndValues))
+}
+
+func (l *recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, formatLogLine(msg, append(keysAndValues, "error", err)))
+}
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return strings.Join(l.lines, "\n")
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret-token")
+	h.Set("Proxy-Authorization", "Basic c2VjcmV0")
+	h.Add("Cookie", "session=secret")
+	h.Add("Cookie", "other=secret")
+	h.Set("Content-Type", "application/json")
+
+	out := RedactHeaders(h)
+	for _, key := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		if got := out.Values(key); len(got) != 1 || got[0] != redacted {
+			t.Errorf("%s = %v, want redacted", key, got)
+		}
+	}
+
+	if got := out.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	if h.Get("Authorization") != "Bearer secret-token" {
+		t.Errorf("RedactHeaders modified its argument")
+	}
+
+	if out := RedactHeaders(nil); out == nil || len(out) != 0 {
+		t.Errorf("RedactHeaders(nil) = %v", out)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "top level fields",
+			body: `{"name":"alice","password":"s3cret","secretKey":"k3y"}`,
+			want: `{"name":"alice","password":"--- REDACTED ---","secretKey":"--- REDACTED ---"}`,
+		},
+		{
+			name: "case, underscores and dashes",
+			body: `{"Secret_Key":"a","api-pass":"b","Token":"c","bearer_token":"d"}`,
+			want: `{"Secret_Key":"--- REDACTED ---","api-pass":"--- REDACTED ---","Token":"--- REDACTED ---","bearer_token":"--- REDACTED ---"}`,
+		},
+		{
+			name: "nested objects and arrays",
+			body: `{"items":[{"name":"a","auth":"x"}],"meta":{"secret_key":"y"}}`,
+			want: `{"items":[{"auth":"--- REDACTED ---","name":"a"}],"meta":{"secret_key":"--- REDACTED ---"}}`,
+		},
+		{
+			name: "empty secrets are kept",
+			body: `{"password":""}`,
+			want: `{"password":""}`,
+		},
+		{
+			name: "numbers are preserved",
+			body: `[{"id":12345678901234567890,"token":42}]`,
+			want: `[{"id":12345678901234567890,"token":"--- REDACTED ---"}]`,
+		},
+		{name: "not json", body: `password=s3cret`, want: `password=s3cret`},
+		{name: "json scalar", body: `"s3cret"`, want: `"s3cret"`},
+		{name: "empty", body: ``, want: ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(RedactBody([]byte(tt.body)))
+			if !jsonEqual(got, tt.want) {
+				t.Errorf("RedactBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+// jsonEqual compares two bodies ignoring the order of the object keys.
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestTruncateBody(t *testing.T) {
+	old := MaxLoggedBodyLength
+	MaxLoggedBodyLength = 4
+	defer func() { MaxLoggedBodyLength = old }()
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{body: "", want: ""},
+		{body: "abcd", want: "abcd"},
+		{body: "abcdefg", want: "abcd...(3 bytes truncated)"},
+	}
+
+	for _, tt := range tests {
+		if got := truncateBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("truncateBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLogLine(t *testing.T) {
+	tests := []struct {
+		msg  string
+		kv   []interface{}
+		want string
+	}{
+		{msg: "HTTP request", want: "HTTP request"},
+		{msg: "m", kv: []interface{}{"verb", "GET", "status", 200}, want: `m verb="GET" status="200"`},
+		{msg: "m", kv: []interface{}{"odd"}, want: `m odd="<missing>"`},
+		{msg: "m", kv: []interface{}{"error", errors.New("a \"quoted\" error")}, want: `m error="a \"quoted\" error"`},
+	}
+
+	for _, tt := range tests {
+		if got := formatLogLine(tt.msg, tt.kv); got != tt.want {
+			t.Errorf("formatLogLine(%q, %v) = %s, want %s", tt.msg, tt.kv, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=response-secret")
+		w.Header().Set("X-Request-Id", "req-1")
+
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"name":"alice","secretKey":"response-secret"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		verbosity int
+		path      string
+		want      []string
+		wantNot   []string
+	}{
+		{
+			name:      "off",
+			verbosity: LogLevelOff,
+			path:      "users",
+			wantNot:   []string{"HTTP"},
+		},
+		{
+			name:      "request lines",
+			verbosity: LogLevelRequest,
+			path:      "users",
+			want:      []string{`HTTP request verb="POST"`, `HTTP response verb="POST"`, `status="200"`, `retries="0"`},
+			wantNot:   []string{"X-Request-Id", "alice"},
+		},
+		{
+			name:      "headers",
+			verbosity: LogLevelHeaders,
+			path:      "users",
+			want:      []string{"Authorization: " + redacted, "X-Request-Id: req-1", "Set-Cookie: " + redacted},
+			wantNot:   []string{"alice"},
+		},
+		{
+			name:      "bodies",
+			verbosity: LogLevelBodies,
+			path:      "users",
+			want:      []string{`\"name\":\"alice\"`, `\"password\":\"` + redacted},
+		},
+		{
+			name:      "error response",
+			verbosity: LogLevelBodies,
+			path:      "missing",
+			want:      []string{`status="404"`, `body="not found"`, `error="not found"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+
+			config := testConfig(server.URL)
+			config.Username = "admin"
+			config.Password = "request-secret"
+			config.Verbosity = tt.verbosity
+			config.Logger = logger
+
+			client := newTestClient(t, config)
+			_ = client.Post().
+				Resource(tt.path).
+				Body(map[string]string{"name": "alice", "password": "request-secret"}).
+				Do(context.Background()).
+				Error()
+
+			out := logger.String()
+			for _, s := range append(tt.wantNot, "request-secret", "response-secret") {
+				if strings.Contains(out, s) {
+					t.Errorf("log contains %q:\n%s", s, out)
+				}
+			}
+
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("log does not contain %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestCurlCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		request func(*RESTClient) *Request
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "get with query",
+			config: func(c *Config) { c.BearerToken = "request-secret" },
+			request: func(c *RESTClient) *Request {
+				return c.Get().Resource("users").Name("alice").Param("limit", "10")
+			},
+			want: []string{"curl -X 'GET'", "-H 'Authorization: " + redacted + "'", "'http://elmt.test/v1/users/alice?limit=10'"},
+		},
+		{
+			name: "post with a secret body",
+			config: func(c *Config) {
+				c.SecretID = "id"
+				c.SecretKey = "request-secret"
+			},
+			request: func(c *RESTClient) *Request {
+				return c.Post().Resource("secrets").Body(map[string]string{"name": "s", "secretKey": "request-secret"})
+			},
+			want: []string{"curl -X 'POST'", `-d '{"name":"s","secretKey":"` + redacted + `"}'`, "'http://elmt.test/v1/secrets'"},
+		},
+		{
+			name:   "request error",
+			config: func(c *Config) {},
+			request: func(c *RESTClient) *Request {
+				r := c.Get().Resource("users")
+				r.err = fmt.Errorf("broken request")
+				return r
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig("http://elmt.test")
+			tt.config(config)
+
+			cmd, err := tt.request(newTestClient(t, config)).CurlCommand()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CurlCommand error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if strings.Contains(cmd, "request-secret") {
+				t.Errorf("curl command leaks a secret: %s", cmd)
+			}
+
+			for _, s := range tt.want {
+				if !strings.Contains(cmd, s) {
+					t.Errorf("curl command %s does not contain %s", cmd, s)
+				}
+			}
+		})
+	}
+}
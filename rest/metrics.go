@@ -0,0 +1,45 @@
+package rest
+
+import "time"
+
+// RequestMetrics describes the outcome of a single Request.Do call.
+type RequestMetrics struct {
+	Verb     string
+	Resource string
+	Host     string
+
+	// StatusCode is zero when no response was received from the server.
+	StatusCode int
+
+	// Latency covers the whole call, including retries but excluding rate limiter wait time.
+	Latency time.Duration
+
+	// Retries is the number of extra attempts made after the first one.
+	Retries int
+
+	RequestBytes  int64
+	ResponseBytes int64
+}
+
+// MetricsProvider
+// - receive telemetry from the RESTClient
+// - implementations must be safe for concurrent use, they are called from every Request.Do
+type MetricsProvider interface {
+	// ObserveRequest is called after every request, whether it succeeded or not.
+	ObserveRequest(m RequestMetrics)
+
+	// ObserveRateLimiterWait is called with the time a request spent waiting on the rate limiter.
+	ObserveRateLimiterWait(verb, host string, wait time.Duration)
+
+	// AddInFlight adjusts the number of requests currently being executed by delta.
+	AddInFlight(verb, host string, delta int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveRequest(RequestMetrics)                        {}
+func (noopMetrics) ObserveRateLimiterWait(string, string, time.Duration) {}
+func (noopMetrics) AddInFlight(string, string, int)                      {}
+
+// NoopMetrics is a MetricsProvider that discards everything, it is used when Config.Metrics is not set.
+var NoopMetrics MetricsProvider = noopMetrics{}
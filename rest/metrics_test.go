@@ -0,0 +1,260 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []RequestMetrics
+	waits    []time.Duration
+	inFlight map[string]int
+	maxIn    int
+}
+
+func (m *recordingMetrics) ObserveRequest(r RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, r)
+}
+
+func (m *recordingMetrics) ObserveRateLimiterWait(verb, host string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waits = append(m.waits, wait)
+}
+
+func (m *recordingMetrics) AddInFlight(verb, host string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inFlight == nil {
+		m.inFlight = map[string]int{}
+	}
+
+	m.inFlight[verb+" "+host] += delta
+	if n := m.inFlight[verb+" "+host]; n > m.maxIn {
+		m.maxIn = n
+	}
+}
+
+type sleepingLimiter struct {
+	delay time.Duration
+	err   error
+}
+
+func (l sleepingLimiter) Wait(ctx context.Context) error {
+	time.Sleep(l.delay)
+	return l.err
+}
+
+func TestRequestMetrics(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/retried":
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "/v1/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"name":"alice"}`))
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name     string
+		verb     string
+		resource string
+		body     interface{}
+		want     RequestMetrics
+		wantErr  bool
+	}{
+		{
+			name:     "get",
+			verb:     http.MethodGet,
+			resource: "users",
+			want:     RequestMetrics{Verb: "GET", Resource: "users", Host: host, StatusCode: 200, ResponseBytes: 16},
+		},
+		{
+			name:     "post with a body",
+			verb:     http.MethodPost,
+			resource: "users",
+			body:     map[string]string{"name": "bob"},
+			want: RequestMetrics{Verb: "POST", Resource: "users", Host: host, StatusCode: 200,
+				RequestBytes: 14, ResponseBytes: 16},
+		},
+		{
+			name:     "server error",
+			verb:     http.MethodGet,
+			resource: "missing",
+			want:     RequestMetrics{Verb: "GET", Resource: "missing", Host: host, StatusCode: 404, ResponseBytes: 9},
+			wantErr:  true,
+		},
+		{
+			name:     "retries",
+			verb:     http.MethodGet,
+			resource: "retried",
+			want:     RequestMetrics{Verb: "GET", Resource: "retried", Host: host, StatusCode: 200, Retries: 2, ResponseBytes: 16},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &recordingMetrics{}
+			config := testConfig(server.URL)
+			config.Metrics = metrics
+			config.MaxRetries = 3
+			config.RetryInterval = time.Millisecond
+
+			client := newTestClient(t, config)
+
+			err := client.Verb(tt.verb).Resource(tt.resource).Body(tt.body).Do(context.Background()).Error()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Do error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if len(metrics.requests) != 1 {
+				t.Fatalf("ObserveRequest called %d times, want 1", len(metrics.requests))
+			}
+
+			got := metrics.requests[0]
+			if got.Latency <= 0 {
+				t.Errorf("Latency = %v, want a positive duration", got.Latency)
+			}
+
+			got.Latency = 0
+			if got != tt.want {
+				t.Errorf("RequestMetrics = %+v, want %+v", got, tt.want)
+			}
+
+			if metrics.maxIn != 1 || metrics.inFlight["GET "+host]+metrics.inFlight["POST "+host] != 0 {
+				t.Errorf("in flight = %v, max %d, want back to 0 after a max of 1", metrics.inFlight, metrics.maxIn)
+			}
+
+			if len(metrics.waits) != 0 {
+				t.Errorf("ObserveRateLimiterWait called without a rate limiter")
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		limiter      sleepingLimiter
+		wantRequests int
+	}{
+		{name: "allowed", limiter: sleepingLimiter{delay: 20 * time.Millisecond}, wantRequests: 1},
+		{name: "rejected", limiter: sleepingLimiter{err: errors.New("rate limited")}, wantRequests: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &recordingMetrics{}
+			config := testConfig(server.URL)
+			config.Metrics = metrics
+			config.RateLimiter = tt.limiter
+
+			err := newTestClient(t, config).Get().Resource("users").Do(context.Background()).Error()
+			if !errors.Is(err, tt.limiter.err) {
+				t.Fatalf("Do error = %v, want %v", err, tt.limiter.err)
+			}
+
+			if len(metrics.waits) != 1 || metrics.waits[0] < tt.limiter.delay {
+				t.Errorf("rate limiter waits = %v, want one of at least %v", metrics.waits, tt.limiter.delay)
+			}
+
+			if len(metrics.requests) != tt.wantRequests {
+				t.Errorf("ObserveRequest called %d times, want %d", len(metrics.requests), tt.wantRequests)
+			}
+		})
+	}
+}
+
+// TestConcurrentRequestMetrics sends requests from several goroutines through a single RESTClient,
+// run with -race.
+func TestConcurrentRequestMetrics(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(`{"name":"alice"}`))
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	metrics := &recordingMetrics{}
+	config := testConfig(server.URL)
+	config.Metrics = metrics
+
+	client := newTestClient(t, config)
+
+	const n = 10
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- client.Get().Resource("users").Do(context.Background()).Error()
+		}()
+	}
+
+	// Every request is in flight before the server answers any of them
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		metrics.mu.Lock()
+		in := metrics.inFlight["GET "+host]
+		metrics.mu.Unlock()
+
+		if in == n || time.Now().After(deadline) {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Do error = %v", err)
+		}
+	}
+
+	if len(metrics.requests) != n || metrics.maxIn != n || metrics.inFlight["GET "+host] != 0 {
+		t.Errorf("%d requests observed, max in flight %d, in flight %d, want %d, %d and 0",
+			len(metrics.requests), metrics.maxIn, metrics.inFlight["GET "+host], n, n)
+	}
+}
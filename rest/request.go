@@ -2,7 +2,6 @@ package rest
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/opsdata/common-base/pkg/auth"
 	"github.com/opsdata/common-base/pkg/runtime"
 
 	"github.com/opsdata/elmt-sdk/third_party/forked/gorequest"
@@ -32,6 +30,9 @@ type Request struct {
 	params     url.Values
 	headers    http.Header
 
+	// query objects added by VersionedParams, encoded by gorequest when the request is sent
+	versionedParams []interface{}
+
 	// structural elements of the request that are part of the ELMT API conventions
 	// <resource>/[ns/<namespace>/]<name>
 	namespace    string
@@ -59,33 +60,15 @@ func NewRequest(c *RESTClient) *Request {
 		pathPrefix: pathPrefix,
 	}
 
-	// Check the count of auth methods:
-	// - 只能设置其中一个
-	// 1.basic auth
-	// 2.bearer token
-	// 3.secretID, secretKey
-	authMethod := 0
-	for _, fn := range []func() bool{c.content.HasBasicAuth, c.content.HasTokenAuth, c.content.HasKeyAuth} {
-		if fn() {
-			authMethod++
-		}
-	}
-	if authMethod > 1 {
-		r.err = fmt.Errorf(
-			"username/password or bearer token or secretID/secretKey may be set, but should use only one of them",
-		)
+	// Set authorization header
+	authorization, err := c.content.AuthorizationHeader(c.group)
+	if err != nil {
+		r.err = err
 		return r
 	}
 
-	// Set authorization header
-	switch {
-	case c.content.HasBasicAuth():
-		r.SetHeader("Authorization", "Basic "+basicAuth(c.content.Username, c.content.Password))
-	case c.content.HasTokenAuth():
-		r.SetHeader("Authorization", fmt.Sprintf("Bearer %s", c.content.BearerToken))
-	case c.content.HasKeyAuth():
-		tokenString := auth.Sign(c.content.SecretID, c.content.SecretKey, "elmt-sdk", c.group+".elmt")
-		r.SetHeader("Authorization", fmt.Sprintf("Bearer %s", tokenString))
+	if len(authorization) > 0 {
+		r.SetHeader("Authorization", authorization)
 	}
 
 	// Set accept content header
@@ -99,11 +82,6 @@ func NewRequest(c *RESTClient) *Request {
 	return r
 }
 
-func basicAuth(username, password string) string {
-	auth := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(auth))
-}
-
 // SetHeader set header for a http request.
 func (r *Request) SetHeader(key string, values ...string) *Request {
 	if r.headers == nil {
@@ -124,6 +102,9 @@ func NewRequestWithClient(base *url.URL, versionedAPIPath string,
 		base:             base,
 		versionedAPIPath: versionedAPIPath,
 		content:          content,
+		metrics:          NoopMetrics,
+		tracer:           NoopTracer,
+		logger:           defaultLogger,
 		Client:           client,
 	})
 }
@@ -382,8 +363,9 @@ func (r *Request) Body(obj interface{}) *Request {
 
 // Do formats and executes the request. Returns a Result object for easy response processing.
 func (r *Request) Do(ctx context.Context) Result {
-	client := r.c.Client
-	client.Header = r.headers
+	if r.err != nil {
+		return Result{err: r.err}
+	}
 
 	if r.timeout > 0 {
 		var cancel context.CancelFunc
@@ -392,10 +374,57 @@ func (r *Request) Do(ctx context.Context) Result {
 		defer cancel()
 	}
 
+	host := r.host()
+
+	ctx, span := r.c.tracer.Start(ctx, "ELMT "+r.verb+" "+r.resource)
+	defer span.End()
+
+	if sc := span.SpanContext(); sc.IsValid() {
+		r.SetHeader(TraceParentHeader, sc.TraceParent())
+
+		if len(sc.TraceState) > 0 {
+			r.SetHeader(TraceStateHeader, sc.TraceState)
+		}
+	}
+
+	if r.c.rateLimiter != nil {
+		start := time.Now()
+		err := r.c.rateLimiter.Wait(ctx)
+		r.c.metrics.ObserveRateLimiterWait(r.verb, host, time.Since(start))
+
+		if err != nil {
+			span.RecordError(err)
+			return Result{err: err}
+		}
+	}
+
+	r.c.metrics.AddInFlight(r.verb, host, 1)
+	defer r.c.metrics.AddInFlight(r.verb, host, -1)
+
+	client := r.newAgent()
 	client.WithContext(ctx)
 
-	resp, body, errs := client.CustomMethod(r.verb, r.URL().String()).Send(r.body).EndBytes()
-	if err := combineErr(resp, body, errs); err != nil {
+	reqURL := r.URL().String()
+	r.logRequest(reqURL)
+
+	start := time.Now()
+	resp, body, errs := client.CustomMethod(r.verb, reqURL).Send(r.body).EndBytes()
+	m := r.observe(resp, body, client.Retryable.Attempt, time.Since(start))
+
+	span.SetAttribute(AttributeVerb, m.Verb)
+	span.SetAttribute(AttributeURL, reqURL)
+	span.SetAttribute(AttributeHost, m.Host)
+	span.SetAttribute(AttributeResource, r.resource)
+	span.SetAttribute(AttributeName, r.resourceName)
+	span.SetAttribute(AttributeStatusCode, m.StatusCode)
+	span.SetAttribute(AttributeRetries, m.Retries)
+
+	err := combineErr(resp, body, errs)
+	r.logResponse(reqURL, resp, body, m, err)
+
+	if err != nil {
+		span.RecordError(err)
+
 		return Result{
 			response: &resp,
 			err:      err,
@@ -420,6 +449,59 @@ func (r *Request) Do(ctx context.Context) Result {
 	}
 }
 
+// newAgent
+// - return a copy of the RESTClient agent carrying only the state of this request
+// - the shared agent is never mutated, so a RESTClient may be used from several goroutines
+func (r *Request) newAgent() *gorequest.SuperAgent {
+	client := r.c.Client.Clone()
+	client.Header = r.headers
+	client.Data = make(map[string]interface{})
+	client.SliceData = []interface{}{}
+	client.RawString = ""
+	client.BounceToRawString = false
+	client.QueryData = url.Values{}
+	client.Retryable.Attempt = 0
+
+	for _, v := range r.versionedParams {
+		client.Query(v)
+	}
+
+	return client
+}
+
+// host returns the host the request is sent to, used to label telemetry.
+func (r *Request) host() string {
+	if r.c.base == nil {
+		return ""
+	}
+
+	return r.c.base.Host
+}
+
+// observe reports the outcome of the request to the metrics provider.
+func (r *Request) observe(resp gorequest.Response, body []byte, retries int, latency time.Duration) RequestMetrics {
+	m := RequestMetrics{
+		Verb:          r.verb,
+		Resource:      r.resource,
+		Host:          r.host(),
+		Latency:       latency,
+		Retries:       retries,
+		ResponseBytes: int64(len(body)),
+	}
+
+	if resp != nil {
+		m.StatusCode = resp.StatusCode
+
+		if resp.Request != nil && resp.Request.ContentLength > 0 {
+			m.RequestBytes = resp.Request.ContentLength
+		}
+	}
+
+	r.c.metrics.ObserveRequest(m)
+
+	return m
+}
+
 // Result contains the result of calling Request.Do().
 type Result struct {
 	response *gorequest.Response
@@ -455,6 +537,15 @@ func (r Result) Error() error {
 	return r.err
 }
 
+// StatusCode returns the HTTP status code of the response, or 0 if no response was received.
+func (r Result) StatusCode() int {
+	if r.response == nil || *r.response == nil {
+		return 0
+	}
+
+	return (*r.response).StatusCode
+}
+
 func combineErr(resp gorequest.Response, body []byte, errs []error) error {
 	var e, sep string
 
@@ -508,7 +599,7 @@ func (r *Request) VersionedParams(v interface{}) *Request {
 		return r
 	}
 
-	r.c.Client.Query(v)
+	r.versionedParams = append(r.versionedParams, v)
 
 	return r
 }
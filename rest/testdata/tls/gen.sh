@@ -0,0 +1,49 @@
+#!/bin/sh
+# Regenerates the client certificates, keys and PKCS#12 bundles used by the rest TLS tests.
+# Requires OpenSSL 3. The passphrase of every encrypted file is "testpass".
+set -e
+cd "$(dirname "$0")"
+
+pass=pass:testpass
+days=36500
+
+openssl req -x509 -newkey rsa:2048 -nodes -keyout root.key -out root.crt -days $days -subj "/CN=test root"
+openssl req -newkey rsa:2048 -nodes -keyout intermediate.key -out intermediate.csr -subj "/CN=test intermediate"
+printf 'basicConstraints=critical,CA:true\nkeyUsage=critical,keyCertSign\n' > ca.ext
+openssl x509 -req -in intermediate.csr -CA root.crt -CAkey root.key -CAcreateserial -days $days \
+	-extfile ca.ext -out intermediate.crt
+
+printf 'extendedKeyUsage=clientAuth\n' > client.ext
+openssl req -newkey rsa:2048 -nodes -keyout client.key -out client.csr -subj "/CN=rsa client"
+openssl x509 -req -in client.csr -CA intermediate.crt -CAkey intermediate.key -CAcreateserial -days $days \
+	-extfile client.ext -out client.crt
+openssl req -newkey ec -pkeyopt ec_paramgen_curve:P-256 -nodes -keyout client-ec.key -out client-ec.csr \
+	-subj "/CN=ec client"
+openssl x509 -req -in client-ec.csr -CA intermediate.crt -CAkey intermediate.key -CAcreateserial -days $days \
+	-extfile client.ext -out client-ec.crt
+
+# the chain is given root first, the bundles must still start with the client certificate
+cat root.crt intermediate.crt > chain.crt
+
+# PKCS#12: PBES2 with AES-256-CBC and a SHA-256 MAC, the default of OpenSSL 3
+openssl pkcs12 -export -in client.crt -inkey client.key -certfile chain.crt -passout $pass -out client-aes.p12
+# PKCS#12: PBES2 with AES-128-CBC and a SHA-512 MAC, EC key
+openssl pkcs12 -export -in client-ec.crt -inkey client-ec.key -certfile chain.crt -passout $pass \
+	-keypbe aes-128-cbc -certpbe aes-128-cbc -macalg sha512 -out client-ec-aes128.p12
+# PKCS#12: PBES2 with 3DES
+openssl pkcs12 -export -in client.crt -inkey client.key -certfile chain.crt -passout $pass \
+	-keypbe des-ede3-cbc -certpbe des-ede3-cbc -out client-pbes2-3des.p12
+# PKCS#12: legacy PKCS#12 PBE with 3DES and a SHA-1 MAC
+openssl pkcs12 -export -in client.crt -inkey client.key -certfile chain.crt -passout $pass \
+	-keypbe PBE-SHA1-3DES -certpbe PBE-SHA1-3DES -macalg sha1 -out client-legacy-3des.p12
+
+# encrypted PEM keys
+openssl pkcs8 -topk8 -in client.key -v2 aes-256-cbc -v2prf hmacWithSHA256 -passout $pass -out client-pkcs8-aes256.key
+openssl pkcs8 -topk8 -in client.key -v2 aes-128-cbc -v2prf hmacWithSHA1 -passout $pass -out client-pkcs8-aes128-sha1.key
+openssl pkcs8 -topk8 -in client.key -v2 des3 -passout $pass -out client-pkcs8-3des.key
+openssl pkcs8 -topk8 -in client.key -v1 PBE-SHA1-3DES -passout $pass -out client-pkcs8-v1.key
+openssl pkcs8 -topk8 -in client-ec.key -v2 aes-256-cbc -passout $pass -out client-ec-pkcs8-aes256.key
+openssl rsa -in client.key -des3 -traditional -passout $pass -out client-legacy-3des.key
+openssl ec -in client-ec.key -aes256 -passout $pass -out client-ec-legacy-aes256.key
+
+rm -f *.csr *.ext *.srl root.key intermediate.key
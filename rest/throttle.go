@@ -0,0 +1,12 @@
+package rest
+
+import "context"
+
+// RateLimiter
+// - throttle the requests sent by a RESTClient, the SDK itself ships no limiter
+// - the time every request spends in Wait is reported to MetricsProvider.ObserveRateLimiterWait
+// - golang.org/x/time/rate.Limiter, among others, satisfies it
+type RateLimiter interface {
+	// Wait blocks until the request is allowed to proceed or ctx is done.
+	Wait(ctx context.Context) error
+}
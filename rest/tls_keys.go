@@ -0,0 +1,344 @@
+package rest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// errPassphraseRequired is returned when an encrypted key or bundle is given without a passphrase.
+var errPassphraseRequired = errors.New("the client key is encrypted but no passphrase was given")
+
+// keyPassphraseFor
+// - return the passphrase of the client key or PKCS#12 bundle of the config, or nil if there is none
+// - KeyPassphrase takes precedence over KeyPassphraseEnv, which takes precedence over KeyPassphraseFile
+func keyPassphraseFor(c *TLSClientConfig) ([]byte, error) {
+	switch {
+	case len(c.KeyPassphrase) > 0:
+		return []byte(c.KeyPassphrase), nil
+	case len(c.KeyPassphraseEnv) > 0:
+		passphrase, ok := os.LookupEnv(c.KeyPassphraseEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s holding the client key passphrase is not set",
+				c.KeyPassphraseEnv)
+		}
+
+		return []byte(passphrase), nil
+	case len(c.KeyPassphraseFile) > 0:
+		data, err := ioutil.ReadFile(c.KeyPassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the client key passphrase: %v", err)
+		}
+
+		// Like openssl -passin file:, only the first line is the passphrase
+		return []byte(strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")), nil
+	}
+
+	return nil, nil
+}
+
+// clientCertificate
+// - return the client certificate made of either a PKCS#12 bundle, or PEM-encoded certificates
+// and key, the latter being possibly encrypted
+// - the certificate issued for the key comes first, followed by the rest of the chain
+func clientCertificate(certPEM, keyPEM, pkcs12Data, passphrase []byte) (*tls.Certificate, error) {
+	if len(pkcs12Data) > 0 {
+		certs, key, err := decodePKCS12(pkcs12Data, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load PKCS#12 client certificate: %v", err)
+		}
+
+		return certificateChain(certs, key)
+	}
+
+	keyPEM, err := decryptPEMKey(keyPEM, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+// certificateChain
+// - put the certificate matching the key first, followed by its issuer, the issuer of the latter
+// and so on, as TLS requires, whatever the order of the bundle
+// - the certificates which are not part of that chain come last
+func certificateChain(certs []*x509.Certificate, key crypto.PrivateKey) (*tls.Certificate, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	cert := &tls.Certificate{PrivateKey: key}
+
+	for i, c := range certs {
+		if !publicKeyEqual(c.PublicKey, signer.Public()) {
+			continue
+		}
+
+		used := make([]bool, len(certs))
+		used[i] = true
+
+		cert.Leaf = c
+		cert.Certificate = append(cert.Certificate, c.Raw)
+
+		for last := c; ; {
+			next := issuerIndex(last, certs, used)
+			if next < 0 {
+				break
+			}
+
+			used[next] = true
+			cert.Certificate = append(cert.Certificate, certs[next].Raw)
+			last = certs[next]
+		}
+
+		for j, other := range certs {
+			if !used[j] {
+				cert.Certificate = append(cert.Certificate, other.Raw)
+			}
+		}
+
+		return cert, nil
+	}
+
+	return nil, fmt.Errorf("none of the certificates matches the private key")
+}
+
+// issuerIndex returns the index of the unused certificate which signed cert, or -1.
+func issuerIndex(cert *x509.Certificate, certs []*x509.Certificate, used []bool) int {
+	for i, c := range certs {
+		if !used[i] && bytes.Equal(cert.RawIssuer, c.RawSubject) && cert.CheckSignatureFrom(c) == nil {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	switch k := a.(type) {
+	case *rsa.PublicKey:
+		return k.Equal(b)
+	case *ecdsa.PublicKey:
+		return k.Equal(b)
+	case ed25519.PublicKey:
+		return k.Equal(b)
+	}
+
+	return false
+}
+
+// decryptPEMKey
+// - return the PEM-encoded key unchanged if it is not encrypted
+// - otherwise decrypt it with the passphrase, both PKCS#8 ENCRYPTED PRIVATE KEY blocks and the
+// legacy OpenSSL encryption, marked by a Proc-Type header, are supported
+func decryptPEMKey(keyPEM, passphrase []byte) ([]byte, error) {
+	var (
+		out  []byte
+		rest = keyPEM
+	)
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		switch {
+		case block.Type == "ENCRYPTED PRIVATE KEY":
+			if passphrase == nil {
+				return nil, errPassphraseRequired
+			}
+
+			der, err := decryptPKCS8(block.Bytes, passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decrypt the client key: %v", err)
+			}
+
+			block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		case x509.IsEncryptedPEMBlock(block): //nolint: staticcheck
+			if passphrase == nil {
+				return nil, errPassphraseRequired
+			}
+
+			//nolint: staticcheck
+			der, err := x509.DecryptPEMBlock(block, passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decrypt the client key: %v", err)
+			}
+
+			block = &pem.Block{Type: block.Type, Bytes: der}
+		}
+
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+
+	if out == nil {
+		// Let tls.X509KeyPair report the missing key
+		return keyPEM, nil
+	}
+
+	return out, nil
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts a DER-encoded EncryptedPrivateKeyInfo and returns the PrivateKeyInfo.
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted private key: %v", err)
+	}
+
+	return decryptPBES2(info.Algorithm, info.EncryptedData, passphrase)
+}
+
+// decryptPBES2 decrypts data encrypted with the PBES2 scheme of PKCS#5 v2, using PBKDF2 and
+// AES or 3DES in CBC mode, which is what OpenSSL 3 uses by default.
+func decryptPBES2(algorithm pkix.AlgorithmIdentifier, data, passphrase []byte) ([]byte, error) {
+	if !algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption algorithm %v, only PBES2 is supported", algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %v", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %v, only PBKDF2 is supported",
+			params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 parameters: %v", err)
+	}
+
+	prf, err := pbkdf2PRF(kdf.PRF)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		keyLength int
+		newCipher func(key []byte) (cipher.Block, error)
+	)
+
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme %v", scheme)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("invalid encryption scheme parameters: %v", err)
+	}
+
+	block, err := newCipher(pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, keyLength, prf))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	// A wrong passphrase is almost always detected by an invalid padding
+	padding := int(out[len(out)-1])
+	if padding == 0 || padding > block.BlockSize() ||
+		!bytes.Equal(out[len(out)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("decryption failed, the passphrase is probably incorrect")
+	}
+
+	return out[:len(out)-padding], nil
+}
+
+// pbkdf2PRF returns the hash of the HMAC used by PBKDF2, HMAC-SHA1 when absent.
+func pbkdf2PRF(prf pkix.AlgorithmIdentifier) (func() hash.Hash, error) {
+	switch {
+	case len(prf.Algorithm) == 0, prf.Algorithm.Equal(oidHMACWithSHA1):
+		return sha1.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA224):
+		return sha256.New224, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA256):
+		return sha256.New, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA384):
+		return sha512.New384, nil
+	case prf.Algorithm.Equal(oidHMACWithSHA512):
+		return sha512.New, nil
+	}
+
+	return nil, fmt.Errorf("unsupported PBKDF2 pseudorandom function %v", prf.Algorithm)
+}
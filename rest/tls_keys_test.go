@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pemBlock(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+
+	return block.Bytes
+}
+
+func TestDecryptPEMKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		cert       string
+		passphrase []byte
+		wantErr    string
+	}{
+		{key: "client.key", cert: "client.crt"},
+		{key: "client.key", cert: "client.crt", passphrase: []byte("ignored")},
+		{key: "client-pkcs8-aes256.key", cert: "client.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-pkcs8-aes128-sha1.key", cert: "client.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-pkcs8-3des.key", cert: "client.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-legacy-3des.key", cert: "client.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-ec-pkcs8-aes256.key", cert: "client-ec.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-ec-legacy-aes256.key", cert: "client-ec.crt", passphrase: []byte(testPassphrase)},
+		{key: "client-pkcs8-aes256.key", cert: "client.crt", wantErr: "no passphrase was given"},
+		{key: "client-legacy-3des.key", cert: "client.crt", wantErr: "no passphrase was given"},
+		{key: "client-pkcs8-aes256.key", cert: "client.crt", passphrase: []byte("wrong"), wantErr: "passphrase is probably incorrect"},
+		{key: "client-pkcs8-3des.key", cert: "client.crt", passphrase: []byte("wrong"), wantErr: "passphrase is probably incorrect"},
+		{key: "client-legacy-3des.key", cert: "client.crt", passphrase: []byte("wrong"), wantErr: "unable to decrypt"},
+		{key: "client-ec-pkcs8-aes256.key", cert: "client-ec.crt", passphrase: []byte("wrong"), wantErr: "unable to decrypt"},
+		{key: "client-pkcs8-v1.key", cert: "client.crt", passphrase: []byte(testPassphrase), wantErr: "only PBES2 is supported"},
+		{key: "client-ec.key", cert: "client.crt", wantErr: "does not match public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"/"+string(tt.passphrase), func(t *testing.T) {
+			cert, err := clientCertificate(readTestData(t, tt.cert), readTestData(t, tt.key), nil, tt.passphrase)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cert.Certificate) != 1 || cert.PrivateKey == nil {
+				t.Errorf("certificate = %d certificates, key %T", len(cert.Certificate), cert.PrivateKey)
+			}
+		})
+	}
+}
+
+func TestKeyPassphraseFor(t *testing.T) {
+	dir := t.TempDir()
+	passFile := writeFile(t, dir, "pass", []byte("from-file\r\nsecond line\n"))
+
+	t.Setenv("TEST_KEY_PASSPHRASE", "from-env")
+
+	tests := []struct {
+		name    string
+		config  TLSClientConfig
+		want    string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "literal first", config: TLSClientConfig{
+			KeyPassphrase: "literal", KeyPassphraseEnv: "TEST_KEY_PASSPHRASE", KeyPassphraseFile: passFile,
+		}, want: "literal"},
+		{name: "env before file", config: TLSClientConfig{
+			KeyPassphraseEnv: "TEST_KEY_PASSPHRASE", KeyPassphraseFile: passFile,
+		}, want: "from-env"},
+		{name: "first line of the file", config: TLSClientConfig{KeyPassphraseFile: passFile}, want: "from-file"},
+		{name: "unset env", config: TLSClientConfig{KeyPassphraseEnv: "TEST_KEY_PASSPHRASE_UNSET"}, wantErr: "is not set"},
+		{name: "missing file", config: TLSClientConfig{KeyPassphraseFile: filepath.Join(dir, "missing")}, wantErr: "unable to read"},
+	}
+
+	for _, tt := range tests {
+		got, err := keyPassphraseFor(&tt.config)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: passphrase = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestClientCertificateHandshake(t *testing.T) {
+	ca := newTestCA(t, "server ca")
+	dir := t.TempDir()
+
+	// the server only trusts the root, so the client must send the intermediate in the right place
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(readTestData(t, "root.crt"))
+
+	server := newTLSTestServer(t, ca, func(c *tls.Config) {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		c.ClientCAs = clientCAs
+	})
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"`))
+	})
+
+	rsaChain := writeFile(t, dir, "rsa-chain.crt", append(readTestData(t, "client.crt"), readTestData(t, "intermediate.crt")...))
+	ecChain := writeFile(t, dir, "ec-chain.crt", append(readTestData(t, "client-ec.crt"), readTestData(t, "intermediate.crt")...))
+	passFile := writeFile(t, dir, "pass", []byte(testPassphrase+"\n"))
+	testdata := filepath.Join("testdata", "tls")
+
+	t.Setenv("TEST_KEY_PASSPHRASE", testPassphrase)
+
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		want    string
+		wantErr string
+	}{
+		{name: "PKCS#12 file", config: func(c *Config) {
+			c.PKCS12File = filepath.Join(testdata, "client-aes.p12")
+			c.KeyPassphrase = testPassphrase
+		}, want: "rsa client"},
+		{name: "legacy PKCS#12 data", config: func(c *Config) {
+			c.PKCS12Data = []byte(base64.StdEncoding.EncodeToString(readTestData(t, "client-legacy-3des.p12")))
+			c.KeyPassphraseEnv = "TEST_KEY_PASSPHRASE"
+		}, want: "rsa client"},
+		{name: "EC PKCS#12 file", config: func(c *Config) {
+			c.PKCS12File = filepath.Join(testdata, "client-ec-aes128.p12")
+			c.KeyPassphraseFile = passFile
+		}, want: "ec client"},
+		{name: "encrypted PKCS#8 key", config: func(c *Config) {
+			c.CertFile = rsaChain
+			c.KeyFile = filepath.Join(testdata, "client-pkcs8-aes256.key")
+			c.KeyPassphraseFile = passFile
+		}, want: "rsa client"},
+		{name: "legacy encrypted EC key", config: func(c *Config) {
+			c.CertFile = ecChain
+			c.KeyFile = filepath.Join(testdata, "client-ec-legacy-aes256.key")
+			c.KeyPassphrase = testPassphrase
+		}, want: "ec client"},
+		{name: "wrong passphrase", config: func(c *Config) {
+			c.PKCS12File = filepath.Join(testdata, "client-aes.p12")
+			c.KeyPassphrase = "wrong"
+		}, wantErr: "incorrect passphrase"},
+		{name: "missing passphrase", config: func(c *Config) {
+			c.CertFile = rsaChain
+			c.KeyFile = filepath.Join(testdata, "client-pkcs8-3des.key")
+		}, wantErr: "no passphrase was given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tlsTestConfig(server)
+			tt.config(config)
+
+			client, err := RESTClientFor(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RESTClientFor error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := client.Get().Resource("whoami").Do(context.Background()).Raw()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Trim(string(body), `"`); got != tt.want {
+				t.Errorf("server saw client %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// publicKeyPinPrefix is the prefix of a public key pin, as in HTTP Public Key Pinning.
+const publicKeyPinPrefix = "sha256/"
+
+// ParseTLSVersion
+// - parse a TLS version such as 1.2 or 1.3
+// - versions older than TLS 1.2 are rejected, they are considered insecure
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(version), "TLS") {
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	case "1.0", "10", "1.1", "11":
+		return 0, fmt.Errorf("TLS version %q is insecure, the minimum supported version is 1.2", version)
+	}
+
+	return 0, fmt.Errorf("unknown TLS version %q, must be 1.2 or 1.3", version)
+}
+
+// ParseCipherSuites
+// - parse cipher suite names, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, into their IDs
+// - only the suites of tls.CipherSuites are accepted, the insecure ones are rejected
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	supported := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+
+	for _, name := range names {
+		id, ok := supported[name]
+
+		switch {
+		case ok:
+			ids = append(ids, id)
+		case insecure[name]:
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		default:
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+	}
+
+	return ids, nil
+}
+
+// PublicKeyPin
+// - return the pin of the public key of a certificate: sha256/ followed by the base64
+// encoded SHA-256 digest of its DER-encoded SubjectPublicKeyInfo
+// - it can also be computed with:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der |
+//	  openssl dgst -sha256 -binary | base64
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return publicKeyPinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// spkiPins is a set of SHA-256 digests of SubjectPublicKeyInfo.
+type spkiPins map[[sha256.Size]byte]bool
+
+// parsePublicKeyPins parses pins as returned by PublicKeyPin.
+func parsePublicKeyPins(pins []string) (spkiPins, error) {
+	if len(pins) == 0 {
+		return nil, nil
+	}
+
+	set := make(spkiPins, len(pins))
+
+	for _, pin := range pins {
+		if !strings.HasPrefix(pin, publicKeyPinPrefix) {
+			return nil, fmt.Errorf("invalid public key pin %q, must start with %s", pin, publicKeyPinPrefix)
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, publicKeyPinPrefix))
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid public key pin %q, must be a base64 encoded SHA-256 digest", pin)
+		}
+
+		var key [sha256.Size]byte
+		copy(key[:], sum)
+		set[key] = true
+	}
+
+	return set, nil
+}
+
+// ValidatePublicKeyPins returns an error if one of the pins is not formatted as returned by PublicKeyPin.
+func ValidatePublicKeyPins(pins []string) error {
+	_, err := parsePublicKeyPins(pins)
+
+	return err
+}
+
+// verify succeeds if the public key of one of the certificates is pinned.
+func (p spkiPins) verify(certs []*x509.Certificate) error {
+	for _, cert := range certs {
+		if p[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("tls: none of the server certificates matches a pinned public key")
+}
+
+// verifyConnection
+// - return a tls.Config.VerifyConnection function checking the pins against the verified chains
+// - when crypto/tls did not verify the chain, because of the insecure flag, only the server
+// certificate itself is checked: the rest of the chain it sent proves nothing
+func (p spkiPins) verifyConnection(cs tls.ConnectionState) error {
+	return p.verify(pinnableCertificates(cs.PeerCertificates, cs.VerifiedChains))
+}
+
+// pinnableCertificates returns the certificates of the verified chains, or the server certificate alone.
+func pinnableCertificates(peer []*x509.Certificate, chains [][]*x509.Certificate) []*x509.Certificate {
+	if len(chains) == 0 {
+		if len(peer) == 0 {
+			return nil
+		}
+
+		return peer[:1]
+	}
+
+	var certs []*x509.Certificate
+	for _, chain := range chains {
+		certs = append(certs, chain...)
+	}
+
+	return certs
+}
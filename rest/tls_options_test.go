@@ -0,0 +1,274 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		wantErr string
+	}{
+		{version: "1.2", want: tls.VersionTLS12},
+		{version: "TLS1.3", want: tls.VersionTLS13},
+		{version: "tls13", want: tls.VersionTLS13},
+		{version: "1.1", wantErr: "insecure"},
+		{version: "1.0", wantErr: "insecure"},
+		{version: "2.0", wantErr: "unknown TLS version"},
+		{version: "", wantErr: "unknown TLS version"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.version)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTLSVersion(%q) error = %v, want %q", tt.version, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %x, %v, want %x", tt.version, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		names   []string
+		want    []uint16
+		wantErr string
+	}{
+		{names: nil, want: nil},
+		{
+			names: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{names: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: "insecure"},
+		{names: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_NOPE"}, wantErr: "unknown cipher suite TLS_NOPE"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCipherSuites(tt.names)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseCipherSuites(%v) error = %v, want %q", tt.names, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || len(got) != len(tt.want) {
+			t.Fatalf("ParseCipherSuites(%v) = %v, %v, want %v", tt.names, got, err, tt.want)
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseCipherSuites(%v) = %v, want %v", tt.names, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestValidatePublicKeyPins(t *testing.T) {
+	valid := "sha256/" + base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		pins    []string
+		wantErr string
+	}{
+		{pins: nil},
+		{pins: []string{valid}},
+		{pins: []string{valid, "sha1/" + base64.StdEncoding.EncodeToString(make([]byte, 20))}, wantErr: "must start with sha256/"},
+		{pins: []string{"sha256/not base64"}, wantErr: "base64 encoded SHA-256 digest"},
+		{pins: []string{"sha256/" + base64.StdEncoding.EncodeToString(make([]byte, 20))}, wantErr: "base64 encoded SHA-256 digest"},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePublicKeyPins(tt.pins)
+		if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("ValidatePublicKeyPins(%v) = %v, want %q", tt.pins, err, tt.wantErr)
+		}
+	}
+}
+
+// newTLSTestServer starts a TLS server presenting a certificate issued by ca, configured by configure.
+func newTLSTestServer(t *testing.T, ca *testCA, configure func(*tls.Config)) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.serverCertificate(t)}}
+
+	if configure != nil {
+		configure(server.TLS)
+	}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestTLSOptionsHandshake(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	other := newTestCA(t, "other")
+	caData := []byte(base64.StdEncoding.EncodeToString(ca.pem))
+
+	server := newTLSTestServer(t, ca, nil)
+	tls12Server := newTLSTestServer(t, ca, func(c *tls.Config) {
+		c.MaxVersion = tls.VersionTLS12
+		c.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+	})
+
+	leaf := server.Certificate()
+	leafPin := PublicKeyPin(leaf)
+	caPin := PublicKeyPin(ca.cert)
+	otherPin := PublicKeyPin(other.cert)
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		config  func(*Config)
+		wantErr string
+	}{
+		{name: "CA data", config: func(c *Config) { c.CAData = caData }},
+		{name: "untrusted", config: func(c *Config) {
+			c.CAData = []byte(base64.StdEncoding.EncodeToString(other.pem))
+		}, wantErr: "certificate"},
+		{name: "CA appended to the system roots", config: func(c *Config) {
+			c.CAData = caData
+			c.AppendSystemRoots = true
+		}},
+		{name: "server certificate pinned", config: func(c *Config) {
+			c.CAData = caData
+			c.PinnedPublicKeys = []string{otherPin, leafPin}
+		}},
+		{name: "CA pinned", config: func(c *Config) {
+			c.CAData = caData
+			c.PinnedPublicKeys = []string{caPin}
+		}},
+		{name: "no pin matches", config: func(c *Config) {
+			c.CAData = caData
+			c.PinnedPublicKeys = []string{otherPin}
+		}, wantErr: "pinned public key"},
+		{name: "insecure with the server certificate pinned", config: func(c *Config) {
+			c.Insecure = true
+			c.PinnedPublicKeys = []string{leafPin}
+		}},
+		{name: "insecure with only the unverified CA pinned", config: func(c *Config) {
+			c.Insecure = true
+			c.PinnedPublicKeys = []string{caPin}
+		}, wantErr: "pinned public key"},
+		{name: "reloaded CA file with a pin", config: func(c *Config) {
+			c.CAFile = writeFile(t, t.TempDir(), "ca.crt", ca.pem)
+			c.ReloadInterval = time.Minute
+			c.PinnedPublicKeys = []string{caPin}
+		}},
+		{name: "reloaded CA file with no pin matching", config: func(c *Config) {
+			c.CAFile = writeFile(t, t.TempDir(), "ca.crt", ca.pem)
+			c.ReloadInterval = time.Minute
+			c.PinnedPublicKeys = []string{otherPin}
+		}, wantErr: "pinned public key"},
+		{name: "TLS 1.2 server", server: tls12Server, config: func(c *Config) { c.CAData = caData }},
+		{name: "minimum version above the server", server: tls12Server, config: func(c *Config) {
+			c.CAData = caData
+			c.MinVersion = tls.VersionTLS13
+		}, wantErr: "protocol version"},
+		{name: "matching cipher suite", server: tls12Server, config: func(c *Config) {
+			c.CAData = caData
+			c.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+		}},
+		{name: "no common cipher suite", server: tls12Server, config: func(c *Config) {
+			c.CAData = caData
+			c.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256}
+		}, wantErr: "handshake failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server
+			if tt.server != nil {
+				s = tt.server
+			}
+
+			config := testConfig(s.URL)
+			tt.config(config)
+
+			err := newTestClient(t, config).Get().Resource("users").Do(context.Background()).Error()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("request error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSConfigForErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		wantErr string
+	}{
+		{name: "old TLS version", config: func(c *Config) {
+			c.Insecure = true
+			c.MinVersion = tls.VersionTLS11
+		}, wantErr: "older than 1.2"},
+		{name: "invalid pin", config: func(c *Config) {
+			c.Insecure = true
+			c.PinnedPublicKeys = []string{"md5/abc"}
+		}, wantErr: "invalid public key pin"},
+		{name: "CA with the insecure flag", config: func(c *Config) {
+			c.Insecure = true
+			c.CAData = []byte("Y2E=")
+		}, wantErr: "insecure flag"},
+		{name: "no certificate in the CA data", config: func(c *Config) {
+			c.CAData = []byte(base64.StdEncoding.EncodeToString([]byte("not a certificate")))
+		}, wantErr: "no valid PEM certificate"},
+	}
+
+	for _, tt := range tests {
+		config := testConfig("https://elmt.example.com")
+		tt.config(config)
+
+		if _, err := TLSConfigFor(config); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: TLSConfigFor error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPublicKeyPin(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	pin := PublicKeyPin(ca.cert)
+	if !strings.HasPrefix(pin, "sha256/") || ValidatePublicKeyPins([]string{pin}) != nil {
+		t.Fatalf("PublicKeyPin = %q", pin)
+	}
+
+	pins, _ := parsePublicKeyPins([]string{pin})
+	if err := pins.verify([]*x509.Certificate{ca.cert}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	if err := pins.verify([]*x509.Certificate{newTestCA(t, "other").cert}); err == nil {
+		t.Errorf("verify succeeded with another key")
+	}
+}
@@ -0,0 +1,333 @@
+package rest
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// decodePKCS12
+// - return the certificates and the private key of a PKCS#12 (.p12, .pfx) bundle
+// - bundles using the legacy encryption (RC2, 3DES and a SHA-1 MAC) are decoded by
+// golang.org/x/crypto/pkcs12, the ones using PBES2 and a SHA-2 MAC, which OpenSSL 3 produces
+// by default, by decodePBES2PKCS12
+func decodePKCS12(data, passphrase []byte) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	blocks, err := pkcs12.ToPEM(data, string(passphrase))
+
+	var notImplemented pkcs12.NotImplementedError
+
+	switch {
+	case errors.As(err, &notImplemented):
+		return decodePBES2PKCS12(data, passphrase)
+	case errors.Is(err, pkcs12.ErrIncorrectPassword), errors.Is(err, pkcs12.ErrDecryption):
+		return nil, nil, fmt.Errorf("incorrect passphrase")
+	case err != nil:
+		return nil, nil, err
+	}
+
+	var (
+		certs []*x509.Certificate
+		key   crypto.PrivateKey
+	)
+
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			// Despite the block type, the key is PKCS#1 encoded for RSA and SEC 1 encoded for ECDSA
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+				continue
+			}
+
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("unsupported private key: %v", err)
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, fmt.Errorf("no private key found")
+	}
+
+	return certs, key, nil
+}
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// decodePBES2PKCS12 decodes a PKCS#12 bundle whose contents and keys are encrypted with PBES2.
+func decodePBES2PKCS12(data, passphrase []byte) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	var p pfx
+	if _, err := asn1.Unmarshal(data, &p); err != nil {
+		return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+
+	if !p.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, fmt.Errorf("only password-protected PKCS#12 data is supported")
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+
+	if len(p.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12MAC(&p.MacData, authSafe, passphrase); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+
+	var (
+		certs []*x509.Certificate
+		key   crypto.PrivateKey
+	)
+
+	for _, ci := range contents {
+		var safeContents []byte
+
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+			}
+
+			var err error
+
+			safeContents, err = decryptPBES2(ed.EncryptedContentInfo.ContentEncryptionAlgorithm,
+				ed.EncryptedContentInfo.EncryptedContent, passphrase)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported PKCS#12 content type %v", ci.ContentType)
+		}
+
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+		}
+
+		for _, bag := range bags {
+			var err error
+
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err = asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil || !cb.ID.Equal(oidCertTypeX509) {
+					return nil, nil, fmt.Errorf("unsupported PKCS#12 certificate bag")
+				}
+
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				der, err := decryptPKCS8(bag.Value.Bytes, passphrase)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+					return nil, nil, err
+				}
+			case bag.ID.Equal(oidKeyBag):
+				if key, err = x509.ParsePKCS8PrivateKey(bag.Value.Bytes); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, fmt.Errorf("no private key found")
+	}
+
+	return certs, key, nil
+}
+
+// verifyPKCS12MAC checks the integrity of the bundle, which also tells whether the passphrase is correct.
+func verifyPKCS12MAC(md *macData, message, passphrase []byte) error {
+	var (
+		newHash   func() hash.Hash
+		blockSize int
+	)
+
+	switch alg := md.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA1):
+		newHash, blockSize = sha1.New, sha1.BlockSize
+	case alg.Equal(oidSHA256):
+		newHash, blockSize = sha256.New, sha256.BlockSize
+	case alg.Equal(oidSHA384):
+		newHash, blockSize = sha512.New384, sha512.BlockSize
+	case alg.Equal(oidSHA512):
+		newHash, blockSize = sha512.New, sha512.BlockSize
+	default:
+		return fmt.Errorf("unsupported PKCS#12 MAC algorithm %v", alg)
+	}
+
+	key := pkcs12KDF(newHash, blockSize, bmpPassword(passphrase), md.MacSalt, 3, md.Iterations, newHash().Size())
+
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return fmt.Errorf("incorrect passphrase")
+	}
+
+	return nil
+}
+
+// bmpPassword encodes a passphrase as a NUL terminated big endian UTF-16 string, as required by PKCS#12.
+func bmpPassword(passphrase []byte) []byte {
+	units := utf16.Encode([]rune(string(passphrase)))
+
+	out := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF implements the key derivation function of PKCS#12, RFC 7292 appendix B.2.
+func pkcs12KDF(newHash func() hash.Hash, v int, password, salt []byte, id byte, iterations, size int) []byte {
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+
+		out := make([]byte, v*((len(data)+v-1)/v))
+		for i := range out {
+			out[i] = data[i%len(data)]
+		}
+
+		return out
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+
+	input := append(fill(salt), fill(password)...)
+
+	var out []byte
+
+	for len(out) < size {
+		h := newHash()
+		h.Write(d)
+		h.Write(input)
+		a := h.Sum(nil)
+
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(8v), where B is A repeated to v bytes
+		b := fill(a)[:v]
+		for j := 0; j < len(input); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+
+	return out[:size]
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "testpass"
+
+func readTestData(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "tls", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// chainNames returns the common names of the certificates of a tls.Certificate, in order.
+func chainNames(t *testing.T, cert *tls.Certificate) []string {
+	t.Helper()
+
+	names := make([]string, 0, len(cert.Certificate))
+
+	for _, der := range cert.Certificate {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, c.Subject.CommonName)
+	}
+
+	return names
+}
+
+func TestDecodePKCS12(t *testing.T) {
+	rsaChain := []string{"rsa client", "test intermediate", "test root"}
+	ecChain := []string{"ec client", "test intermediate", "test root"}
+
+	tests := []struct {
+		file       string
+		passphrase string
+		wantChain  []string
+		wantEC     bool
+		wantErr    string
+	}{
+		{file: "client-aes.p12", passphrase: testPassphrase, wantChain: rsaChain},
+		{file: "client-ec-aes128.p12", passphrase: testPassphrase, wantChain: ecChain, wantEC: true},
+		{file: "client-pbes2-3des.p12", passphrase: testPassphrase, wantChain: rsaChain},
+		{file: "client-legacy-3des.p12", passphrase: testPassphrase, wantChain: rsaChain},
+		{file: "client-aes.p12", passphrase: "wrong", wantErr: "incorrect passphrase"},
+		{file: "client-ec-aes128.p12", passphrase: "", wantErr: "incorrect passphrase"},
+		{file: "client-pbes2-3des.p12", passphrase: "wrong", wantErr: "incorrect passphrase"},
+		{file: "client-legacy-3des.p12", passphrase: "wrong", wantErr: "incorrect passphrase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+tt.passphrase, func(t *testing.T) {
+			cert, err := clientCertificate(nil, nil, readTestData(t, tt.file), []byte(tt.passphrase))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := chainNames(t, cert); strings.Join(got, ",") != strings.Join(tt.wantChain, ",") {
+				t.Errorf("chain = %q, want %q", got, tt.wantChain)
+			}
+
+			if cert.Leaf == nil || cert.Leaf.Subject.CommonName != tt.wantChain[0] {
+				t.Errorf("leaf = %v", cert.Leaf)
+			}
+
+			switch cert.PrivateKey.(type) {
+			case *ecdsa.PrivateKey:
+				if !tt.wantEC {
+					t.Errorf("got an EC key, want RSA")
+				}
+			case *rsa.PrivateKey:
+				if tt.wantEC {
+					t.Errorf("got an RSA key, want EC")
+				}
+			default:
+				t.Errorf("unexpected key type %T", cert.PrivateKey)
+			}
+		})
+	}
+}
+
+// tamperPKCS12MAC returns the bundle with one bit of its MAC digest flipped.
+func tamperPKCS12MAC(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var p pfx
+	if _, err := asn1.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	p.MacData.Mac.Digest[0] ^= 1
+
+	out, err := asn1.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestDecodePKCS12Corrupted(t *testing.T) {
+	for _, file := range []string{"client-aes.p12", "client-legacy-3des.p12"} {
+		data := readTestData(t, file)
+
+		// the tampered bundle still decodes as ASN.1, only the MAC check fails
+		if _, err := clientCertificate(nil, nil, tamperPKCS12MAC(t, data), []byte(testPassphrase)); err == nil {
+			t.Errorf("%s: a bundle with a bad MAC was accepted", file)
+		}
+
+		for _, corrupted := range [][]byte{data[:len(data)/2], []byte("not a bundle"), append(data[:8:8], 0xff)} {
+			if _, err := clientCertificate(nil, nil, corrupted, []byte(testPassphrase)); err == nil {
+				t.Errorf("%s: corrupted data was accepted", file)
+			}
+		}
+	}
+}
+
+func TestCertificateChainOrder(t *testing.T) {
+	var (
+		certs []*x509.Certificate
+		key   interface{}
+	)
+
+	for _, name := range []string{"client.crt", "intermediate.crt", "root.crt"} {
+		cert, err := x509.ParseCertificate(pemBlock(t, readTestData(t, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(pemBlock(t, readTestData(t, "client.key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecCert, err := x509.ParseCertificate(pemBlock(t, readTestData(t, "client-ec.crt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, intermediate, root := certs[0], certs[1], certs[2]
+	want := "rsa client,test intermediate,test root"
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		want  string
+	}{
+		{name: "ordered", certs: []*x509.Certificate{leaf, intermediate, root}, want: want},
+		{name: "reversed", certs: []*x509.Certificate{root, intermediate, leaf}, want: want},
+		{name: "root first", certs: []*x509.Certificate{leaf, root, intermediate}, want: want},
+		{name: "intermediate first", certs: []*x509.Certificate{intermediate, leaf, root}, want: want},
+		{name: "no intermediate", certs: []*x509.Certificate{root, leaf}, want: "rsa client,test root"},
+		{
+			name:  "unrelated certificate last",
+			certs: []*x509.Certificate{ecCert, root, leaf, intermediate},
+			want:  "rsa client,test intermediate,test root,ec client",
+		},
+		{name: "no matching certificate", certs: []*x509.Certificate{ecCert, root}},
+	}
+
+	for _, tt := range tests {
+		cert, err := certificateChain(tt.certs, key)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: certificateChain succeeded", tt.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if got := strings.Join(chainNames(t, cert), ","); got != tt.want {
+			t.Errorf("%s: chain = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
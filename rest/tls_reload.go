@@ -0,0 +1,197 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// tlsFileReloader
+// - keep the client certificate and root CAs loaded from CertFile and KeyFile, or PKCS12File,
+// and CAFile
+// - the files are polled lazily: when a new connection is made and at least interval has
+// elapsed since the last check, so no goroutine is left running
+// - a failed reload keeps the previous certificates and is reported to onError
+type tlsFileReloader struct {
+	certFile   string
+	keyFile    string
+	pkcs12File string
+	caFile     string
+	interval   time.Duration
+	onError    func(error)
+
+	passphrase        []byte
+	appendSystemRoots bool
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	stamps    map[string]fileStamp
+	cert      *tls.Certificate
+	rootCAs   *x509.CertPool
+}
+
+// load reads every configured file and swaps the certificates in if all of them are valid.
+func (r *tlsFileReloader) load() error {
+	stamps := make(map[string]fileStamp)
+
+	read := func(file string) ([]byte, error) {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+		return ioutil.ReadFile(file)
+	}
+
+	var (
+		cert    *tls.Certificate
+		rootCAs *x509.CertPool
+	)
+
+	if len(r.pkcs12File) > 0 {
+		data, err := read(r.pkcs12File)
+		if err != nil {
+			return err
+		}
+
+		if cert, err = clientCertificate(nil, nil, data, r.passphrase); err != nil {
+			return fmt.Errorf("unable to load client certificate %s: %v", r.pkcs12File, err)
+		}
+	}
+
+	if len(r.certFile) > 0 && len(r.keyFile) > 0 {
+		certPEM, err := read(r.certFile)
+		if err != nil {
+			return err
+		}
+
+		keyPEM, err := read(r.keyFile)
+		if err != nil {
+			return err
+		}
+
+		if cert, err = clientCertificate(certPEM, keyPEM, nil, r.passphrase); err != nil {
+			return fmt.Errorf("unable to load client certificate %s and key %s: %v", r.certFile, r.keyFile, err)
+		}
+	}
+
+	if len(r.caFile) > 0 {
+		caPEM, err := read(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		rootCAs, err = rootCertPool(caPEM, r.appendSystemRoots)
+		if err != nil {
+			return fmt.Errorf("unable to load root certificates from %s: %v", r.caFile, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = cert
+	r.rootCAs = rootCAs
+	r.stamps = stamps
+
+	return nil
+}
+
+// maybeReload reloads the files if the poll interval elapsed and any of them changed on disk.
+func (r *tlsFileReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+
+	r.lastCheck = time.Now()
+	changed := false
+
+	for file, stamp := range r.stamps {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil && r.onError != nil {
+		r.onError(fmt.Errorf("reloading TLS files: %v", err))
+	}
+}
+
+// clientCertificate implements tls.Config.GetClientCertificate.
+func (r *tlsFileReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+
+	return r.cert, nil
+}
+
+// roots returns the current root CAs, reloading them first if needed.
+func (r *tlsFileReloader) roots() *x509.CertPool {
+	r.maybeReload()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rootCAs
+}
+
+// verifyPeerWithRoots
+// - return a tls.Config.VerifyConnection function checking the server chain against the pool
+// returned by roots at handshake time, which is how reloaded CAs apply to new connections
+// - it replaces the verification of crypto/tls, which is turned off by InsecureSkipVerify,
+// pins are then checked against the chains it built
+func verifyPeerWithRoots(serverName string, roots func() *x509.CertPool, pins spkiPins) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("tls: server did not present a certificate")
+		}
+
+		opts := x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         roots(),
+			Intermediates: x509.NewCertPool(),
+		}
+
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		chains, err := cs.PeerCertificates[0].Verify(opts)
+		if err != nil {
+			return err
+		}
+
+		if pins != nil {
+			return pins.verify(pinnableCertificates(cs.PeerCertificates, chains))
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,241 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rotatingServer is a TLS test server whose certificate may be replaced, answering with the
+// common name of the client certificate.
+type rotatingServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	cert tls.Certificate
+}
+
+func newRotatingServer(t *testing.T, ca *testCA) *rotatingServer {
+	t.Helper()
+
+	s := &rotatingServer{cert: ca.serverCertificate(t)}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "none"
+		if len(r.TLS.PeerCertificates) > 0 {
+			name = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+
+		_, _ = w.Write([]byte(`"` + name + `"`))
+	}))
+	s.TLS = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return &tls.Config{Certificates: []tls.Certificate{s.cert}, ClientAuth: tls.RequestClientCert}, nil
+		},
+	}
+	s.StartTLS()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *rotatingServer) rotate(t *testing.T, ca *testCA) {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cert = ca.serverCertificate(t)
+}
+
+// clientName sends a request on a new connection and returns the name of the client certificate
+// seen by the server.
+func clientName(client *RESTClient) (string, error) {
+	client.CloseIdleConnections()
+
+	body, err := client.Get().Resource("whoami").Do(context.Background()).Raw()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(string(body), `"`), nil
+}
+
+func TestTLSReload(t *testing.T) {
+	const interval = 10 * time.Millisecond
+
+	ca1 := newTestCA(t, "ca-1")
+	ca2 := newTestCA(t, "ca-2")
+
+	type step struct {
+		// rotate writes new files, and may rotate the certificate of the server
+		rotate   func(t *testing.T, dir string, server *rotatingServer)
+		want     string
+		wantErr  bool
+		reloadOK bool
+	}
+
+	writeClient := func(t *testing.T, dir, name string) {
+		certPEM, keyPEM := ca1.issue(t, name, false)
+		writeFile(t, dir, "client.crt", certPEM)
+		writeFile(t, dir, "client.key", keyPEM)
+	}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		steps    []step
+	}{
+		{
+			name:     "client certificate",
+			interval: interval,
+			steps: []step{
+				{rotate: func(t *testing.T, dir string, _ *rotatingServer) { writeClient(t, dir, "client-2") }, want: "client-2"},
+				{rotate: func(t *testing.T, dir string, _ *rotatingServer) { writeClient(t, dir, "client-3") }, want: "client-3"},
+			},
+		},
+		{
+			name:     "root CAs",
+			interval: interval,
+			steps: []step{
+				// the server moves to a certificate the client does not trust yet
+				{rotate: func(t *testing.T, _ string, server *rotatingServer) { server.rotate(t, ca2) }, wantErr: true},
+				{rotate: func(t *testing.T, dir string, _ *rotatingServer) { writeFile(t, dir, "ca.crt", ca2.pem) }, want: "client-1"},
+			},
+		},
+		{
+			name:     "invalid files keep the previous certificates",
+			interval: interval,
+			steps: []step{
+				{
+					rotate: func(t *testing.T, dir string, _ *rotatingServer) {
+						writeFile(t, dir, "client.key", []byte("not a key"))
+					},
+					want: "client-1", reloadOK: true,
+				},
+				{
+					rotate: func(t *testing.T, dir string, _ *rotatingServer) { writeFile(t, dir, "ca.crt", []byte("no CA")) },
+					want:   "client-1", reloadOK: true,
+				},
+				{rotate: func(t *testing.T, dir string, _ *rotatingServer) {
+					writeClient(t, dir, "client-2")
+					writeFile(t, dir, "ca.crt", ca1.pem)
+				}, want: "client-2"},
+			},
+		},
+		{
+			name: "no reload interval",
+			steps: []step{
+				{rotate: func(t *testing.T, dir string, _ *rotatingServer) { writeClient(t, dir, "client-2") }, want: "client-1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			server := newRotatingServer(t, ca1)
+
+			writeClient(t, dir, "client-1")
+			writeFile(t, dir, "ca.crt", ca1.pem)
+
+			var (
+				mu         sync.Mutex
+				reloadErrs []error
+			)
+
+			config := testConfig(server.URL)
+			config.CertFile = dir + "/client.crt"
+			config.KeyFile = dir + "/client.key"
+			config.CAFile = dir + "/ca.crt"
+			config.ReloadInterval = tt.interval
+			config.OnReloadError = func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				reloadErrs = append(reloadErrs, err)
+			}
+
+			client := newTestClient(t, config)
+
+			if name, err := clientName(client); err != nil || name != "client-1" {
+				t.Fatalf("first request: client %q, error %v", name, err)
+			}
+
+			for i, s := range tt.steps {
+				s.rotate(t, dir, server)
+				time.Sleep(2 * tt.interval)
+
+				mu.Lock()
+				reloadErrs = nil
+				mu.Unlock()
+
+				name, err := clientName(client)
+				if (err != nil) != s.wantErr {
+					t.Fatalf("step %d: error = %v, want error %v", i, err, s.wantErr)
+				}
+
+				if !s.wantErr && name != s.want {
+					t.Errorf("step %d: client certificate %q, want %q", i, name, s.want)
+				}
+
+				mu.Lock()
+				if s.reloadOK != (len(reloadErrs) > 0) {
+					t.Errorf("step %d: reload errors %v", i, reloadErrs)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+}
+
+func TestTLSConfigForKeepsTheConfig(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	dir := t.TempDir()
+	server := newRotatingServer(t, ca)
+
+	certPEM, keyPEM := ca.issue(t, "client-1", false)
+	certFile := writeFile(t, dir, "client.crt", certPEM)
+	keyFile := writeFile(t, dir, "client.key", keyPEM)
+	caData := []byte(base64.StdEncoding.EncodeToString(ca.pem))
+
+	config := testConfig(server.URL)
+	config.CertFile = certFile
+	config.KeyFile = keyFile
+	config.CAData = caData
+	config.ReloadInterval = time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		if _, err := TLSConfigFor(config); err != nil {
+			t.Fatalf("TLSConfigFor call %d: %v", i, err)
+		}
+
+		if _, err := TransportFor(config); err != nil {
+			t.Fatalf("TransportFor call %d: %v", i, err)
+		}
+	}
+
+	if len(config.CertData) != 0 || len(config.KeyData) != 0 || string(config.CAData) != string(caData) {
+		t.Fatalf("TLSConfigFor modified the data of the config")
+	}
+
+	// a client built from the same config afterwards still reloads the files
+	client := newTestClient(t, config)
+
+	certPEM, keyPEM = ca.issue(t, "client-2", false)
+	writeFile(t, dir, "client.crt", certPEM)
+	writeFile(t, dir, "client.key", keyPEM)
+	time.Sleep(10 * time.Millisecond)
+
+	if name, err := clientName(client); err != nil || name != "client-2" {
+		t.Errorf("client %q, error %v, want client-2", name, err)
+	}
+}
@@ -0,0 +1,259 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TraceParentHeader is the W3C trace context header carrying the trace and parent span ids.
+	TraceParentHeader = "traceparent"
+
+	// TraceStateHeader is the W3C trace context header carrying vendor specific trace data.
+	TraceStateHeader = "tracestate"
+)
+
+// TraceID is a W3C trace id.
+type TraceID [16]byte
+
+// SpanID is a W3C span (parent) id.
+type SpanID [8]byte
+
+// SpanContext identifies a span and carries the W3C trace context that is propagated to the server.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	TraceState string
+}
+
+// IsValid returns whether both the trace id and the span id are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// IsSampled returns whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&0x01 == 0x01
+}
+
+// TraceParent formats the span context as a version 00 traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.TraceFlags)
+}
+
+// ParseTraceParent
+// - parse a traceparent header value, such as 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+// - the tracestate value is attached as is
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+
+	// Version 00 has exactly four fields, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", traceParent)
+	}
+
+	var sc SpanContext
+
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace id in traceparent %q", traceParent)
+	}
+
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid parent id in traceparent %q", traceParent)
+	}
+
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace flags in traceparent %q", traceParent)
+	}
+
+	sc.TraceFlags = flags[0]
+	sc.TraceState = strings.TrimSpace(traceState)
+
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: all-zero id", traceParent)
+	}
+
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid length or case")
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+
+	return err
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying sc, so that it becomes the parent of the client spans.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Tracer starts the client span of every Request.Do call.
+type Tracer interface {
+	// Start creates a span as a child of the span context carried by ctx, if any, and returns
+	// a context carrying the new span context.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	// SpanContext returns the context injected into the outgoing request headers.
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Attributes set on the client spans.
+const (
+	AttributeVerb       = "http.method"
+	AttributeURL        = "http.url"
+	AttributeStatusCode = "http.status_code"
+	AttributeHost       = "net.peer.name"
+	AttributeResource   = "elmt.resource"
+	AttributeName       = "elmt.name"
+	AttributeRetries    = "elmt.retries"
+)
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext         { return SpanContext{} }
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) RecordError(error)                {}
+func (noopSpan) End()                             {}
+
+// NoopTracer is a Tracer that records nothing and injects no headers, it is used when Config.Tracer is not set.
+var NoopTracer Tracer = noopTracer{}
+
+// SpanData is the snapshot of an ended span handed to a SpanExporter.
+type SpanData struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanContext
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Err        error
+}
+
+// SpanExporter receives the spans ended by a tracer created with NewTracer.
+type SpanExporter interface {
+	ExportSpan(span SpanData)
+}
+
+// simpleTracer generates W3C ids and hands every ended span to an exporter.
+type simpleTracer struct {
+	exporter SpanExporter
+}
+
+// NewTracer
+// - return a Tracer which samples every trace and hands the ended spans to exporter
+// - a parent span context carried by the context keeps its trace id, flags and tracestate
+func NewTracer(exporter SpanExporter) Tracer {
+	return &simpleTracer{exporter: exporter}
+}
+
+// Start implements Tracer.
+func (t *simpleTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, hasParent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{TraceFlags: 0x01}
+	if hasParent {
+		sc.TraceID = parent.TraceID
+		sc.TraceFlags = parent.TraceFlags
+		sc.TraceState = parent.TraceState
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+
+	_, _ = rand.Read(sc.SpanID[:])
+
+	s := &simpleSpan{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Context:    sc,
+			Parent:     parent,
+			StartTime:  time.Now(),
+			Attributes: make(map[string]interface{}),
+		},
+	}
+
+	return ContextWithSpanContext(ctx, sc), s
+}
+
+type simpleSpan struct {
+	tracer *simpleTracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *simpleSpan) SpanContext() SpanContext {
+	return s.data.Context
+}
+
+func (s *simpleSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Attributes[key] = value
+}
+
+func (s *simpleSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Err = err
+}
+
+func (s *simpleSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+	s.data.EndTime = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(data)
+	}
+}
@@ -0,0 +1,180 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opsdata/common-base/pkg/runtime"
+	"github.com/opsdata/common-base/pkg/scheme"
+
+	"github.com/opsdata/elmt-sdk/rest"
+	"github.com/opsdata/elmt-sdk/tools/tracetest"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{traceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", wantSampled: true},
+		{traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{traceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{traceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{traceParent: "00-4bf92f3577b34da6-00f067aa0ba902b7-01", wantErr: true},
+		{traceParent: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		sc, err := rest.ParseTraceParent(tt.traceParent, "")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTraceParent(%q) error = %v, want error %v", tt.traceParent, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil && sc.IsSampled() != tt.wantSampled {
+			t.Errorf("ParseTraceParent(%q) sampled = %v, want %v", tt.traceParent, sc.IsSampled(), tt.wantSampled)
+		}
+	}
+}
+
+func TestRequestTracing(t *testing.T) {
+	var headers http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	parent, err := rest.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		resource  string
+		itemName  string
+		wantCode  int
+		wantState string
+		wantErr   bool
+	}{
+		{name: "root span", ctx: context.Background(), resource: "users", itemName: "alice", wantCode: 200},
+		{
+			name: "child of the caller span", ctx: rest.ContextWithSpanContext(context.Background(), parent),
+			resource: "users", itemName: "bob", wantCode: 200, wantState: "vendor=value",
+		},
+		{name: "error", ctx: context.Background(), resource: "users", itemName: "missing", wantCode: 404, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+
+			client, err := rest.RESTClientFor(&rest.Config{
+				Host:   server.URL,
+				Tracer: rest.NewTracer(recorder),
+				ContentConfig: rest.ContentConfig{
+					GroupVersion: &scheme.GroupVersion{Group: "test", Version: "v1"},
+					Negotiator:   runtime.NewSimpleClientNegotiator(),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Get().Resource(tt.resource).Name(tt.itemName).Do(tt.ctx).Error()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Do error = %v, want error %v", err, tt.wantErr)
+			}
+
+			spans := recorder.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("%d spans recorded, want 1", len(spans))
+			}
+
+			span := spans[0]
+			if span.Name != "ELMT GET users" {
+				t.Errorf("span name = %q", span.Name)
+			}
+
+			for key, want := range map[string]interface{}{
+				rest.AttributeVerb:       "GET",
+				rest.AttributeResource:   tt.resource,
+				rest.AttributeName:       tt.itemName,
+				rest.AttributeStatusCode: tt.wantCode,
+				rest.AttributeRetries:    0,
+				rest.AttributeHost:       strings.TrimPrefix(server.URL, "http://"),
+				rest.AttributeURL:        server.URL + "/v1/" + tt.resource + "/" + tt.itemName,
+			} {
+				if got := span.Attributes[key]; got != want {
+					t.Errorf("attribute %s = %v, want %v", key, got, want)
+				}
+			}
+
+			if (span.Err != nil) != tt.wantErr {
+				t.Errorf("span error = %v, want error %v", span.Err, tt.wantErr)
+			}
+
+			if got, want := headers.Get(rest.TraceParentHeader), span.Context.TraceParent(); got != want {
+				t.Errorf("traceparent = %q, want %q", got, want)
+			}
+
+			if got := headers.Get(rest.TraceStateHeader); got != tt.wantState {
+				t.Errorf("tracestate = %q, want %q", got, tt.wantState)
+			}
+
+			_, hasParent := rest.SpanContextFromContext(tt.ctx)
+			if hasParent {
+				if span.Context.TraceID != parent.TraceID || span.Parent != parent || span.Context.SpanID == parent.SpanID {
+					t.Errorf("span %+v is not a child of %+v", span.Context, parent)
+				}
+			} else if span.Parent.IsValid() || !span.Context.IsValid() || !span.Context.IsSampled() {
+				t.Errorf("root span context = %+v, parent %+v", span.Context, span.Parent)
+			}
+		})
+	}
+}
+
+func TestNoopTracerInjectsNothing(t *testing.T) {
+	var headers http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host: server.URL,
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &scheme.GroupVersion{Group: "test", Version: "v1"},
+			Negotiator:   runtime.NewSimpleClientNegotiator(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Get().Resource("users").Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := headers.Get(rest.TraceParentHeader); v != "" {
+		t.Errorf("traceparent = %q without a tracer", v)
+	}
+}
@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// The transport settings used when the corresponding Config fields are zero, they match
+// the DefaultTransport of net/http.
+const (
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// WrapperFunc wraps an http.RoundTripper when creating a new RESTClient.
+type WrapperFunc func(rt http.RoundTripper) http.RoundTripper
+
+// Wrappers accepts any number of wrappers and returns a wrapper function that is the equivalent
+// of calling each of them in order. Nil values are ignored, which makes this function convenient
+// for incrementally wrapping a function.
+func Wrappers(fns ...WrapperFunc) WrapperFunc {
+	if len(fns) == 0 {
+		return nil
+	}
+
+	// optimize the common case of wrapping a possibly nil transport wrapper
+	// with an additional wrapper
+	if len(fns) == 2 && fns[0] == nil {
+		return fns[1]
+	}
+
+	return func(rt http.RoundTripper) http.RoundTripper {
+		base := rt
+		for _, fn := range fns {
+			if fn != nil {
+				base = fn(base)
+			}
+		}
+
+		return base
+	}
+}
+
+// RoundTripperFunc allows an ordinary function to be used as an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// roundTripperFor
+// - return the round tripper the RESTClient sends its requests through, or nil when the
+// transport built by gorequest is used as is
+// - the layers of a request are, from the caller to the wire:
+//  1. Request.Do: tracing, rate limiting, metrics and logging, once per call
+//  2. the Authorization and Accept headers, set by NewRequest
+//  3. the gorequest retry loop, on server side errors
+//  4. Config.WrapTransport, which therefore sees every attempt with its final headers
+//  5. Config.Transport, or the transport built from the TLS, proxy and timeout settings
+func roundTripperFor(config *Config, base *http.Transport) (http.RoundTripper, error) {
+	if config.Transport == nil && config.WrapTransport == nil {
+		return nil, nil
+	}
+
+	var rt http.RoundTripper = base
+
+	if config.Transport != nil {
+		if config.hasTLSOptions() {
+			return nil, fmt.Errorf("using a custom transport with TLS certificate options or the insecure flag is not allowed")
+		}
+
+		if len(config.ProxyURL) > 0 {
+			return nil, fmt.Errorf("using a custom transport with a proxy url is not allowed")
+		}
+
+		if _, ok := unixSocketPath(config.Host); ok || config.Dial != nil {
+			return nil, fmt.Errorf("using a custom transport with a custom dial or a unix socket host is not allowed")
+		}
+
+		rt = config.Transport
+	}
+
+	if config.WrapTransport != nil {
+		rt = config.WrapTransport(rt)
+	}
+
+	return rt, nil
+}
+
+// TransportFor
+// - return the round tripper of the TLS, proxy and timeout settings of the config, or of
+// Config.Transport, wrapped by Config.WrapTransport, for the callers making their own requests to
+// the server, such as a reverse proxy
+// - the requests are sent as they are: neither the auth headers nor the retries of the RESTClient
+// are added, see ClientContentConfig.AuthorizationHeader
+func TransportFor(config *Config) (http.RoundTripper, error) {
+	tlsConfig, err := TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxyFuncFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &http.Transport{TLSClientConfig: tlsConfig, Proxy: proxy}
+	configureTransport(config, t)
+
+	rt, err := roundTripperFor(config, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt == nil {
+		return t, nil
+	}
+
+	return rt, nil
+}
+
+// configureTransport
+// - apply the connection pool, timeout and HTTP/2 settings of the config to the transport built by gorequest
+// - keep-alives are enabled unless DisableKeepAlives is set, whereas gorequest turns them off
+// - HTTP/2 is attempted as net/http does when there are no TLS settings, since setting DialContext
+// would otherwise turn it off, and with TLS settings only if EnableHTTP2 is set
+// - connections are made by Config.Dial if set, and go to the socket of a unix:// host whatever
+// the address of the request
+func configureTransport(config *Config, t *http.Transport) {
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(config.DialTimeout, DefaultDialTimeout),
+		KeepAlive: durationOrDefault(config.KeepAlive, DefaultKeepAlive),
+	}
+
+	dial := dialer.DialContext
+	if config.Dial != nil {
+		dial = config.Dial
+	}
+
+	t.DialContext = dial
+
+	if socketPath, ok := unixSocketPath(config.Host); ok {
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, "unix", socketPath)
+		}
+	}
+
+	t.TLSHandshakeTimeout = durationOrDefault(config.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout)
+	t.IdleConnTimeout = durationOrDefault(config.IdleConnTimeout, DefaultIdleConnTimeout)
+	t.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	t.MaxIdleConns = DefaultMaxIdleConns
+	t.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = config.MaxConnsPerHost
+	t.DisableKeepAlives = config.DisableKeepAlives
+	t.ForceAttemptHTTP2 = config.EnableHTTP2 || t.TLSClientConfig == nil
+
+	if config.MaxIdleConns > 0 {
+		t.MaxIdleConns = config.MaxIdleConns
+	}
+}
+
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+
+	return def
+}
+
+// CloseIdleConnections closes the idle connections kept by the transport of the RESTClient.
+// It does not interrupt any connections currently in use.
+func (c *RESTClient) CloseIdleConnections() {
+	if c.Client == nil {
+		return
+	}
+
+	if c.Client.Transport != nil {
+		c.Client.Transport.CloseIdleConnections()
+	}
+
+	// Let the round tripper installed from Config.Transport or WrapTransport release its own pool
+	if c.Client.Client != nil {
+		c.Client.Client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,406 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// tlsTestConfig returns a config trusting the certificate of the TLS test server.
+func tlsTestConfig(server *httptest.Server) *Config {
+	config := testConfig(server.URL)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	config.CAData = []byte(base64.StdEncoding.EncodeToString(ca))
+
+	return config
+}
+
+func TestWrapTransport(t *testing.T) {
+	var attempts int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"from":"server"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		transport    http.RoundTripper
+		wantBody     string
+		wantAttempts int
+	}{
+		{name: "built transport", wantBody: `{"from":"server"}`, wantAttempts: 2},
+		{
+			name: "custom transport",
+			transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"from":"transport"}`)),
+					Request:    req,
+				}, nil
+			}),
+			wantBody:     `{"from":"transport"}`,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = 0
+
+			var (
+				mu    sync.Mutex
+				seen  []string
+				inner http.RoundTripper
+			)
+
+			config := testConfig(server.URL)
+			config.BearerToken = "token"
+			config.MaxRetries = 1
+			config.RetryInterval = time.Millisecond
+			config.Transport = tt.transport
+			config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+				inner = rt
+				return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					seen = append(seen, req.Header.Get("Authorization"))
+					mu.Unlock()
+
+					return rt.RoundTrip(req)
+				})
+			}
+
+			client := newTestClient(t, config)
+
+			if tt.transport == nil && inner != http.RoundTripper(client.Client.Transport) {
+				t.Errorf("WrapTransport wraps %T, want the transport built from the config", inner)
+			}
+
+			body, err := client.Get().Resource("users").Do(context.Background()).Raw()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+
+			// the wrapper sees every attempt with its final headers
+			if len(seen) != tt.wantAttempts {
+				t.Errorf("wrapper saw %d attempts, want %d", len(seen), tt.wantAttempts)
+			}
+
+			for _, auth := range seen {
+				if auth != "Bearer token" {
+					t.Errorf("wrapper saw Authorization %q", auth)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+	}{
+		{name: "insecure", config: func(c *Config) { c.Insecure = true }},
+		{name: "CA data", config: func(c *Config) { c.CAData = []byte("Y2E=") }},
+		{name: "dial", config: func(c *Config) { c.Dial = (&net.Dialer{}).DialContext }},
+		{name: "unix socket", config: func(c *Config) { c.Host = "unix:///var/run/elmt.sock" }},
+	}
+
+	for _, tt := range tests {
+		config := testConfig("https://elmt.example.com")
+		config.Transport = http.DefaultTransport
+		tt.config(config)
+
+		if _, err := RESTClientFor(config); err == nil {
+			t.Errorf("%s: RESTClientFor succeeded with a custom transport", tt.name)
+		}
+	}
+}
+
+func TestTransportForWrapsTransport(t *testing.T) {
+	errWrapped := errors.New("wrapped")
+
+	config := testConfig("http://elmt.example.com")
+	config.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("unwrapped")
+	})
+	config.WrapTransport = func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errWrapped
+		})
+	}
+
+	rt, err := TransportFor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://elmt.example.com/v1/users", nil)
+	if _, err := rt.RoundTrip(req); err != errWrapped {
+		t.Errorf("RoundTrip error = %v, want the error of the wrapper", err)
+	}
+}
+
+func TestHTTP2Negotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		tls         bool
+		enableHTTP2 bool
+		wantForce   bool
+		wantProto   string
+	}{
+		{name: "no TLS settings", wantForce: true},
+		{name: "TLS settings", tls: true, wantProto: "HTTP/1.1"},
+		{name: "TLS settings with EnableHTTP2", tls: true, enableHTTP2: true, wantForce: true, wantProto: "HTTP/2.0"},
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"` + r.Proto + `"`))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(server.URL)
+			if tt.tls {
+				config = tlsTestConfig(server)
+			}
+
+			config.EnableHTTP2 = tt.enableHTTP2
+
+			client := newTestClient(t, config)
+			if got := client.Client.Transport.ForceAttemptHTTP2; got != tt.wantForce {
+				t.Errorf("ForceAttemptHTTP2 = %v, want %v", got, tt.wantForce)
+			}
+
+			if !tt.tls {
+				return
+			}
+
+			body, err := client.Get().Resource("proto").Do(context.Background()).Raw()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Trim(string(body), `"`); got != tt.wantProto {
+				t.Errorf("protocol = %s, want %s", got, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestConfigureTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+		want   *http.Transport
+	}{
+		{
+			name:   "defaults",
+			config: func(*Config) {},
+			want: &http.Transport{
+				TLSHandshakeTimeout: DefaultTLSHandshakeTimeout,
+				IdleConnTimeout:     DefaultIdleConnTimeout,
+				MaxIdleConns:        DefaultMaxIdleConns,
+				ForceAttemptHTTP2:   true,
+			},
+		},
+		{
+			name: "tuned",
+			config: func(c *Config) {
+				c.TLSHandshakeTimeout = time.Second
+				c.IdleConnTimeout = 2 * time.Second
+				c.ResponseHeaderTimeout = 3 * time.Second
+				c.MaxIdleConns = 10
+				c.MaxIdleConnsPerHost = 5
+				c.MaxConnsPerHost = 20
+				c.DisableKeepAlives = true
+			},
+			want: &http.Transport{
+				TLSHandshakeTimeout:   time.Second,
+				IdleConnTimeout:       2 * time.Second,
+				ResponseHeaderTimeout: 3 * time.Second,
+				MaxIdleConns:          10,
+				MaxIdleConnsPerHost:   5,
+				MaxConnsPerHost:       20,
+				DisableKeepAlives:     true,
+				ForceAttemptHTTP2:     true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig("http://elmt.example.com")
+			tt.config(config)
+
+			got := newTestClient(t, config).Client.Transport
+			if got.DialContext == nil {
+				t.Errorf("DialContext is not set")
+			}
+
+			for _, f := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"TLSHandshakeTimeout", got.TLSHandshakeTimeout, tt.want.TLSHandshakeTimeout},
+				{"IdleConnTimeout", got.IdleConnTimeout, tt.want.IdleConnTimeout},
+				{"ResponseHeaderTimeout", got.ResponseHeaderTimeout, tt.want.ResponseHeaderTimeout},
+				{"MaxIdleConns", got.MaxIdleConns, tt.want.MaxIdleConns},
+				{"MaxIdleConnsPerHost", got.MaxIdleConnsPerHost, tt.want.MaxIdleConnsPerHost},
+				{"MaxConnsPerHost", got.MaxConnsPerHost, tt.want.MaxConnsPerHost},
+				{"DisableKeepAlives", got.DisableKeepAlives, tt.want.DisableKeepAlives},
+				{"ForceAttemptHTTP2", got.ForceAttemptHTTP2, tt.want.ForceAttemptHTTP2},
+			} {
+				if f.got != f.want {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+
+			if copied := CopyConfig(config); copied.DisableKeepAlives != config.DisableKeepAlives ||
+				copied.MaxConnsPerHost != config.MaxConnsPerHost || copied.IdleConnTimeout != config.IdleConnTimeout {
+				t.Errorf("CopyConfig dropped the transport settings")
+			}
+		})
+	}
+}
+
+// connCounter counts the connections of a test server by state.
+type connCounter struct {
+	mu     sync.Mutex
+	states map[http.ConnState]int
+	closed chan struct{}
+}
+
+func newConnCounter(server *httptest.Server) *connCounter {
+	c := &connCounter{states: map[http.ConnState]int{}, closed: make(chan struct{}, 16)}
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		c.mu.Lock()
+		c.states[state]++
+		c.mu.Unlock()
+
+		if state == http.StateClosed {
+			c.closed <- struct{}{}
+		}
+	}
+
+	return c
+}
+
+func (c *connCounter) count(state http.ConnState) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.states[state]
+}
+
+func TestKeepAlives(t *testing.T) {
+	tests := []struct {
+		name              string
+		disableKeepAlives bool
+		wantConns         int
+	}{
+		{name: "reused by default", wantConns: 1},
+		{name: "disabled", disableKeepAlives: true, wantConns: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{}"))
+			}))
+			conns := newConnCounter(server)
+			server.Start()
+			defer server.Close()
+
+			config := testConfig(server.URL)
+			config.DisableKeepAlives = tt.disableKeepAlives
+			client := newTestClient(t, config)
+
+			for i := 0; i < 3; i++ {
+				if err := client.Get().Resource("users").Do(context.Background()).Error(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := conns.count(http.StateNew); got != tt.wantConns {
+				t.Errorf("%d connections opened, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
+
+func TestCloseIdleConnections(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap bool
+	}{
+		{name: "built transport"},
+		{name: "wrapped transport", wrap: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{}"))
+			}))
+			conns := newConnCounter(server)
+			server.Start()
+			defer server.Close()
+
+			config := testConfig(server.URL)
+			if tt.wrap {
+				config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+					return struct{ http.RoundTripper }{rt}
+				}
+			}
+
+			client := newTestClient(t, config)
+			if err := client.Get().Resource("users").Do(context.Background()).Error(); err != nil {
+				t.Fatal(err)
+			}
+
+			client.CloseIdleConnections()
+
+			select {
+			case <-conns.closed:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the idle connection was not closed")
+			}
+
+			// the client may still be used
+			if err := client.Get().Resource("users").Do(context.Background()).Error(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -4,15 +4,35 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/opsdata/common-base/pkg/scheme"
 )
 
+// unixSocketHost is the host of the requests sent over a unix domain socket, as the Host header
+// must not be empty.
+const unixSocketHost = "localhost"
+
 // DefaultServerURL
 // - convert a host, host:port, or URL string to the default base server API path
 // to use with a Client at a given API version following the standard conventions
 // for an ELMT API
+// - a unix:///path/to.sock host sends the requests over that unix domain socket, to
+// http://localhost, or https://localhost when defaultTLS is set
 func DefaultServerURL(host, apiPath string, groupVersion scheme.GroupVersion, defaultTLS bool) (*url.URL, string, error) {
+	if socketPath, ok := unixSocketPath(host); ok {
+		if len(socketPath) == 0 {
+			return nil, "", fmt.Errorf("unix socket host has no path: %q", host)
+		}
+
+		hostURL := &url.URL{Scheme: "http", Host: unixSocketHost}
+		if defaultTLS {
+			hostURL.Scheme = "https"
+		}
+
+		return hostURL, path.Join("/", apiPath, groupVersion.Version), nil
+	}
+
 	hostURL, err := url.Parse(host)
 
 	if err != nil || hostURL.Scheme == "" || hostURL.Host == "" {
@@ -47,6 +67,21 @@ func DefaultServerURL(host, apiPath string, groupVersion scheme.GroupVersion, de
 	return hostURL, versionedAPIPath, nil
 }
 
+// unixSocketPath returns the path of the socket of a unix:// host, and whether the host is one.
+func unixSocketPath(host string) (string, bool) {
+	if !strings.HasPrefix(host, "unix:") {
+		return "", false
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return strings.TrimPrefix(strings.TrimPrefix(host, "unix:"), "//"), true
+	}
+
+	// unix://relative.sock puts the path in the host
+	return u.Host + u.Path, true
+}
+
 // DefaultVersionedAPIPath constructs the default path for the given group version, assuming the given
 // API path, following the standard conventions of the ELMT API.
 func DefaultVersionedAPIPath(apiPath string, groupVersion scheme.GroupVersion) string {
@@ -75,3 +110,11 @@ func defaultServerURLFor(config *Config) (*url.URL, string, error) {
 
 	return DefaultServerURL(config.Host, config.APIPath, scheme.GroupVersion{}, defaultTLS)
 }
+
+// ServerURLFor returns the URL of the server of the config, the path of which, if any, prefixes
+// the path of every request. It requires Host and GroupVersion to be set prior to being called.
+func ServerURLFor(config *Config) (*url.URL, error) {
+	hostURL, _, err := defaultServerURLFor(config)
+
+	return hostURL, err
+}
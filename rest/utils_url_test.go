@@ -0,0 +1,267 @@
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opsdata/common-base/pkg/scheme"
+)
+
+func TestUnixSocketPath(t *testing.T) {
+	tests := []struct {
+		host     string
+		wantPath string
+		wantOK   bool
+	}{
+		{host: "unix:///var/run/elmt.sock", wantPath: "/var/run/elmt.sock", wantOK: true},
+		{host: "unix://relative.sock", wantPath: "relative.sock", wantOK: true},
+		{host: "unix:/var/run/elmt.sock", wantPath: "/var/run/elmt.sock", wantOK: true},
+		{host: "unix://", wantPath: "", wantOK: true},
+		{host: "http://elmt.example.com"},
+		{host: "elmt.example.com:8080"},
+		{host: "/var/run/elmt.sock"},
+	}
+
+	for _, tt := range tests {
+		path, ok := unixSocketPath(tt.host)
+		if path != tt.wantPath || ok != tt.wantOK {
+			t.Errorf("unixSocketPath(%q) = %q, %v, want %q, %v", tt.host, path, ok, tt.wantPath, tt.wantOK)
+		}
+	}
+}
+
+func TestDefaultServerURL(t *testing.T) {
+	gv := scheme.GroupVersion{Group: "test", Version: "v1"}
+
+	tests := []struct {
+		host       string
+		apiPath    string
+		defaultTLS bool
+		wantURL    string
+		wantPath   string
+		wantErr    bool
+	}{
+		{host: "http://127.0.0.1:8080", wantURL: "http://127.0.0.1:8080", wantPath: "/v1"},
+		{host: "unix:///var/run/elmt.sock", wantURL: "http://localhost", wantPath: "/v1"},
+		{host: "unix:///var/run/elmt.sock", apiPath: "api", defaultTLS: true, wantURL: "https://localhost", wantPath: "/api/v1"},
+		{host: "unix://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		u, versionedPath, err := DefaultServerURL(tt.host, tt.apiPath, gv, tt.defaultTLS)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("DefaultServerURL(%q) succeeded", tt.host)
+			}
+
+			continue
+		}
+
+		if err != nil || u.String() != tt.wantURL || versionedPath != tt.wantPath {
+			t.Errorf("DefaultServerURL(%q) = %v, %q, %v, want %s, %s", tt.host, u, versionedPath, err, tt.wantURL, tt.wantPath)
+		}
+	}
+}
+
+// serveUnix serves handler on a unix domain socket in a temp dir, over TLS if tlsConfig is set,
+// and returns the path of the socket.
+func serveUnix(t *testing.T, handler http.Handler, tlsConfig *tls.Config) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "elmt.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix domain sockets are not supported: %v", err)
+	}
+
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(l) }()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	return socket
+}
+
+func TestUnixSocketHost(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"` + r.Host + " " + r.URL.Path + `"`))
+	})
+
+	ca := newTestCA(t, "ca")
+	plain := serveUnix(t, handler, nil)
+	secure := serveUnix(t, handler, &tls.Config{Certificates: []tls.Certificate{ca.serverCertificate(t)}})
+
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		want    string
+		wantErr string
+	}{
+		{name: "plain", config: func(c *Config) { c.Host = "unix://" + plain }, want: "localhost /v1/users"},
+		{name: "TLS", config: func(c *Config) {
+			c.Host = "unix://" + secure
+			c.CAData = []byte(base64.StdEncoding.EncodeToString(ca.pem))
+		}, want: "localhost /v1/users"},
+		{name: "custom dial", config: func(c *Config) {
+			c.Host = "unix://" + plain
+			c.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				if network != "unix" || address != plain {
+					return nil, errors.New("unexpected dial " + network + " " + address)
+				}
+
+				return (&net.Dialer{}).DialContext(ctx, network, address)
+			}
+		}, want: "localhost /v1/users"},
+		{name: "proxy", config: func(c *Config) {
+			c.Host = "unix://" + plain
+			c.ProxyURL = "http://proxy.example.com:3128"
+		}, wantErr: "unix socket host"},
+		{name: "no path", config: func(c *Config) { c.Host = "unix://" }, wantErr: "has no path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the proxy variables of the environment never apply to a unix socket
+			t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+			t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+
+			config := testConfig("")
+			tt.config(config)
+
+			client, err := RESTClientFor(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RESTClientFor error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := client.Get().Resource("users").Do(context.Background()).Raw()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Trim(string(body), `"`); got != tt.want {
+				t.Errorf("server saw %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"` + r.Host + `"`))
+	}))
+	defer server.Close()
+
+	var (
+		mu    sync.Mutex
+		dials []string
+	)
+
+	errDial := errors.New("dial refused")
+
+	tests := []struct {
+		name    string
+		host    string
+		dial    func(ctx context.Context, network, address string) (net.Conn, error)
+		want    string
+		wantErr error
+	}{
+		{
+			name: "redirected",
+			host: "http://elmt.example.com",
+			dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				mu.Lock()
+				dials = append(dials, network+" "+address)
+				mu.Unlock()
+
+				return (&net.Dialer{}).DialContext(ctx, "tcp", server.Listener.Addr().String())
+			},
+			want: "elmt.example.com",
+		},
+		{
+			name: "error",
+			host: "http://elmt.example.com",
+			dial: func(context.Context, string, string) (net.Conn, error) {
+				return nil, errDial
+			},
+			wantErr: errDial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dials = nil
+
+			config := testConfig(tt.host)
+			config.Dial = tt.dial
+
+			body, err := newTestClient(t, config).Get().Resource("users").Do(context.Background()).Raw()
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Trim(string(body), `"`); got != tt.want {
+				t.Errorf("server saw host %q, want %q", got, tt.want)
+			}
+
+			if len(dials) != 1 || dials[0] != "tcp elmt.example.com:80" {
+				t.Errorf("dials = %v", dials)
+			}
+		})
+	}
+}
+
+func TestServerURLFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		want    string
+		wantErr bool
+	}{
+		{name: "URL", config: testConfig("http://elmt.example.com:8080"), want: "http://elmt.example.com:8080"},
+		{name: "path prefix", config: testConfig("https://elmt.example.com/elmt"), want: "https://elmt.example.com/elmt"},
+		{name: "unix socket", config: testConfig("unix:///var/run/elmt.sock"), want: "http://" + unixSocketHost},
+		{name: "unix socket without path", config: testConfig("unix://"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ServerURLFor(tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ServerURLFor() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if err == nil && got.String() != tt.want {
+			t.Errorf("%s: ServerURLFor() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
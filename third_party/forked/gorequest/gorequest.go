@@ -78,6 +78,7 @@ type SuperAgent struct {
 	RawString            string
 	Client               *http.Client
 	Transport            *http.Transport
+	RoundTripper         http.RoundTripper
 	Cookies              []*http.Cookie
 	Errors               []error
 	BasicAuth            struct{ Username, Password string }
@@ -222,6 +223,7 @@ func (s *SuperAgent) Clone() *SuperAgent {
 		RawString:            s.RawString,
 		Client:               s.Client,
 		Transport:            s.Transport,
+		RoundTripper:         s.RoundTripper,
 		Cookies:              shallowCopyCookies(s.Cookies),
 		Errors:               shallowCopyErrors(s.Errors),
 		BasicAuth:            s.BasicAuth,
@@ -614,6 +616,16 @@ func (s *SuperAgent) TLSClientConfig(config *tls.Config) *SuperAgent {
 	return s
 }
 
+// SetRoundTripper sets the http.RoundTripper used to send the requests instead of Transport.
+// It is meant for callers wrapping Transport with their own middlewares, settings made
+// afterwards through TLSClientConfig or Proxy only apply if the round tripper delegates to Transport.
+func (s *SuperAgent) SetRoundTripper(rt http.RoundTripper) *SuperAgent {
+	s.safeModifyHttpClient()
+	s.RoundTripper = rt
+	s.Client.Transport = rt
+	return s
+}
+
 // Proxy function accepts a proxy url string to setup proxy url for any request.
 // It provides a convenience way to setup proxy which have advantages over usual old ways.
 // One example is you might try to set `http_proxy` environment. This means you are setting proxy up for all the
@@ -1212,8 +1224,8 @@ func (s *SuperAgent) getResponseBytes() (Response, []byte, []error) {
 		return nil, nil, s.Errors
 	}
 
-	// Set Transport
-	if !DisableTransportSwap {
+	// Set Transport, unless a round tripper was installed on the client by SetRoundTripper
+	if !DisableTransportSwap && s.RoundTripper == nil {
 		s.Client.Transport = s.Transport
 	}
 
@@ -1400,7 +1412,7 @@ func (s *SuperAgent) MakeRequest() (*http.Request, error) {
 	}
 
 	if s.ctx != nil {
-		req.WithContext(s.ctx)
+		req = req.WithContext(s.ctx)
 	}
 
 	for k, vals := range s.Header {
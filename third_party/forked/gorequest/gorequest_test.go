@@ -2,6 +2,7 @@ package gorequest
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -239,6 +240,50 @@ func TestMakeRequest(t *testing.T) {
 	}
 }
 
+// Test that the context given to WithContext is the context of the request
+func TestMakeRequestWithContext(t *testing.T) {
+	type ctxKey struct{}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	req, err := New().Get("/").WithContext(ctx).MakeRequest()
+	if err != nil {
+		t.Fatalf("Expected nil error; got %q", err.Error())
+	}
+	if req.Context().Value(ctxKey{}) != "value" {
+		t.Errorf("Expected the request to carry the context given to WithContext")
+	}
+
+	req, err = New().Get("/").MakeRequest()
+	if err != nil {
+		t.Fatalf("Expected nil error; got %q", err.Error())
+	}
+	if req.Context() != context.Background() {
+		t.Errorf("Expected the background context without WithContext")
+	}
+
+	// a canceled context aborts the request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, errs := New().Get(ts.URL).WithContext(ctx).End()
+	if len(errs) == 0 {
+		t.Errorf("Expected an error for a canceled context")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the request to stop with its context; took %v", elapsed)
+	}
+}
+
 // testing for Get method
 func TestGet(t *testing.T) {
 	const case1_empty = "/"
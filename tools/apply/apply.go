@@ -0,0 +1,318 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	utilerrors "github.com/opsdata/errors"
+
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+)
+
+// DefaultPageSize is the number of objects fetched per List call, unless Options.PageSize is set.
+const DefaultPageSize = 500
+
+// Options defines how the objects are applied.
+type Options struct {
+	// DryRun computes the changes without making them.
+	DryRun bool
+
+	// Prune deletes the objects which were applied before and are no longer in the manifests.
+	// The objects created by other means, without LastAppliedAnnotation, are never pruned.
+	Prune bool
+
+	// PageSize is the number of objects fetched per List call.
+	PageSize int64
+}
+
+// Action is what apply does to an object.
+type Action string
+
+// Actions of a Change.
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "unchanged"
+)
+
+// Change is the action planned, or made, on an object.
+type Change struct {
+	Kind   string
+	Name   string
+	Source string
+	Action Action
+
+	// Diff are the fields which change, as "- path: value" and "+ path: value" lines.
+	Diff []string
+
+	// Applied is true once the change was made, Err is set if it failed.
+	Applied bool
+	Err     error
+
+	// fields are the fields sent to the server
+	fields map[string]interface{}
+}
+
+// String returns the kind and the name of the changed object.
+func (c *Change) String() string {
+	return c.Kind + "/" + c.Name
+}
+
+/*
+ * Applier:
+ * - make the users, secrets and policies of the server match the objects of manifests
+ * - the fields of an object are merged into the live object, so that the fields set by the server
+ * are kept, and the fields last applied but no longer in the manifest are removed
+ */
+
+type Applier struct {
+	resources map[string]resource
+	options   Options
+}
+
+// New
+// - create an Applier working with the users, secrets and policies of client
+//
+// - 示例:
+//
+//	objects, err := apply.Load("manifests/")
+//	applier := apply.New(clientset.Elmt().APIV1(), apply.Options{DryRun: true})
+//	changes, err := applier.Apply(ctx, objects)
+//	apply.PrintChanges(os.Stdout, changes, true)
+func New(client apiv1.APIV1Interface, options Options) *Applier {
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	return &Applier{
+		resources: newResources(client),
+		options:   options,
+	}
+}
+
+// Apply
+// - compute the changes needed for the server to match the objects, and make them unless DryRun is set
+// - the objects are created or updated in the order of Kinds, and pruned in the reverse order
+// - a failed change does not stop the others, the returned error aggregates the failures
+func (a *Applier) Apply(ctx context.Context, objects []*Object) ([]*Change, error) {
+	changes, err := a.plan(ctx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.options.DryRun {
+		return changes, nil
+	}
+
+	var errs []error
+
+	for _, change := range changes {
+		if change.Action == ActionUnchanged {
+			continue
+		}
+
+		resource := a.resources[change.Kind]
+
+		switch change.Action {
+		case ActionCreate:
+			change.Err = resource.create(ctx, change.fields)
+		case ActionUpdate:
+			change.Err = resource.update(ctx, change.fields)
+		case ActionDelete:
+			change.Err = resource.delete(ctx, change.Name)
+		}
+
+		if change.Err != nil {
+			errs = append(errs, fmt.Errorf("unable to %s %s: %v", change.Action, change, change.Err))
+			continue
+		}
+
+		change.Applied = true
+	}
+
+	return changes, utilerrors.NewAggregate(errs)
+}
+
+// plan returns the changes of the objects, followed by the objects to prune.
+func (a *Applier) plan(ctx context.Context, objects []*Object) ([]*Change, error) {
+	objects = append([]*Object(nil), objects...)
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindOrder(objects[i].Kind) < kindOrder(objects[j].Kind)
+	})
+
+	lives := make(map[string]map[string]map[string]interface{})
+
+	for _, kind := range Kinds {
+		if !a.options.Prune && !hasKind(objects, kind) {
+			continue
+		}
+
+		live, err := a.resources[kind].list(ctx, a.options.PageSize)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the %s objects: %v", kind, err)
+		}
+
+		lives[kind] = live
+	}
+
+	changes := make([]*Change, 0, len(objects))
+	applied := make(map[string]bool, len(objects))
+
+	for _, object := range objects {
+		if _, ok := a.resources[object.Kind]; !ok {
+			return nil, fmt.Errorf("%s: unsupported kind %q", object.Source, object.Kind)
+		}
+
+		change, err := planObject(object, lives[object.Kind][object.Name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", object.Source, err)
+		}
+
+		changes = append(changes, change)
+		applied[object.String()] = true
+	}
+
+	if !a.options.Prune {
+		return changes, nil
+	}
+
+	for i := len(Kinds) - 1; i >= 0; i-- {
+		kind := Kinds[i]
+
+		names := make([]string, 0, len(lives[kind]))
+		for name := range lives[kind] {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, managed := lastApplied(lives[kind][name]); !managed || applied[kind+"/"+name] {
+				continue
+			}
+
+			changes = append(changes, &Change{Kind: kind, Name: name, Action: ActionDelete})
+		}
+	}
+
+	return changes, nil
+}
+
+// planObject compares an object with its live version, which is nil if it does not exist yet.
+func planObject(object *Object, live map[string]interface{}) (*Change, error) {
+	change := &Change{
+		Kind:   object.Kind,
+		Name:   object.Name,
+		Source: object.Source,
+	}
+
+	if live == nil {
+		applied, err := lastAppliedFields(object.Fields, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		fields, err := withLastApplied(object.Fields, applied)
+		if err != nil {
+			return nil, err
+		}
+
+		change.Action = ActionCreate
+		change.Diff = diff(nil, object.Fields)
+		change.fields = fields
+
+		return change, nil
+	}
+
+	last, _ := lastApplied(live)
+
+	applied, err := lastAppliedFields(object.Fields, last)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := merge(live, last, object.Fields)
+	keepWriteOnly(object.Kind, merged, live, last, applied)
+
+	merged, err = withLastApplied(merged, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := normalize(object.Kind, live)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := normalize(object.Kind, merged)
+	if err != nil {
+		return nil, err
+	}
+
+	change.Diff = diff(before, after)
+	change.fields = merged
+
+	if len(change.Diff) > 0 || !reflect.DeepEqual(last, applied) {
+		change.Action = ActionUpdate
+	} else {
+		change.Action = ActionUnchanged
+	}
+
+	return change, nil
+}
+
+func hasKind(objects []*Object, kind string) bool {
+	for _, object := range objects {
+		if object.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrintChanges
+// - write a line per change, telling what was done, or what would be done for a dry run
+// - showDiff also writes the changed fields of the created and updated objects
+func PrintChanges(w io.Writer, changes []*Change, showDiff bool) error {
+	for _, change := range changes {
+		var status string
+
+		switch {
+		case change.Err != nil:
+			status = fmt.Sprintf("%s failed: %v", change.Action, change.Err)
+		case change.Action == ActionUnchanged:
+			status = "unchanged"
+		case change.Applied:
+			status = pastTense[change.Action]
+		default:
+			status = pastTense[change.Action] + " (dry run)"
+		}
+
+		if _, err := fmt.Fprintf(w, "%s %s\n", change, status); err != nil {
+			return err
+		}
+
+		if !showDiff {
+			continue
+		}
+
+		for _, line := range change.Diff {
+			if _, err := fmt.Fprintf(w, "    %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var pastTense = map[Action]string{
+	ActionCreate: "created",
+	ActionUpdate: "configured",
+	ActionDelete: "pruned",
+}
@@ -0,0 +1,4 @@
+package apply
+
+// package apply
+// - create, update and prune the users, secrets and policies of an ELMT server from manifest files
@@ -0,0 +1,276 @@
+package apply
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LastAppliedAnnotation is the key of metadata.extend recording the fields last applied to an object,
+// as a JSON string. It tells which fields were removed from the manifest since, and which objects
+// are managed by apply and may be pruned. The sensitive fields are recorded as bcrypt hashes.
+const LastAppliedAnnotation = "elmt/last-applied-configuration"
+
+// writeOnlyFields are the fields the server does not return as they were set, such as the hashed
+// password of a user. They are only sent when they changed since they were last applied.
+var writeOnlyFields = map[string][]string{
+	KindUser: {"password"},
+}
+
+// sensitiveFields are the fields whose values are never printed in a diff, nor recorded in
+// LastAppliedAnnotation.
+var sensitiveFields = map[string]bool{
+	"password":   true,
+	"secret_key": true,
+}
+
+// nameOf returns metadata.name of the fields of an object.
+func nameOf(fields map[string]interface{}) string {
+	metadata, _ := fields["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+
+	return name
+}
+
+// lastApplied returns the fields recorded by LastAppliedAnnotation, ok is false if there are none.
+func lastApplied(fields map[string]interface{}) (last map[string]interface{}, ok bool) {
+	metadata, _ := fields["metadata"].(map[string]interface{})
+	extend, _ := metadata["extend"].(map[string]interface{})
+
+	value, ok := extend[LastAppliedAnnotation].(string)
+	if !ok || json.Unmarshal([]byte(value), &last) != nil {
+		return nil, false
+	}
+
+	return last, true
+}
+
+// lastAppliedFields
+// - return the fields to record in LastAppliedAnnotation for desired, where the sensitive fields are
+// replaced by a hash, as the annotation is returned to whoever can read the object
+// - the hash recorded in last is kept while it matches, so that the result can be compared with
+// last to tell whether the desired fields changed
+func lastAppliedFields(desired, last map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(desired))
+
+	for key, value := range desired {
+		var err error
+
+		if m, ok := value.(map[string]interface{}); ok {
+			result[key], err = lastAppliedFields(m, asMap(last[key]))
+		} else if sensitiveFields[key] {
+			result[key], err = hashSecret(value, last[key])
+		} else {
+			result[key] = value
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// hashSecret
+// - return the bcrypt hash of a sensitive value, or recorded if it is already a hash of it
+// - the value is hashed with SHA-256 first, as bcrypt only reads the first 72 bytes
+// - the empty values and the values which are not strings are kept, they disclose nothing
+func hashSecret(value, recorded interface{}) (interface{}, error) {
+	secret, ok := value.(string)
+	if !ok || len(secret) == 0 {
+		return value, nil
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	digest := []byte(hex.EncodeToString(sum[:]))
+
+	if hash, ok := recorded.(string); ok && bcrypt.CompareHashAndPassword([]byte(hash), digest) == nil {
+		return hash, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(digest, bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(hash), nil
+}
+
+// withLastApplied returns a copy of fields recording applied, see lastAppliedFields, in LastAppliedAnnotation.
+func withLastApplied(fields, applied map[string]interface{}) (map[string]interface{}, error) {
+	value, err := json.Marshal(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	result := copyMap(fields)
+	metadata := copyMap(asMap(result["metadata"]))
+	extend := copyMap(asMap(metadata["extend"]))
+
+	extend[LastAppliedAnnotation] = string(value)
+	metadata["extend"] = extend
+	result["metadata"] = metadata
+
+	return result, nil
+}
+
+// merge
+// - return the live fields, updated with the desired ones
+// - the fields which were last applied and are no longer desired are removed, the other fields of
+// live, such as the ones set by the server, are kept
+// - the objects are merged field by field, the arrays are replaced
+func merge(live, last, desired map[string]interface{}) map[string]interface{} {
+	merged := copyMap(live)
+
+	for key := range last {
+		if _, ok := desired[key]; !ok {
+			delete(merged, key)
+		}
+	}
+
+	for key, value := range desired {
+		desiredMap, ok := value.(map[string]interface{})
+		liveMap, isMap := merged[key].(map[string]interface{})
+
+		if ok && isMap {
+			lastMap, _ := last[key].(map[string]interface{})
+			merged[key] = merge(liveMap, lastMap, desiredMap)
+
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// keepWriteOnly
+// - keep the live value of the write-only fields which did not change since last applied
+// - applied are the fields which will be recorded, see lastAppliedFields: a sensitive field did not
+// change if its hash is still the recorded one
+func keepWriteOnly(kind string, merged, live, last, applied map[string]interface{}) {
+	if last == nil {
+		return
+	}
+
+	for _, key := range writeOnlyFields[kind] {
+		value, ok := live[key]
+		if ok && reflect.DeepEqual(last[key], applied[key]) {
+			merged[key] = value
+		}
+	}
+}
+
+// diff returns the fields which differ, as "- path: value" and "+ path: value" lines sorted by path.
+func diff(before, after map[string]interface{}) []string {
+	from, to := map[string]string{}, map[string]string{}
+
+	flatten("", before, from)
+	flatten("", after, to)
+
+	annotation := "metadata.extend." + LastAppliedAnnotation
+	delete(from, annotation)
+	delete(to, annotation)
+
+	paths := make([]string, 0, len(to))
+	for path := range from {
+		paths = append(paths, path)
+	}
+
+	for path := range to {
+		if _, ok := from[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	var lines []string
+
+	for _, path := range paths {
+		oldValue, hadOld := from[path]
+		newValue, hasNew := to[path]
+
+		if hadOld && hasNew && oldValue == newValue {
+			continue
+		}
+
+		if isSensitive(path) {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+
+		if hadOld {
+			lines = append(lines, "- "+path+": "+oldValue)
+		}
+
+		if hasNew {
+			lines = append(lines, "+ "+path+": "+newValue)
+		}
+	}
+
+	return lines
+}
+
+// flatten sets the JSON value of every leaf field of fields, by path.
+func flatten(prefix string, fields map[string]interface{}, values map[string]string) {
+	for key, value := range fields {
+		path := key
+		if len(prefix) > 0 {
+			path = prefix + "." + key
+		}
+
+		if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+			flatten(path, m, values)
+			continue
+		}
+
+		values[path] = encode(value)
+	}
+}
+
+// encode returns the JSON of a value, without escaping the <> delimiters of the policies.
+func encode(value interface{}) string {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func isSensitive(path string) bool {
+	return sensitiveFields[path[strings.LastIndex(path, ".")+1:]]
+}
+
+// redact hides a sensitive JSON value, unless it is empty.
+func redact(value string) string {
+	if value == `""` || value == "null" {
+		return value
+	}
+
+	return "<redacted>"
+}
+
+func asMap(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+
+	return m
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		result[key] = value
+	}
+
+	return result
+}
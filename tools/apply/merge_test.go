@@ -0,0 +1,241 @@
+package apply
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testUser(password string) *Object {
+	return &Object{
+		Kind:   KindUser,
+		Name:   "alice",
+		Source: "users.yaml#0",
+		Fields: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "alice"},
+			"nickname": "alice",
+			"email":    "alice@example.com",
+			"password": password,
+		},
+	}
+}
+
+// liveOf returns the object as the server returns it once fields were sent: the password hashed
+// by the server, and the fields it sets.
+func liveOf(fields map[string]interface{}) map[string]interface{} {
+	live := copyMap(fields)
+	live["password"] = "$2a$10$server-side-hash"
+	live["totalPolicy"] = 1
+
+	metadata := copyMap(asMap(live["metadata"]))
+	metadata["instanceID"] = "user-abc"
+	live["metadata"] = metadata
+
+	return live
+}
+
+// annotation returns LastAppliedAnnotation of the fields.
+func annotation(t *testing.T, fields map[string]interface{}) string {
+	t.Helper()
+
+	value, ok := asMap(asMap(fields["metadata"])["extend"])[LastAppliedAnnotation].(string)
+	if !ok {
+		t.Fatalf("%v has no %s", fields, LastAppliedAnnotation)
+	}
+
+	return value
+}
+
+func TestPlanObjectSecrets(t *testing.T) {
+	created, err := planObject(testUser("s3cret-passw0rd"), nil)
+	if err != nil {
+		t.Fatalf("planObject() failed: %v", err)
+	}
+
+	live := liveOf(created.fields)
+
+	// A manifest applied before the annotation recorded hashes
+	legacy, err := withLastApplied(liveOf(testUser("s3cret-passw0rd").Fields), testUser("s3cret-passw0rd").Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		object       *Object
+		live         map[string]interface{}
+		wantAction   Action
+		wantPassword string
+		// wantSameAnnotation is true if the annotation of live is kept as is
+		wantSameAnnotation bool
+	}{
+		{
+			name:         "create",
+			object:       testUser("s3cret-passw0rd"),
+			wantAction:   ActionCreate,
+			wantPassword: "s3cret-passw0rd",
+		},
+		{
+			name:               "unchanged",
+			object:             testUser("s3cret-passw0rd"),
+			live:               live,
+			wantAction:         ActionUnchanged,
+			wantPassword:       "$2a$10$server-side-hash",
+			wantSameAnnotation: true,
+		},
+		{
+			name:         "password changed",
+			object:       testUser("n3w-passw0rd"),
+			live:         live,
+			wantAction:   ActionUpdate,
+			wantPassword: "n3w-passw0rd",
+		},
+		{
+			name:         "plaintext annotation",
+			object:       testUser("s3cret-passw0rd"),
+			live:         legacy,
+			wantAction:   ActionUpdate,
+			wantPassword: "s3cret-passw0rd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change, err := planObject(tt.object, tt.live)
+			if err != nil {
+				t.Fatalf("planObject() failed: %v", err)
+			}
+
+			if change.Action != tt.wantAction {
+				t.Errorf("action = %s, want %s", change.Action, tt.wantAction)
+			}
+
+			if change.fields["password"] != tt.wantPassword {
+				t.Errorf("password sent = %v, want %s", change.fields["password"], tt.wantPassword)
+			}
+
+			value := annotation(t, change.fields)
+			if strings.Contains(value, "passw0rd") {
+				t.Errorf("the annotation discloses the password: %s", value)
+			}
+
+			if tt.live != nil && (value == annotation(t, tt.live)) != tt.wantSameAnnotation {
+				t.Errorf("annotation = %s, want the one of live %v", value, tt.wantSameAnnotation)
+			}
+
+			var recorded map[string]interface{}
+			if err := json.Unmarshal([]byte(value), &recorded); err != nil {
+				t.Fatal(err)
+			}
+
+			if recorded["email"] != "alice@example.com" || !strings.HasPrefix(recorded["password"].(string), "$2a$") {
+				t.Errorf("recorded fields = %v, want the email and the hash of the password", recorded)
+			}
+
+			for _, line := range change.Diff {
+				if strings.Contains(line, "passw0rd") {
+					t.Errorf("the diff discloses the password: %s", line)
+				}
+			}
+		})
+	}
+}
+
+func TestLastAppliedFields(t *testing.T) {
+	desired := map[string]interface{}{
+		"metadata":   map[string]interface{}{"name": "key", "extend": map[string]interface{}{"secret_key": "nested"}},
+		"username":   "alice",
+		"secret_key": "3b5f0e2c9d",
+		"password":   "",
+		"expires":    float64(0),
+	}
+
+	applied, err := lastAppliedFields(desired, nil)
+	if err != nil {
+		t.Fatalf("lastAppliedFields() failed: %v", err)
+	}
+
+	if applied["username"] != "alice" || applied["password"] != "" || applied["expires"] != float64(0) {
+		t.Errorf("lastAppliedFields() = %v, want the other fields and the empty ones unchanged", applied)
+	}
+
+	for _, hash := range []interface{}{applied["secret_key"], asMap(asMap(applied["metadata"])["extend"])["secret_key"]} {
+		if s, _ := hash.(string); !strings.HasPrefix(s, "$2a$") {
+			t.Errorf("secret_key recorded as %v, want a bcrypt hash", hash)
+		}
+	}
+
+	// Recording the same fields again keeps the hashes
+	again, err := lastAppliedFields(desired, applied)
+	if err != nil || !reflect.DeepEqual(again, applied) {
+		t.Errorf("lastAppliedFields() = %v, %v, want %v", again, err, applied)
+	}
+}
+
+func TestHashSecret(t *testing.T) {
+	// Longer than the 72 bytes bcrypt reads, and only differing after them
+	long := strings.Repeat("x", 80)
+
+	hash, err := hashSecret(long+"a", nil)
+	if err != nil {
+		t.Fatalf("hashSecret() failed: %v", err)
+	}
+
+	tests := []struct {
+		value    interface{}
+		recorded interface{}
+		wantSame bool
+	}{
+		{value: long + "a", recorded: hash, wantSame: true},
+		{value: long + "b", recorded: hash},
+		{value: long + "a", recorded: long + "a"},
+		{value: long + "a", recorded: nil},
+	}
+
+	for _, tt := range tests {
+		got, err := hashSecret(tt.value, tt.recorded)
+		if err != nil {
+			t.Fatalf("hashSecret() failed: %v", err)
+		}
+
+		if (got == tt.recorded) != tt.wantSame {
+			t.Errorf("hashSecret(%.10s..., %.10v...) = %v, want the recorded hash %v", tt.value, tt.recorded,
+				got, tt.wantSame)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name                string
+		live, last, desired map[string]interface{}
+		want                map[string]interface{}
+	}{
+		{
+			name:    "server fields kept",
+			live:    map[string]interface{}{"a": "1", "id": "server"},
+			desired: map[string]interface{}{"a": "2"},
+			want:    map[string]interface{}{"a": "2", "id": "server"},
+		},
+		{
+			name:    "field removed from the manifest",
+			live:    map[string]interface{}{"a": "1", "b": "1"},
+			last:    map[string]interface{}{"a": "1", "b": "1"},
+			desired: map[string]interface{}{"a": "1"},
+			want:    map[string]interface{}{"a": "1"},
+		},
+		{
+			name:    "nested objects merged, arrays replaced",
+			live:    map[string]interface{}{"m": map[string]interface{}{"x": "1", "y": "server"}, "l": []interface{}{"a", "b"}},
+			desired: map[string]interface{}{"m": map[string]interface{}{"x": "2"}, "l": []interface{}{"c"}},
+			want:    map[string]interface{}{"m": map[string]interface{}{"x": "2", "y": "server"}, "l": []interface{}{"c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := merge(tt.live, tt.last, tt.desired); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: merge() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
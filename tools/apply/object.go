@@ -0,0 +1,162 @@
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/tools/manifest"
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+)
+
+// Kinds of the objects of a manifest.
+const (
+	KindUser   = "User"
+	KindSecret = "Secret"
+	KindPolicy = "Policy"
+)
+
+// Kinds are the supported kinds, in the order they are created: secrets and policies refer to users.
+var Kinds = []string{KindUser, KindSecret, KindPolicy}
+
+/*
+ * Object:
+ * - an object of a manifest, which is a v1.User, a v1.Secret or a v1.Policy with a kind
+ *
+ *	kind: Policy
+ *	metadata:
+ *	  name: articles-admin
+ *	policy:
+ *	  subjects: ["users:<alice|bob>"]
+ *	  actions: ["delete"]
+ *	  resources: ["resources:articles:<.*>"]
+ *	  effect: allow
+ */
+
+type Object struct {
+	Kind string
+	Name string
+
+	// Source is the document the object was read from.
+	Source string
+
+	// Fields are the fields of the document, without the kind.
+	Fields map[string]interface{}
+}
+
+// String returns the kind and the name of the object.
+func (o *Object) String() string {
+	return o.Kind + "/" + o.Name
+}
+
+// Load
+// - read the objects of the given files or directories, see manifest.ReadFiles
+// - every document must have a supported kind and a name, and the fields of its kind only
+// - the policies must pass ValidatePolicy, and an object must not be defined twice
+func Load(paths ...string) ([]*Object, error) {
+	docs, err := manifest.ReadFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*Object, 0, len(docs))
+	sources := make(map[string]string, len(docs))
+
+	for _, doc := range docs {
+		object, err := decodeObject(doc.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", doc, err)
+		}
+
+		object.Source = doc.String()
+
+		if source, ok := sources[object.String()]; ok {
+			return nil, fmt.Errorf("%s: %s is already defined in %s", doc, object, source)
+		}
+
+		sources[object.String()] = object.Source
+		objects = append(objects, object)
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		return kindOrder(objects[i].Kind) < kindOrder(objects[j].Kind)
+	})
+
+	return objects, nil
+}
+
+func decodeObject(data json.RawMessage) (*Object, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	kind, _ := fields["kind"].(string)
+	delete(fields, "kind")
+
+	typed, ok := newTyped(kind)
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind %q, it must be one of %v", kind, Kinds)
+	}
+
+	// decode the fields into their type, to reject the unknown ones
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(typed); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", kind, err)
+	}
+
+	object := &Object{Kind: kind, Fields: fields}
+
+	switch t := typed.(type) {
+	case *v1.User:
+		object.Name = t.Name
+	case *v1.Secret:
+		object.Name = t.Name
+	case *v1.Policy:
+		object.Name = t.Name
+
+		if errs := apiv1.ValidatePolicy(t); len(errs) > 0 {
+			return nil, errs.ToAggregate()
+		}
+	}
+
+	if len(object.Name) == 0 {
+		return nil, fmt.Errorf("%s without metadata.name", kind)
+	}
+
+	return object, nil
+}
+
+// newTyped returns a new object of the API type of the kind.
+func newTyped(kind string) (interface{}, bool) {
+	switch kind {
+	case KindUser:
+		return &v1.User{}, true
+	case KindSecret:
+		return &v1.Secret{}, true
+	case KindPolicy:
+		return &v1.Policy{}, true
+	}
+
+	return nil, false
+}
+
+func kindOrder(kind string) int {
+	for i, k := range Kinds {
+		if k == kind {
+			return i
+		}
+	}
+
+	return len(Kinds)
+}
@@ -0,0 +1,222 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+)
+
+// resource works with the objects of a kind as JSON fields.
+type resource interface {
+	// list returns the fields of every object, by name
+	list(ctx context.Context, pageSize int64) (map[string]map[string]interface{}, error)
+	create(ctx context.Context, fields map[string]interface{}) error
+	update(ctx context.Context, fields map[string]interface{}) error
+	delete(ctx context.Context, name string) error
+}
+
+func newResources(client apiv1.APIV1Interface) map[string]resource {
+	return map[string]resource{
+		KindUser:   &users{client: client.Users()},
+		KindSecret: &secrets{client: client.Secrets()},
+		KindPolicy: &policies{client: client.Policies()},
+	}
+}
+
+type users struct {
+	client apiv1.UserInterface
+}
+
+func (r *users) list(ctx context.Context, pageSize int64) (map[string]map[string]interface{}, error) {
+	return listPages(pageSize, func(opts metav1.ListOptions) ([]interface{}, int64, error) {
+		list, err := r.client.List(ctx, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		items := make([]interface{}, len(list.Items))
+		for i := range list.Items {
+			items[i] = list.Items[i]
+		}
+
+		return items, list.TotalCount, nil
+	})
+}
+
+func (r *users) create(ctx context.Context, fields map[string]interface{}) error {
+	user := &v1.User{}
+	if err := convert(fields, user); err != nil {
+		return err
+	}
+
+	_, err := r.client.Create(ctx, user, metav1.CreateOptions{})
+
+	return err
+}
+
+func (r *users) update(ctx context.Context, fields map[string]interface{}) error {
+	user := &v1.User{}
+	if err := convert(fields, user); err != nil {
+		return err
+	}
+
+	_, err := r.client.Update(ctx, user, metav1.UpdateOptions{})
+
+	return err
+}
+
+func (r *users) delete(ctx context.Context, name string) error {
+	return r.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+type secrets struct {
+	client apiv1.SecretInterface
+}
+
+func (r *secrets) list(ctx context.Context, pageSize int64) (map[string]map[string]interface{}, error) {
+	return listPages(pageSize, func(opts metav1.ListOptions) ([]interface{}, int64, error) {
+		list, err := r.client.List(ctx, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		items := make([]interface{}, len(list.Items))
+		for i := range list.Items {
+			items[i] = list.Items[i]
+		}
+
+		return items, list.TotalCount, nil
+	})
+}
+
+func (r *secrets) create(ctx context.Context, fields map[string]interface{}) error {
+	secret := &v1.Secret{}
+	if err := convert(fields, secret); err != nil {
+		return err
+	}
+
+	_, err := r.client.Create(ctx, secret, metav1.CreateOptions{})
+
+	return err
+}
+
+func (r *secrets) update(ctx context.Context, fields map[string]interface{}) error {
+	secret := &v1.Secret{}
+	if err := convert(fields, secret); err != nil {
+		return err
+	}
+
+	_, err := r.client.Update(ctx, secret, metav1.UpdateOptions{})
+
+	return err
+}
+
+func (r *secrets) delete(ctx context.Context, name string) error {
+	return r.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+type policies struct {
+	client apiv1.PolicyInterface
+}
+
+func (r *policies) list(ctx context.Context, pageSize int64) (map[string]map[string]interface{}, error) {
+	return listPages(pageSize, func(opts metav1.ListOptions) ([]interface{}, int64, error) {
+		list, err := r.client.List(ctx, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		items := make([]interface{}, len(list.Items))
+		for i := range list.Items {
+			items[i] = list.Items[i]
+		}
+
+		return items, list.TotalCount, nil
+	})
+}
+
+func (r *policies) create(ctx context.Context, fields map[string]interface{}) error {
+	policy := &v1.Policy{}
+	if err := convert(fields, policy); err != nil {
+		return err
+	}
+
+	_, err := r.client.Create(ctx, policy, metav1.CreateOptions{})
+
+	return err
+}
+
+func (r *policies) update(ctx context.Context, fields map[string]interface{}) error {
+	policy := &v1.Policy{}
+	if err := convert(fields, policy); err != nil {
+		return err
+	}
+
+	_, err := r.client.Update(ctx, policy, metav1.UpdateOptions{})
+
+	return err
+}
+
+func (r *policies) delete(ctx context.Context, name string) error {
+	return r.client.Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// listPages calls list page by page, and returns the fields of the items by name.
+func listPages(pageSize int64,
+	list func(opts metav1.ListOptions) ([]interface{}, int64, error)) (map[string]map[string]interface{}, error) {
+	objects := make(map[string]map[string]interface{})
+
+	for offset := int64(0); ; {
+		limit := pageSize
+		start := offset
+
+		items, total, err := list(metav1.ListOptions{Offset: &start, Limit: &limit})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			var fields map[string]interface{}
+			if err := convert(item, &fields); err != nil {
+				return nil, err
+			}
+
+			objects[nameOf(fields)] = fields
+		}
+
+		offset += int64(len(items))
+
+		if int64(len(items)) < limit || (total > 0 && offset >= total) {
+			return objects, nil
+		}
+	}
+}
+
+// convert converts between the API types and their JSON fields.
+func convert(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// normalize returns the fields of kind as the server would return them, with their zero values.
+func normalize(kind string, fields map[string]interface{}) (map[string]interface{}, error) {
+	typed, _ := newTyped(kind)
+	if err := convert(fields, typed); err != nil {
+		return nil, err
+	}
+
+	var normalized map[string]interface{}
+	if err := convert(typed, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
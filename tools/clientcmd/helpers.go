@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -73,10 +74,7 @@ func LoadFromFile(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	// Set the LocationOfOrigin
-	config.AuthInfo.LocationOfOrigin = filename
-	config.Server.LocationOfOrigin = filename
-
+	// An empty section, such as "auth:", is decoded as nil
 	if config.AuthInfo == nil {
 		config.AuthInfo = &AuthInfo{}
 	}
@@ -85,5 +83,48 @@ func LoadFromFile(filename string) (*Config, error) {
 		config.Server = &Server{}
 	}
 
+	// Set the LocationOfOrigin
+	config.AuthInfo.LocationOfOrigin = filename
+	config.Server.LocationOfOrigin = filename
+
 	return config, nil
 }
+
+// Write
+// - serialize the config to YAML, the inverse of Load
+func Write(config *Config) ([]byte, error) {
+	return yaml.Marshal(config)
+}
+
+// WriteToFile
+// - write the config to a file, which is only readable by its owner as it holds credentials
+// - the directory of the file is created if needed, and the file is replaced atomically
+func WriteToFile(config *Config, filename string) error {
+	data, err := Write(config)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
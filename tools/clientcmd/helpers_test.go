@@ -0,0 +1,149 @@
+package clientcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{duration: "0", want: 0},
+		{duration: "30", want: 30 * time.Second},
+		{duration: "2m", want: 2 * time.Minute},
+		{duration: "1h30m", want: 90 * time.Minute},
+		{duration: "-1", wantErr: true},
+		{duration: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeout(tt.duration)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTimeout(%q) = %v, %v, want %v, error %v", tt.duration, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{name: "empty", data: ""},
+		{name: "address", data: "server:\n  address: https://elmt.example.com\n", want: "https://elmt.example.com"},
+		{name: "null sections", data: "server:\nuser:\n"},
+		{name: "invalid", data: "server: [", wantErr: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "config")
+			if err := ioutil.WriteFile(filename, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadFromFile(filename)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFromFile() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadFromFile() failed: %v", err)
+			}
+
+			if config.Server.Address != tt.want || config.Server.LocationOfOrigin != filename ||
+				config.AuthInfo.LocationOfOrigin != filename {
+				t.Errorf("LoadFromFile() = %+v, %+v, want the address %q from %s", config.Server, config.AuthInfo,
+					tt.want, filename)
+			}
+		})
+	}
+
+	if _, err := LoadFromFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("LoadFromFile() of a missing file error = %v", err)
+	}
+}
+
+func TestWriteToFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "home", RecommendedHomeDir)
+	filename := filepath.Join(dir, RecommendedFileName)
+
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	config := NewConfig()
+	config.Server.Address = "https://elmt.example.com"
+	config.Server.LocationOfOrigin = "/elsewhere"
+	config.AuthInfo.Username = "alice"
+	config.AuthInfo.Token = "t0ken"
+	config.AuthInfo.TokenExpiry = expiry
+
+	// the file is created with its directory, then replaced
+	for _, token := range []string{"t0ken", "n3w-t0ken"} {
+		config.AuthInfo.Token = token
+
+		if err := WriteToFile(config, filename); err != nil {
+			t.Fatalf("WriteToFile() failed: %v", err)
+		}
+
+		loaded, err := LoadFromFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if loaded.Server.Address != config.Server.Address || loaded.AuthInfo.Username != "alice" ||
+			loaded.AuthInfo.Token != token || !loaded.AuthInfo.TokenExpiry.Equal(expiry) {
+			t.Errorf("loaded %+v, %+v, want the config written", loaded.Server, loaded.AuthInfo)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lower := strings.ToLower(string(data)); strings.Contains(lower, "/elsewhere") ||
+		strings.Contains(lower, "locationoforigin") {
+		t.Errorf("the file records the location of origin:\n%s", data)
+	}
+
+	for path, want := range map[string]os.FileMode{filename: 0o600, dir: 0o700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != want {
+			t.Errorf("%s mode = %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+
+	// the temporary files are removed
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("%s holds %d files, %v, want only the config", dir, len(entries), err)
+	}
+}
+
+func TestWriteToFileError(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(parent, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteToFile(NewConfig(), filepath.Join(parent, "config")); err == nil {
+		t.Error("WriteToFile() under a file succeeded")
+	}
+}
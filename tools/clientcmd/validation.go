@@ -7,6 +7,8 @@ import (
 	"reflect"
 
 	utilerrors "github.com/opsdata/errors"
+
+	restclient "github.com/opsdata/elmt-sdk/rest"
 )
 
 var (
@@ -130,6 +132,26 @@ func validateServerInfo(serverInfo Server) []error {
 		)
 	}
 
+	if len(serverInfo.ProxyURL) != 0 {
+		if _, err := restclient.ParseProxyURL(serverInfo.ProxyURL); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	}
+
+	if len(serverInfo.TLSMinVersion) != 0 {
+		if _, err := restclient.ParseTLSVersion(serverInfo.TLSMinVersion); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	}
+
+	if _, err := restclient.ParseCipherSuites(serverInfo.TLSCipherSuites); err != nil {
+		validationErrors = append(validationErrors, err)
+	}
+
+	if err := restclient.ValidatePublicKeyPins(serverInfo.TLSPinnedPublicKeys); err != nil {
+		validationErrors = append(validationErrors, err)
+	}
+
 	if len(serverInfo.CertificateAuthority) != 0 {
 		clientCertCA, err := os.Open(serverInfo.CertificateAuthority)
 		if err != nil {
@@ -154,7 +176,8 @@ func validateAuthInfo(authInfo AuthInfo) []error {
 		methods = append(methods, "token")
 	}
 
-	if len(authInfo.Username) != 0 || len(authInfo.Password) != 0 {
+	// a username without a password may name the user a token was issued to
+	if len(authInfo.Password) != 0 || (len(authInfo.Username) != 0 && len(authInfo.Token) == 0) {
 		methods = append(methods, "basicAuth")
 	}
 
@@ -169,6 +192,22 @@ func validateAuthInfo(authInfo AuthInfo) []error {
 			fmt.Errorf("more than one authentication method found; found %v, only one is allowed", methods))
 	}
 
+	usingPKCS12 := len(authInfo.ClientPKCS12) != 0 || len(authInfo.ClientPKCS12Data) != 0
+	usingCert := len(authInfo.ClientCertificate) != 0 || len(authInfo.ClientCertificateData) != 0
+
+	// Make sure a PKCS#12 bundle is not mixed with PEM certificates
+	if usingPKCS12 && usingCert {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("client-pkcs12 and client-certificate are both specified, only one is allowed"))
+	}
+
+	if len(authInfo.ClientPKCS12) != 0 {
+		if _, err := os.Stat(authInfo.ClientPKCS12); err != nil {
+			validationErrors = append(validationErrors,
+				fmt.Errorf("unable to read client-pkcs12 %v due to %v", authInfo.ClientPKCS12, err))
+		}
+	}
+
 	if len(authInfo.ClientCertificate) == 0 || len(authInfo.ClientCertificateData) == 0 {
 		return validationErrors
 	}
@@ -213,3 +252,15 @@ func validateAuthInfo(authInfo AuthInfo) []error {
 
 	return validationErrors
 }
+
+// validateZabbixInfo looks for errors in the zabbix info.
+func validateZabbixInfo(zabbixInfo ZabbixInfo) []error {
+	validationErrors := make([]error, 0)
+
+	if zabbixInfo.BatchSize < 0 {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("zabbix batch-size %d is invalid, it must not be negative", zabbixInfo.BatchSize))
+	}
+
+	return validationErrors
+}
@@ -0,0 +1,5 @@
+package login
+
+// package login
+// - exchange a username and password, or a secret ID and key, for a short-lived JWT at the login
+// endpoint of an ELMT server, and keep that token fresh for the requests of a client
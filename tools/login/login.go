@@ -0,0 +1,171 @@
+package login
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opsdata/common-base/pkg/runtime"
+	apiserverv1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// The endpoints of the ELMT server issuing the tokens, relative to its address.
+const (
+	LoginPath   = "/login"
+	RefreshPath = "/refresh"
+)
+
+// Credentials are what a user logs in with, either a username and a password, or a secret ID and key.
+type Credentials struct {
+	Username  string
+	Password  string
+	SecretID  string
+	SecretKey string
+}
+
+// Token is a JWT issued by the login endpoint.
+type Token struct {
+	AccessToken string
+
+	// Expiry is the exp claim of the token, zero if the token never expires.
+	Expiry time.Time
+}
+
+// ExpiresWithin tells whether the token expires in less than d, a token without expiry never does.
+func (t *Token) ExpiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Until(t.Expiry) < d
+}
+
+// tokenResponse is the body of the responses of the login and refresh endpoints.
+type tokenResponse struct {
+	Token  string `json:"token"`
+	Expire string `json:"expire"`
+}
+
+// Login
+// - exchange the credentials for a token at the login endpoint of the server of config
+// - the credentials are sent in the Authorization header, as rest.NewRequest does: basic auth for
+// a username and password, a JWT signed with the secret key for a secret ID and key
+// - the credentials of config itself are ignored, config only tells how to reach the server
+func Login(ctx context.Context, config *rest.Config, creds Credentials) (*Token, error) {
+	c := clientConfig(config)
+	c.Username, c.Password = creds.Username, creds.Password
+	c.SecretID, c.SecretKey = creds.SecretID, creds.SecretKey
+
+	if len(c.Username) == 0 && (len(c.SecretID) == 0 || len(c.SecretKey) == 0) {
+		return nil, fmt.Errorf("a username and a password, or a secret ID and a secret key, are required to log in")
+	}
+
+	token, err := requestToken(ctx, c, LoginPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in: %v", err)
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a token which has not expired yet for a new one, at the refresh endpoint of the
+// server of config.
+func Refresh(ctx context.Context, config *rest.Config, accessToken string) (*Token, error) {
+	c := clientConfig(config)
+	c.BearerToken = accessToken
+
+	token, err := requestToken(ctx, c, RefreshPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh the token: %v", err)
+	}
+
+	return token, nil
+}
+
+// clientConfig returns a copy of config without credentials, for the token endpoints.
+func clientConfig(config *rest.Config) *rest.Config {
+	c := *config
+	c.Username, c.Password = "", ""
+	c.SecretID, c.SecretKey = "", ""
+	c.BearerToken, c.BearerTokenFile = "", ""
+
+	gv := apiserverv1.SchemeGroupVersion
+	c.GroupVersion = &gv
+	c.APIPath = ""
+	c.Negotiator = runtime.NewSimpleClientNegotiator()
+
+	return &c
+}
+
+func requestToken(ctx context.Context, config *rest.Config, path string) (*Token, error) {
+	client, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.CloseIdleConnections()
+
+	body, err := client.Post().AbsPath(path).Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp tokenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response %q: %v", body, err)
+	}
+
+	if len(resp.Token) == 0 {
+		return nil, fmt.Errorf("the response has no token")
+	}
+
+	token := &Token{AccessToken: resp.Token}
+
+	if token.Expiry, err = ParseExpiry(resp.Token); err != nil {
+		return nil, err
+	}
+
+	// a token without an exp claim may still be given an expiry by the server
+	if token.Expiry.IsZero() && len(resp.Expire) > 0 {
+		if token.Expiry, err = time.Parse(time.RFC3339, resp.Expire); err != nil {
+			return nil, fmt.Errorf("invalid token expiry %q: %v", resp.Expire, err)
+		}
+	}
+
+	return token, nil
+}
+
+// ParseExpiry
+// - return the time of the exp claim of a JWT, or the zero time if it has none
+// - the signature of the token is not verified, only the server can do so
+func ParseExpiry(accessToken string) (time.Time, error) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("the token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid JWT payload: %v", err)
+	}
+
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("invalid JWT claims: %v", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid exp claim %q: %v", claims.Exp.String(), err)
+	}
+
+	return time.Unix(int64(exp), 0), nil
+}
@@ -0,0 +1,341 @@
+package login
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// makeToken returns an unsigned JWT expiring at exp, without an exp claim if exp is zero.
+func makeToken(subject string, exp time.Time) string {
+	claims := map[string]interface{}{"sub": subject}
+	if !exp.IsZero() {
+		claims["exp"] = exp.Unix()
+	}
+
+	payload, _ := json.Marshal(claims)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+// tokenSubject returns the sub claim of a JWT of makeToken.
+func tokenSubject(accessToken string) string {
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+
+	if parts := strings.Split(accessToken, "."); len(parts) == 3 {
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		_ = json.Unmarshal(payload, &claims)
+	}
+
+	return claims.Sub
+}
+
+/*
+ * tokenServer:
+ * - stand in for the token endpoints of the ELMT server
+ * - alice logs in with the password passw0rd, the secret id with any key, and the tokens issued
+ * are refreshed
+ */
+
+type tokenServer struct {
+	*httptest.Server
+
+	// lifetime is the lifetime of the tokens issued, which have no exp claim if zero
+	lifetime time.Duration
+
+	// body replaces the response of the endpoints if set
+	body string
+
+	mu                sync.Mutex
+	issued            map[string]bool
+	logins, refreshes int32
+	lastAuthorization atomic.Value
+}
+
+func newTokenServer(t *testing.T, lifetime time.Duration) *tokenServer {
+	t.Helper()
+
+	s := &tokenServer{lifetime: lifetime, issued: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *tokenServer) serve(w http.ResponseWriter, r *http.Request) {
+	authorization := r.Header.Get("Authorization")
+	s.lastAuthorization.Store(authorization)
+
+	var subject string
+
+	switch r.URL.Path {
+	case LoginPath:
+		atomic.AddInt32(&s.logins, 1)
+
+		if user, password, ok := r.BasicAuth(); ok && user == "alice" && password == "passw0rd" {
+			subject = user
+		} else if strings.HasPrefix(authorization, "Bearer ") && strings.Contains(authorization, ".") {
+			subject = "secret"
+		}
+	case RefreshPath:
+		atomic.AddInt32(&s.refreshes, 1)
+
+		if token := strings.TrimPrefix(authorization, "Bearer "); s.isIssued(token) {
+			subject = "refreshed"
+		}
+	}
+
+	if len(subject) == 0 {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":100208,"message":"Authorization failed"}`))
+
+		return
+	}
+
+	if len(s.body) > 0 {
+		_, _ = w.Write([]byte(s.body))
+		return
+	}
+
+	var exp time.Time
+	if s.lifetime > 0 {
+		exp = time.Now().Add(s.lifetime)
+	}
+
+	token := makeToken(subject, exp)
+	s.issue(token)
+
+	_ = json.NewEncoder(w).Encode(&tokenResponse{Token: token})
+}
+
+// issue makes token refreshable.
+func (s *tokenServer) issue(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.issued[token] = true
+}
+
+func (s *tokenServer) isIssued(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issued[token]
+}
+
+func (s *tokenServer) config() *rest.Config {
+	return &rest.Config{Host: s.URL}
+}
+
+func TestLogin(t *testing.T) {
+	expire := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		body     string
+		// config sets credentials on the config, which are not used
+		config     func(c *rest.Config)
+		creds      Credentials
+		wantSub    string
+		wantExpiry func(t time.Time) bool
+		wantErr    string
+	}{
+		{
+			name:       "password",
+			lifetime:   time.Hour,
+			creds:      Credentials{Username: "alice", Password: "passw0rd"},
+			wantSub:    "alice",
+			wantExpiry: func(t time.Time) bool { return time.Until(t) > 59*time.Minute && time.Until(t) <= time.Hour },
+		},
+		{
+			name:       "secret",
+			lifetime:   time.Hour,
+			creds:      Credentials{SecretID: "id", SecretKey: "key"},
+			wantSub:    "secret",
+			wantExpiry: func(t time.Time) bool { return !t.IsZero() },
+		},
+		{
+			name:       "credentials of the config ignored",
+			config:     func(c *rest.Config) { c.BearerToken = "t0ken" },
+			creds:      Credentials{Username: "alice", Password: "passw0rd"},
+			wantSub:    "alice",
+			wantExpiry: time.Time.IsZero,
+		},
+		{
+			name:       "expiry of the response",
+			body:       fmt.Sprintf(`{"token":%q,"expire":%q}`, makeToken("alice", time.Time{}), expire.Format(time.RFC3339)),
+			creds:      Credentials{Username: "alice", Password: "passw0rd"},
+			wantSub:    "alice",
+			wantExpiry: expire.Equal,
+		},
+		{
+			name:    "wrong password",
+			creds:   Credentials{Username: "alice", Password: "guess"},
+			wantErr: "unable to log in",
+		},
+		{
+			name:    "no credentials",
+			creds:   Credentials{SecretID: "id"},
+			wantErr: "are required to log in",
+		},
+		{
+			name:    "no token",
+			body:    `{"expire":"2030-01-02T03:04:05Z"}`,
+			creds:   Credentials{Username: "alice", Password: "passw0rd"},
+			wantErr: "the response has no token",
+		},
+		{
+			name:    "not a JWT",
+			body:    `{"token":"opaque"}`,
+			creds:   Credentials{Username: "alice", Password: "passw0rd"},
+			wantErr: "the token is not a JWT",
+		},
+		{
+			name:    "invalid expiry",
+			body:    fmt.Sprintf(`{"token":%q,"expire":"tomorrow"}`, makeToken("alice", time.Time{})),
+			creds:   Credentials{Username: "alice", Password: "passw0rd"},
+			wantErr: `invalid token expiry "tomorrow"`,
+		},
+		{
+			name:    "invalid response",
+			body:    `<html>`,
+			creds:   Credentials{Username: "alice", Password: "passw0rd"},
+			wantErr: "invalid response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTokenServer(t, tt.lifetime)
+			server.body = tt.body
+
+			config := server.config()
+			if tt.config != nil {
+				tt.config(config)
+			}
+
+			token, err := Login(context.Background(), config, tt.creds)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Login() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Login() failed: %v", err)
+			}
+
+			if sub := tokenSubject(token.AccessToken); sub != tt.wantSub {
+				t.Errorf("token subject = %s, want %s", sub, tt.wantSub)
+			}
+
+			if !tt.wantExpiry(token.Expiry) {
+				t.Errorf("token expiry = %v", token.Expiry)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	server := newTokenServer(t, time.Hour)
+
+	token, err := Login(context.Background(), server.config(), Credentials{Username: "alice", Password: "passw0rd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := Refresh(context.Background(), server.config(), token.AccessToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+
+	if refreshed.AccessToken == token.AccessToken || refreshed.Expiry.IsZero() {
+		t.Errorf("Refresh() = %+v, want a new token", refreshed)
+	}
+
+	if got := server.lastAuthorization.Load(); got != "Bearer "+token.AccessToken {
+		t.Errorf("Authorization = %v, want the token refreshed", got)
+	}
+
+	if _, err := Refresh(context.Background(), server.config(), "unknown"); err == nil ||
+		!strings.Contains(err.Error(), "unable to refresh the token") {
+		t.Errorf("Refresh() of an unknown token error = %v", err)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	exp := time.Unix(1893553445, 0)
+	encode := func(claims string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "exp", token: makeToken("alice", exp), want: exp},
+		{name: "no exp", token: makeToken("alice", time.Time{})},
+		{name: "fractional exp", token: encode(`{"exp":1893553445.5}`), want: exp},
+		{
+			name:  "padded payload",
+			token: "e30." + base64.URLEncoding.EncodeToString([]byte(`{"exp":1893553445}`)) + ".sig",
+			want:  exp,
+		},
+		{name: "not a JWT", token: "opaque", wantErr: "the token is not a JWT"},
+		{name: "invalid payload", token: "e30.!!!.sig", wantErr: "invalid JWT payload"},
+		{name: "invalid claims", token: encode(`[1]`), wantErr: "invalid JWT claims"},
+		{name: "invalid exp", token: encode(`{"exp":"soon"}`), wantErr: "invalid JWT claims"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExpiry(tt.token)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: ParseExpiry() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s: ParseExpiry() = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpiresWithin(t *testing.T) {
+	tests := []struct {
+		expiry time.Time
+		within time.Duration
+		want   bool
+	}{
+		{within: time.Hour},
+		{expiry: time.Now().Add(time.Hour), within: time.Minute},
+		{expiry: time.Now().Add(time.Minute), within: time.Hour, want: true},
+		{expiry: time.Now().Add(-time.Minute), want: true},
+	}
+
+	for _, tt := range tests {
+		if got := (&Token{Expiry: tt.expiry}).ExpiresWithin(tt.within); got != tt.want {
+			t.Errorf("ExpiresWithin(%v) of a token expiring at %v = %v, want %v", tt.within, tt.expiry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// DefaultRefreshWindow is how long before its expiry a token is renewed, unless
+// TokenSource.RefreshWindow is set.
+const DefaultRefreshWindow = 5 * time.Minute
+
+/*
+ * TokenSource:
+ * - hold the token a client is logged in with, and renew it when it is about to expire
+ * - a token which has not expired yet is refreshed, otherwise the user is asked for the
+ * credentials again by Prompt and logged in
+ * - WrapTransport sets the token on every request of a client, as a rest.WrapperFunc
+ *
+ *	source := login.NewTokenSource(config, token)
+ *	source.OnToken = func(t *login.Token) { ... store t ... }
+ *	config.WrapTransport = rest.Wrappers(config.WrapTransport, source.WrapTransport)
+ */
+
+type TokenSource struct {
+	// RefreshWindow is how long before its expiry the token is renewed, DefaultRefreshWindow if zero.
+	RefreshWindow time.Duration
+
+	// Prompt asks for the credentials to log in with when the token cannot be refreshed, the
+	// token is then kept until it expires if nil.
+	Prompt func(ctx context.Context) (Credentials, error)
+
+	// OnToken is called with every new token, such as to store it.
+	OnToken func(token *Token)
+
+	config *rest.Config
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// NewTokenSource creates a TokenSource renewing token at the server of config. It must be called
+// before the WrapTransport of the source is installed on config.
+func NewTokenSource(config *rest.Config, token *Token) *TokenSource {
+	c := *config
+
+	return &TokenSource{config: &c, token: token}
+}
+
+// Token returns the current token, renewed first if it is about to expire.
+func (s *TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window := s.RefreshWindow
+	if window <= 0 {
+		window = DefaultRefreshWindow
+	}
+
+	if !s.token.ExpiresWithin(window) {
+		return s.token, nil
+	}
+
+	if !s.token.ExpiresWithin(0) {
+		token, err := Refresh(ctx, s.config, s.token.AccessToken)
+		if err == nil {
+			return s.set(token), nil
+		}
+
+		// without a way to log in again, the current token is used until it expires
+		if s.Prompt == nil {
+			return s.token, nil
+		}
+	}
+
+	if s.Prompt == nil {
+		return nil, fmt.Errorf("the token expired at %s, log in again", s.token.Expiry.Format(time.RFC3339))
+	}
+
+	creds, err := s.Prompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := Login(ctx, s.config, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.set(token), nil
+}
+
+func (s *TokenSource) set(token *Token) *Token {
+	s.token = token
+
+	if s.OnToken != nil {
+		s.OnToken(token)
+	}
+
+	return token
+}
+
+// WrapTransport returns a round tripper setting the token of the source, renewed if needed, as
+// the bearer token of every request.
+func (s *TokenSource) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return rest.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		token, err := s.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+		return rt.RoundTrip(req)
+	})
+}
@@ -0,0 +1,239 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenSource(t *testing.T) {
+	errCanceled := errors.New("canceled")
+
+	tests := []struct {
+		name string
+		// expiresIn is when the token of the source expires, never if zero
+		expiresIn time.Duration
+		// refreshable tells whether the server refreshes the token
+		refreshable bool
+		prompt      func(ctx context.Context) (Credentials, error)
+
+		wantSame      bool
+		wantSub       string
+		wantLogins    int32
+		wantRefreshes int32
+		wantErr       string
+	}{
+		{
+			name:      "valid",
+			expiresIn: time.Hour,
+			wantSame:  true,
+		},
+		{
+			name:     "no expiry",
+			wantSame: true,
+		},
+		{
+			name:          "refreshed",
+			expiresIn:     time.Minute,
+			refreshable:   true,
+			wantSub:       "refreshed",
+			wantRefreshes: 1,
+		},
+		{
+			name:          "refresh failing without prompt",
+			expiresIn:     time.Minute,
+			wantSame:      true,
+			wantRefreshes: 1,
+		},
+		{
+			name:      "refresh failing with prompt",
+			expiresIn: time.Minute,
+			prompt: func(context.Context) (Credentials, error) {
+				return Credentials{Username: "alice", Password: "passw0rd"}, nil
+			},
+			wantSub:       "alice",
+			wantLogins:    1,
+			wantRefreshes: 1,
+		},
+		{
+			name:      "expired without prompt",
+			expiresIn: -time.Minute,
+			wantErr:   "log in again",
+		},
+		{
+			name:      "expired with prompt",
+			expiresIn: -time.Minute,
+			prompt: func(context.Context) (Credentials, error) {
+				return Credentials{Username: "alice", Password: "passw0rd"}, nil
+			},
+			wantSub:    "alice",
+			wantLogins: 1,
+		},
+		{
+			name:      "prompt failing",
+			expiresIn: -time.Minute,
+			prompt:    func(context.Context) (Credentials, error) { return Credentials{}, errCanceled },
+			wantErr:   errCanceled.Error(),
+		},
+		{
+			name:      "wrong password",
+			expiresIn: -time.Minute,
+			prompt: func(context.Context) (Credentials, error) {
+				return Credentials{Username: "alice", Password: "guess"}, nil
+			},
+			wantErr:    "unable to log in",
+			wantLogins: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTokenServer(t, time.Hour)
+
+			var expiry time.Time
+			if tt.expiresIn != 0 {
+				expiry = time.Now().Add(tt.expiresIn)
+			}
+
+			current := &Token{AccessToken: makeToken("current", expiry), Expiry: expiry}
+			if tt.refreshable {
+				server.issue(current.AccessToken)
+			}
+
+			var stored []*Token
+
+			source := NewTokenSource(server.config(), current)
+			source.Prompt = tt.prompt
+			source.OnToken = func(token *Token) { stored = append(stored, token) }
+
+			token, err := source.Token(context.Background())
+
+			logins, refreshes := atomic.LoadInt32(&server.logins), atomic.LoadInt32(&server.refreshes)
+			if logins != tt.wantLogins || refreshes != tt.wantRefreshes {
+				t.Errorf("%d logins and %d refreshes, want %d and %d", logins, refreshes, tt.wantLogins,
+					tt.wantRefreshes)
+			}
+
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Token() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Token() failed: %v", err)
+			}
+
+			if tt.wantSame {
+				if token != current || len(stored) > 0 {
+					t.Errorf("Token() = %+v, stored %v, want the current token", token, stored)
+				}
+
+				return
+			}
+
+			if sub := tokenSubject(token.AccessToken); sub != tt.wantSub {
+				t.Errorf("Token() = %s, want a token of %s", token.AccessToken, tt.wantSub)
+			}
+
+			if len(stored) != 1 || stored[0] != token {
+				t.Errorf("stored %v, want the new token", stored)
+			}
+
+			// the new token is kept
+			if again, err := source.Token(context.Background()); err != nil || again != token {
+				t.Errorf("Token() = %v, %v, want the new token again", again, err)
+			}
+		})
+	}
+}
+
+func TestTokenSourceRefreshWindow(t *testing.T) {
+	server := newTokenServer(t, time.Hour)
+
+	expiry := time.Now().Add(10 * time.Minute)
+	current := &Token{AccessToken: makeToken("current", expiry), Expiry: expiry}
+	server.issue(current.AccessToken)
+
+	source := NewTokenSource(server.config(), current)
+	source.RefreshWindow = 15 * time.Minute
+
+	// the callers waiting while the token is refreshed get the new token
+	var wg sync.WaitGroup
+
+	tokens := make([]*Token, 8)
+	for i := range tokens {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			tokens[i], _ = source.Token(context.Background())
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, token := range tokens {
+		if token == nil || token == current || token != tokens[0] {
+			t.Fatalf("Token() = %v, want the same refreshed token", tokens)
+		}
+	}
+
+	if refreshes := atomic.LoadInt32(&server.refreshes); refreshes != 1 {
+		t.Errorf("%d refreshes, want 1", refreshes)
+	}
+}
+
+func TestWrapTransport(t *testing.T) {
+	tokens := newTokenServer(t, time.Hour)
+
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Authorization")
+	}))
+	t.Cleanup(backend.Close)
+
+	expiry := time.Now().Add(time.Minute)
+	current := &Token{AccessToken: makeToken("current", expiry), Expiry: expiry}
+	tokens.issue(current.AccessToken)
+
+	source := NewTokenSource(tokens.config(), current)
+	client := &http.Client{Transport: source.WrapTransport(http.DefaultTransport)}
+
+	req, _ := http.NewRequest(http.MethodGet, backend.URL+"/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer other")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	token, _ := source.Token(context.Background())
+	if got := <-received; token == current || got != "Bearer "+token.AccessToken {
+		t.Errorf("Authorization = %s, want the refreshed token", got)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer other" {
+		t.Errorf("the request was modified, Authorization = %s", got)
+	}
+
+	// the requests fail once the token expired
+	expired := time.Now().Add(-time.Minute)
+	source = NewTokenSource(tokens.config(), &Token{AccessToken: makeToken("current", expired), Expiry: expired})
+	client = &http.Client{Transport: source.WrapTransport(http.DefaultTransport)}
+
+	if _, err := client.Get(backend.URL + "/v1/users"); err == nil || !strings.Contains(err.Error(), "log in again") {
+		t.Errorf("Get() error = %v, want the token expired", err)
+	}
+}
@@ -0,0 +1,4 @@
+package manifest
+
+// package manifest
+// - read the documents of YAML or JSON manifest files as JSON, to decode them into the API types
@@ -0,0 +1,184 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions are the file extensions read when a directory is given to ReadFiles.
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// Document is a document of a manifest, converted to JSON.
+type Document struct {
+	// Source is the file the document was read from, "-" for the standard input.
+	Source string
+
+	// Index is the position of the document in its file, starting at 0.
+	Index int
+
+	Data json.RawMessage
+}
+
+// String returns the position of the document, to be used in error messages.
+func (d Document) String() string {
+	return fmt.Sprintf("%s#%d", d.Source, d.Index)
+}
+
+// Decode
+// - split a manifest into its documents, separated by "---", and convert them to JSON
+// - JSON is read as YAML, so a manifest can be made of JSON objects as well
+// - the empty documents are skipped
+func Decode(data []byte) ([]json.RawMessage, error) {
+	var docs []json.RawMessage
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var value interface{}
+
+		if err := decoder.Decode(&value); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+
+			return nil, err
+		}
+
+		if value == nil {
+			continue
+		}
+
+		doc, err := json.Marshal(toJSONValue(value))
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+}
+
+// ReadFiles
+// - read the documents of the given files, in order
+// - a directory is read file by file, in lexical order, for the files ending with one of Extensions
+// - "-" reads the standard input
+func ReadFiles(paths ...string) ([]Document, error) {
+	var docs []Document
+
+	for _, path := range paths {
+		files, err := expand(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			data, err := readFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			values, err := Decode(data)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode %s: %v", file, err)
+			}
+
+			for i, value := range values {
+				docs = append(docs, Document{Source: file, Index: i, Data: value})
+			}
+		}
+	}
+
+	return docs, nil
+}
+
+// expand returns the manifest files of a directory, or the path itself if it is a file.
+func expand(path string) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && hasExtension(file) {
+			files = append(files, file)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func hasExtension(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readFile(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(file)
+}
+
+// toJSONValue converts the maps with non-string keys decoded from YAML, which JSON cannot encode.
+func toJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = toJSONValue(item)
+		}
+
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = toJSONValue(item)
+		}
+
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toJSONValue(item)
+		}
+
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,4 @@
+package metrics
+
+// package metrics
+// - provide ready-made rest.MetricsProvider implementations, such as a Prometheus exporter
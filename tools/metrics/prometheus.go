@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+var (
+	// DefaultLatencyBuckets are the histogram buckets, in seconds, used for request and rate limiter latencies.
+	DefaultLatencyBuckets = []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60}
+
+	// DefaultSizeBuckets are the histogram buckets, in bytes, used for request and response sizes.
+	DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+)
+
+// PrometheusMetrics
+// - implement rest.MetricsProvider and keep the telemetry in memory
+// - implement http.Handler and serve it in the Prometheus text exposition format
+//
+// - 示例:
+//
+//	m := metrics.NewPrometheusMetrics()
+//	config.Metrics = m
+//	http.Handle("/metrics", m)
+type PrometheusMetrics struct {
+	requests        *vec
+	retries         *vec
+	latency         *vec
+	requestSize     *vec
+	responseSize    *vec
+	rateLimiterWait *vec
+	inFlight        *vec
+}
+
+var (
+	_ rest.MetricsProvider = &PrometheusMetrics{}
+	_ http.Handler         = &PrometheusMetrics{}
+)
+
+// NewPrometheusMetrics creates a PrometheusMetrics using the default buckets.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	requestLabels := []string{"verb", "resource", "host"}
+
+	return &PrometheusMetrics{
+		requests: newVec("elmt_sdk_requests_total", "counter",
+			"Number of requests sent to the ELMT server, partitioned by status code.",
+			[]string{"verb", "resource", "host", "code"}, nil),
+		retries: newVec("elmt_sdk_request_retries_total", "counter",
+			"Number of retried attempts made by the ELMT client.",
+			requestLabels, nil),
+		latency: newVec("elmt_sdk_request_duration_seconds", "histogram",
+			"Request latency in seconds, including retries.",
+			requestLabels, DefaultLatencyBuckets),
+		requestSize: newVec("elmt_sdk_request_size_bytes", "histogram",
+			"Request body size in bytes.",
+			requestLabels, DefaultSizeBuckets),
+		responseSize: newVec("elmt_sdk_response_size_bytes", "histogram",
+			"Response body size in bytes.",
+			requestLabels, DefaultSizeBuckets),
+		rateLimiterWait: newVec("elmt_sdk_rate_limiter_duration_seconds", "histogram",
+			"Time spent waiting on the client side rate limiter in seconds.",
+			[]string{"verb", "host"}, DefaultLatencyBuckets),
+		inFlight: newVec("elmt_sdk_requests_in_flight", "gauge",
+			"Number of requests currently being executed.",
+			[]string{"verb", "host"}, nil),
+	}
+}
+
+// ObserveRequest implements rest.MetricsProvider.
+func (p *PrometheusMetrics) ObserveRequest(m rest.RequestMetrics) {
+	code := "<error>"
+	if m.StatusCode != 0 {
+		code = strconv.Itoa(m.StatusCode)
+	}
+
+	p.requests.add(1, m.Verb, m.Resource, m.Host, code)
+	p.retries.add(float64(m.Retries), m.Verb, m.Resource, m.Host)
+	p.latency.observe(m.Latency.Seconds(), m.Verb, m.Resource, m.Host)
+	p.requestSize.observe(float64(m.RequestBytes), m.Verb, m.Resource, m.Host)
+	p.responseSize.observe(float64(m.ResponseBytes), m.Verb, m.Resource, m.Host)
+}
+
+// ObserveRateLimiterWait implements rest.MetricsProvider.
+func (p *PrometheusMetrics) ObserveRateLimiterWait(verb, host string, wait time.Duration) {
+	p.rateLimiterWait.observe(wait.Seconds(), verb, host)
+}
+
+// AddInFlight implements rest.MetricsProvider.
+func (p *PrometheusMetrics) AddInFlight(verb, host string, delta int) {
+	p.inFlight.add(float64(delta), verb, host)
+}
+
+// ServeHTTP implements http.Handler.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+
+	for _, v := range []*vec{
+		p.requests, p.retries, p.latency, p.requestSize, p.responseSize, p.rateLimiterWait, p.inFlight,
+	} {
+		v.write(&buf)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// vec is a metric family partitioned by label values. A nil buckets means a counter or a gauge.
+type vec struct {
+	name    string
+	kind    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64 // counter/gauge value, or histogram sum
+	count       uint64
+	counts      []uint64 // cumulative per bucket
+}
+
+func newVec(name, kind, help string, labels []string, buckets []float64) *vec {
+	return &vec{
+		name:    name,
+		kind:    kind,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+func (v *vec) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+
+	return s
+}
+
+func (v *vec) add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value += delta
+}
+
+func (v *vec) observe(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s := v.get(labelValues)
+	s.value += value
+	s.count++
+
+	for i, upper := range v.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+}
+
+func (v *vec) write(buf *bytes.Buffer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(buf, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", v.name, v.kind)
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := v.series[k]
+		labels := formatLabels(v.labels, s.labelValues)
+
+		if v.kind != "histogram" {
+			fmt.Fprintf(buf, "%s%s %s\n", v.name, labels, formatFloat(s.value))
+			continue
+		}
+
+		for i, upper := range v.buckets {
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", v.name, withLabel(labels, "le", formatFloat(upper)), s.counts[i])
+		}
+
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", v.name, withLabel(labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", v.name, labels, formatFloat(s.value))
+		fmt.Fprintf(buf, "%s_count%s %d\n", v.name, labels, s.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		pairs = append(pairs, formatLabel(name, values[i]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, name, value string) string {
+	pair := formatLabel(name, value)
+	if labels == "{}" {
+		return "{" + pair + "}"
+	}
+
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+// labelValueEscaper escapes a label value the way the text exposition format requires: only the
+// backslash, the double quote and the line feed are escaped, everything else is written as is.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabel(name, value string) string {
+	return name + `="` + labelValueEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
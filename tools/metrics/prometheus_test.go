@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+func TestFormatLabel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "users", want: `l="users"`},
+		{value: `C:\path`, want: `l="C:\\path"`},
+		{value: `say "hi"`, want: `l="say \"hi\""`},
+		{value: "two\nlines", want: `l="two\nlines"`},
+		{value: "tab\there", want: "l=\"tab\there\""},
+		{value: "héllo ☃", want: `l="héllo ☃"`},
+		{value: "\x01", want: "l=\"\x01\""},
+	}
+
+	for _, tt := range tests {
+		if got := formatLabel("l", tt.value); got != tt.want {
+			t.Errorf("formatLabel(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPrometheusMetricsExposition(t *testing.T) {
+	m := NewPrometheusMetrics()
+
+	m.ObserveRequest(rest.RequestMetrics{
+		Verb: "GET", Resource: "users", Host: "elmt:8080", StatusCode: 200,
+		Latency: 30 * time.Millisecond, Retries: 1, RequestBytes: 0, ResponseBytes: 100,
+	})
+	m.ObserveRequest(rest.RequestMetrics{Verb: "GET", Resource: `we"ird`, Host: "elmt:8080"})
+	m.ObserveRateLimiterWait("GET", "elmt:8080", 10*time.Millisecond)
+	m.AddInFlight("GET", "elmt:8080", 2)
+	m.AddInFlight("GET", "elmt:8080", -1)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE elmt_sdk_requests_total counter\n",
+		`elmt_sdk_requests_total{verb="GET",resource="users",host="elmt:8080",code="200"} 1` + "\n",
+		`elmt_sdk_requests_total{verb="GET",resource="we\"ird",host="elmt:8080",code="<error>"} 1` + "\n",
+		`elmt_sdk_request_retries_total{verb="GET",resource="users",host="elmt:8080"} 1` + "\n",
+		"# TYPE elmt_sdk_request_duration_seconds histogram\n",
+		`elmt_sdk_request_duration_seconds_bucket{verb="GET",resource="users",host="elmt:8080",le="0.025"} 0` + "\n",
+		`elmt_sdk_request_duration_seconds_bucket{verb="GET",resource="users",host="elmt:8080",le="0.1"} 1` + "\n",
+		`elmt_sdk_request_duration_seconds_bucket{verb="GET",resource="users",host="elmt:8080",le="+Inf"} 1` + "\n",
+		`elmt_sdk_request_duration_seconds_count{verb="GET",resource="users",host="elmt:8080"} 1` + "\n",
+		`elmt_sdk_response_size_bytes_sum{verb="GET",resource="users",host="elmt:8080"} 100` + "\n",
+		`elmt_sdk_rate_limiter_duration_seconds_count{verb="GET",host="elmt:8080"} 1` + "\n",
+		`elmt_sdk_requests_in_flight{verb="GET",host="elmt:8080"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition misses %q:\n%s", want, body)
+		}
+	}
+}
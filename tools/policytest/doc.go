@@ -0,0 +1,4 @@
+package policytest
+
+// package policytest
+// - test policy files against expected authorization decisions, locally and without an ELMT server
@@ -0,0 +1,168 @@
+package policytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ory/ladon"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/tools/manifest"
+)
+
+// Expected decisions of a TestCase.
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// TestCase is an authorization request and the decision expected from the policies.
+type TestCase struct {
+	Name    string        `json:"name"`
+	Request ladon.Request `json:"request"`
+
+	// Expect is ExpectAllow or ExpectDeny.
+	Expect string `json:"expect"`
+
+	// Policy, when set, is the policy expected to decide, see local.Explanation.DecidingPolicy.
+	Policy string `json:"policy,omitempty"`
+
+	// Source is the document the test case was read from.
+	Source string `json:"-"`
+}
+
+/*
+ * Suite:
+ * - the test cases of one or more test files, each document of a file being of the form
+ *
+ *	tests:
+ *	- name: admins can delete articles
+ *	  request:
+ *	    subject: users:alice
+ *	    action: delete
+ *	    resource: resources:articles:1
+ *	    context:
+ *	      remoteIP: 192.168.0.5
+ *	  expect: allow
+ *	  policy: articles-admin
+ */
+
+type Suite struct {
+	Tests []TestCase `json:"tests"`
+}
+
+// LoadPolicies
+// - read the policies of the given files or directories, see manifest.ReadFiles
+// - a document is either a policy or a policy list with items, as returned by the server
+// - two policies must not have the same name, as the later one would shadow the other
+func LoadPolicies(paths ...string) ([]*v1.Policy, error) {
+	docs, err := manifest.ReadFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []*v1.Policy
+
+	sources := make(map[string]string)
+
+	for _, doc := range docs {
+		items, err := decodePolicies(doc.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", doc, err)
+		}
+
+		for _, policy := range items {
+			if len(policy.Name) > 0 {
+				if source, ok := sources[policy.Name]; ok {
+					return nil, fmt.Errorf("%s: policy %s is already defined in %s", doc, policy.Name, source)
+				}
+
+				sources[policy.Name] = doc.String()
+			}
+
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// decodePolicies decodes a policy, a policy list, or an array of policies.
+func decodePolicies(data json.RawMessage) ([]*v1.Policy, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var items []*v1.Policy
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+
+		return items, nil
+	}
+
+	var probe struct {
+		Items json.RawMessage `json:"items"`
+	}
+
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	if probe.Items != nil {
+		list := &v1.PolicyList{}
+		if err := json.Unmarshal(data, list); err != nil {
+			return nil, err
+		}
+
+		return list.Items, nil
+	}
+
+	policy := &v1.Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+
+	return []*v1.Policy{policy}, nil
+}
+
+// LoadSuite
+// - read the test cases of the given files or directories, see manifest.ReadFiles
+// - the unknown fields are rejected, so that a misspelt field does not silently weaken a test
+// - a test case without a name is named after its position
+func LoadSuite(paths ...string) (*Suite, error) {
+	docs, err := manifest.ReadFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	suite := &Suite{}
+
+	for _, doc := range docs {
+		var s Suite
+
+		decoder := json.NewDecoder(bytes.NewReader(doc.Data))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&s); err != nil {
+			return nil, fmt.Errorf("%s: %v", doc, err)
+		}
+
+		for i := range s.Tests {
+			tc := &s.Tests[i]
+			tc.Source = doc.String()
+
+			if len(tc.Name) == 0 {
+				tc.Name = fmt.Sprintf("%s/%d", doc, i)
+			}
+
+			if tc.Expect != ExpectAllow && tc.Expect != ExpectDeny {
+				return nil, fmt.Errorf("%s: test %q expects %q, it must be %s or %s",
+					doc, tc.Name, tc.Expect, ExpectAllow, ExpectDeny)
+			}
+		}
+
+		suite.Tests = append(suite.Tests, s.Tests...)
+	}
+
+	return suite, nil
+}
@@ -0,0 +1,307 @@
+package policytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ory/ladon"
+)
+
+const testPolicies = `
+metadata:
+  name: read-hosts
+policy:
+  subjects: ["users:<.+>"]
+  actions: [get, list]
+  resources: ["hosts:<.+>"]
+  effect: allow
+---
+items:
+- metadata:
+    name: admins
+  policy:
+    subjects: ["admins:<.+>"]
+    actions: ["<.+>"]
+    resources: ["<.+>"]
+    effect: allow
+- metadata:
+    name: keep-audit
+  policy:
+    subjects: ["<.+>"]
+    actions: [delete]
+    resources: ["audit:<.+>"]
+    effect: deny
+`
+
+// writeFile writes data into a file of dir, and returns its path.
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func testCase(name, subject, action, resource, expect, policy string) TestCase {
+	return TestCase{
+		Name:    name,
+		Request: ladon.Request{Subject: subject, Action: action, Resource: resource},
+		Expect:  expect,
+		Policy:  policy,
+		Source:  "tests.yaml#0",
+	}
+}
+
+func TestRun(t *testing.T) {
+	policies, err := LoadPolicies(writeFile(t, t.TempDir(), "policies.yaml", testPolicies))
+	if err != nil {
+		t.Fatalf("LoadPolicies() failed: %v", err)
+	}
+
+	// broken cannot be evaluated for the get action, which ladon denies even if another policy allows it
+	broken, err := decodePolicies([]byte(`{"metadata":{"name":"broken"},"policy":{"subjects":["users:<[>"],
+		"actions":["get"],"resources":["<.+>"],"effect":"allow"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		test       TestCase
+		withBroken bool
+		wantEffect string
+		wantPassed bool
+	}{
+		{
+			name:       "allowed as expected",
+			test:       testCase("read", "users:alice", "get", "hosts:web-1", ExpectAllow, "read-hosts"),
+			wantEffect: ExpectAllow,
+			wantPassed: true,
+		},
+		{
+			name:       "denied as expected",
+			test:       testCase("delete audit", "admins:bob", "delete", "audit:2024", ExpectDeny, "keep-audit"),
+			wantEffect: ExpectDeny,
+			wantPassed: true,
+		},
+		{
+			name:       "denied by default as expected",
+			test:       testCase("write", "users:alice", "update", "hosts:web-1", ExpectDeny, ""),
+			wantEffect: ExpectDeny,
+			wantPassed: true,
+		},
+		{
+			name:       "unexpected effect",
+			test:       testCase("write", "users:alice", "update", "hosts:web-1", ExpectAllow, ""),
+			wantEffect: ExpectDeny,
+		},
+		{
+			name:       "unexpected deciding policy",
+			test:       testCase("read", "admins:bob", "get", "hosts:web-1", ExpectAllow, "read-hosts"),
+			wantEffect: ExpectAllow,
+		},
+		{
+			name:       "policy failing to evaluate",
+			test:       testCase("read", "users:alice", "get", "hosts:web-1", ExpectAllow, ""),
+			withBroken: true,
+			wantEffect: ExpectDeny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := policies
+			if tt.withBroken {
+				list = append(broken, policies...)
+			}
+
+			report := Run(list, &Suite{Tests: []TestCase{tt.test}})
+
+			if len(report.Results) != 1 {
+				t.Fatalf("%d results, want 1", len(report.Results))
+			}
+
+			result := report.Results[0]
+			if result.Effect != tt.wantEffect || result.Passed != tt.wantPassed {
+				t.Errorf("result = %s, passed %v, want %s, passed %v", result.Effect, result.Passed,
+					tt.wantEffect, tt.wantPassed)
+			}
+
+			if report.OK() != tt.wantPassed || report.Passed+report.Failed != 1 {
+				t.Errorf("report = %d passed, %d failed, OK %v", report.Passed, report.Failed, report.OK())
+			}
+
+			// The explanation always agrees with the decision
+			if result.Explanation.Effect != result.Effect {
+				t.Errorf("explanation effect = %s, decision %s", result.Explanation.Effect, result.Effect)
+			}
+		})
+	}
+}
+
+func TestRunInvalidPolicies(t *testing.T) {
+	policies, err := decodePolicies([]byte(`[{"metadata":{"name":"no-effect"},"policy":{"subjects":["a"],
+		"actions":["get"],"resources":["b"]}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(policies, &Suite{})
+
+	if report.OK() || len(report.Invalid["no-effect"]) != 1 {
+		t.Errorf("report.Invalid = %v, want the missing effect of no-effect", report.Invalid)
+	}
+
+	var out bytes.Buffer
+	if err := report.Print(&out, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "--- INVALID: policy no-effect") ||
+		!strings.Contains(out.String(), "FAIL: 0 of 0 tests failed, 1 invalid policies") {
+		t.Errorf("Print() = %s", out.String())
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	policies, err := LoadPolicies(writeFile(t, t.TempDir(), "policies.yaml", testPolicies))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Run(policies, &Suite{Tests: []TestCase{
+		testCase("read", "users:alice", "get", "hosts:web-1", ExpectAllow, ""),
+		testCase("write", "users:alice", "update", "hosts:web-1", ExpectAllow, "admins"),
+	}})
+
+	tests := []struct {
+		verbose bool
+		want    []string
+		notWant []string
+	}{
+		{
+			want: []string{
+				"--- FAIL: write (tests.yaml#0)",
+				"    request: subject=users:alice action=update resource=hosts:web-1 context=null",
+				"    - effect: allow\n    + effect: deny",
+				"    - policy: admins\n    + policy: <none>",
+				"    Decision: deny (denied by default, no policy matched)",
+				"FAIL: 1 of 2 tests failed, 0 invalid policies",
+			},
+			notWant: []string{"--- PASS"},
+		},
+		{
+			verbose: true,
+			want:    []string{"--- PASS: read\n", "--- FAIL: write"},
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := report.Print(&out, tt.verbose); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Print(%v) = %s, want it to contain %q", tt.verbose, out.String(), want)
+			}
+		}
+
+		for _, notWant := range tt.notWant {
+			if strings.Contains(out.String(), notWant) {
+				t.Errorf("Print(%v) = %s, want it without %q", tt.verbose, out.String(), notWant)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.PrintJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Passed != 1 || decoded.Failed != 1 {
+		t.Errorf("PrintJSON() = %s, %v", out.String(), err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name         string
+		policies     []string
+		tests        string
+		wantPolicies int
+		wantTests    []string
+		wantErr      string
+	}{
+		{
+			name:         "policy, list and test names",
+			policies:     []string{testPolicies},
+			tests:        "tests:\n- name: read\n  request: {subject: a}\n  expect: allow\n- request: {}\n  expect: deny\n",
+			wantPolicies: 3,
+			wantTests:    []string{"read", "tests.yaml#0/1"},
+		},
+		{
+			name:     "policy defined twice",
+			policies: []string{testPolicies, "metadata: {name: admins}\n"},
+			tests:    "tests: []\n",
+			wantErr:  "policy admins is already defined in",
+		},
+		{
+			name:     "unknown field",
+			policies: []string{testPolicies},
+			tests:    "tests:\n- name: read\n  expected: allow\n",
+			wantErr:  `unknown field "expected"`,
+		},
+		{
+			name:     "invalid expectation",
+			policies: []string{testPolicies},
+			tests:    "tests:\n- name: read\n  expect: maybe\n",
+			wantErr:  `test "read" expects "maybe", it must be allow or deny`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for i, data := range tt.policies {
+				writeFile(t, dir, string(rune('a'+i))+".yaml", data)
+			}
+
+			policies, err := LoadPolicies(dir)
+			if err == nil {
+				var suite *Suite
+
+				suite, err = LoadSuite(writeFile(t, t.TempDir(), "tests.yaml", tt.tests))
+				if err == nil {
+					if len(policies) != tt.wantPolicies {
+						t.Errorf("%d policies, want %d", len(policies), tt.wantPolicies)
+					}
+
+					for i, tc := range suite.Tests {
+						if !strings.HasSuffix(tc.Name, tt.wantTests[i]) {
+							t.Errorf("test %d is named %q, want %q", i, tc.Name, tt.wantTests[i])
+						}
+					}
+				}
+			}
+
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(tt.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
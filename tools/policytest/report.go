@@ -0,0 +1,214 @@
+package policytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+	"github.com/opsdata/elmt-sdk/wyvern/service/elmt/authz/local"
+)
+
+// Result is the outcome of a test case.
+type Result struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Passed bool   `json:"passed"`
+
+	Expect string `json:"expect"`
+	Effect string `json:"effect"`
+
+	// ExpectPolicy is the expected deciding policy, if the test case sets one.
+	ExpectPolicy   string `json:"expect_policy,omitempty"`
+	DecidingPolicy string `json:"deciding_policy,omitempty"`
+
+	Explanation *local.Explanation `json:"explanation"`
+}
+
+// Report is the outcome of a suite.
+type Report struct {
+	// Invalid are the validation errors of the policies, by policy name.
+	Invalid map[string][]string `json:"invalid,omitempty"`
+
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+}
+
+// OK returns true if the policies are valid and every test case passed.
+func (r *Report) OK() bool {
+	return len(r.Invalid) == 0 && r.Failed == 0
+}
+
+// Run
+// - validate the policies with ValidatePolicy, then evaluate every test case against them, like the
+// authz server does
+// - a test case passes if the decision, and the deciding policy when it sets one, are the expected ones
+//
+// - 示例:
+//
+//	policies, err := policytest.LoadPolicies("policies/")
+//	suite, err := policytest.LoadSuite("policies/tests.yaml")
+//	report := policytest.Run(policies, suite)
+//	report.Print(os.Stdout, false)
+func Run(policies []*v1.Policy, suite *Suite) *Report {
+	report := &Report{
+		Results: make([]Result, 0, len(suite.Tests)),
+	}
+
+	for _, policy := range policies {
+		errs := apiv1.ValidatePolicy(policy)
+		if len(errs) == 0 {
+			continue
+		}
+
+		if report.Invalid == nil {
+			report.Invalid = make(map[string][]string)
+		}
+
+		for _, err := range errs {
+			report.Invalid[policy.Name] = append(report.Invalid[policy.Name], err.Error())
+		}
+	}
+
+	for i := range suite.Tests {
+		tc := &suite.Tests[i]
+		request := tc.Request
+
+		// The effect of the explanation is the decision of ladon
+		explanation := local.ExplainPolicies(policies, &request)
+
+		result := Result{
+			Name:           tc.Name,
+			Source:         tc.Source,
+			Expect:         tc.Expect,
+			Effect:         explanation.Effect,
+			ExpectPolicy:   tc.Policy,
+			DecidingPolicy: explanation.DecidingPolicy,
+			Explanation:    explanation,
+		}
+
+		result.Passed = result.Effect == result.Expect &&
+			(len(result.ExpectPolicy) == 0 || result.ExpectPolicy == result.DecidingPolicy)
+
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report
+}
+
+// Print
+// - write the invalid policies and the failed test cases, with the difference between the expected
+// and the actual decision and the explanation of the latter
+// - verbose also lists the test cases which passed
+func (r *Report) Print(w io.Writer, verbose bool) error {
+	var buf bytes.Buffer
+
+	for _, name := range sortedKeys(r.Invalid) {
+		fmt.Fprintf(&buf, "--- INVALID: policy %s\n", name)
+
+		for _, msg := range r.Invalid[name] {
+			fmt.Fprintf(&buf, "    %s\n", msg)
+		}
+	}
+
+	for _, result := range r.Results {
+		if result.Passed {
+			if verbose {
+				fmt.Fprintf(&buf, "--- PASS: %s\n", result.Name)
+			}
+
+			continue
+		}
+
+		fmt.Fprintf(&buf, "--- FAIL: %s (%s)\n", result.Name, result.Source)
+		result.printDiff(&buf)
+	}
+
+	switch {
+	case r.OK():
+		fmt.Fprintf(&buf, "PASS: %d tests\n", r.Passed)
+	default:
+		fmt.Fprintf(&buf, "FAIL: %d of %d tests failed, %d invalid policies\n",
+			r.Failed, len(r.Results), len(r.Invalid))
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// PrintJSON writes the report as indented JSON.
+func (r *Report) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func (r Result) printDiff(buf *bytes.Buffer) {
+	request := r.Explanation.Request
+	requestContext, _ := json.Marshal(request.Context)
+
+	fmt.Fprintf(buf, "    request: subject=%s action=%s resource=%s context=%s\n",
+		request.Subject, request.Action, request.Resource, requestContext)
+
+	if r.Effect != r.Expect {
+		fmt.Fprintf(buf, "    - effect: %s\n", r.Expect)
+		fmt.Fprintf(buf, "    + effect: %s\n", r.Effect)
+	}
+
+	if len(r.ExpectPolicy) > 0 && r.ExpectPolicy != r.DecidingPolicy {
+		fmt.Fprintf(buf, "    - policy: %s\n", r.ExpectPolicy)
+		fmt.Fprintf(buf, "    + policy: %s\n", orNone(r.DecidingPolicy))
+	}
+
+	var table bytes.Buffer
+
+	_ = r.Explanation.PrintTable(&table)
+
+	for _, line := range strings.Split(strings.TrimRight(table.String(), "\n"), "\n") {
+		if len(line) == 0 {
+			buf.WriteString("\n")
+			continue
+		}
+
+		fmt.Fprintf(buf, "    %s\n", line)
+	}
+
+	buf.WriteString("\n")
+}
+
+func orNone(s string) string {
+	if len(s) == 0 {
+		return "<none>"
+	}
+
+	return s
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
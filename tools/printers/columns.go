@@ -0,0 +1,258 @@
+package printers
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ory/ladon"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
+)
+
+// AuthzDecision is an authorization request and the response of the server, printed side by side.
+type AuthzDecision struct {
+	Request  *ladon.Request    `json:"request"`
+	Response *authzv1.Response `json:"response"`
+}
+
+func init() {
+	RegisterColumns(&v1.User{},
+		Column{Header: "NAME", Value: func(item interface{}) string { return item.(*v1.User).Name }},
+		Column{Header: "UID", Value: func(item interface{}) string { return strconv.Itoa(item.(*v1.User).UID) }},
+		Column{Header: "ADMIN", Value: func(item interface{}) string { return yesNo(item.(*v1.User).IsAdmin == 1) }},
+		Column{Header: "ACTIVATED", Value: func(item interface{}) string {
+			return yesNo(item.(*v1.User).Activated == 1)
+		}},
+		Column{Header: "CREATED", Value: func(item interface{}) string { return FormatTime(item.(*v1.User).CreatedAt) }},
+		Column{Header: "INSTANCE ID", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.User).InstanceID
+		}},
+		Column{Header: "POLICIES", Wide: true, Value: func(item interface{}) string {
+			return strconv.FormatInt(item.(*v1.User).TotalPolicy, 10)
+		}},
+		Column{Header: "LOGINED AT", Wide: true, Value: func(item interface{}) string {
+			return FormatTime(item.(*v1.User).LoginedAt)
+		}},
+	)
+
+	RegisterColumns(&v1.Secret{},
+		Column{Header: "NAME", Value: func(item interface{}) string { return item.(*v1.Secret).Name }},
+		Column{Header: "USERNAME", Value: func(item interface{}) string { return item.(*v1.Secret).Username }},
+		Column{Header: "SECRET ID", Value: func(item interface{}) string { return item.(*v1.Secret).SecretID }},
+		Column{Header: "EXPIRES", Value: func(item interface{}) string {
+			return FormatExpires(item.(*v1.Secret).Expires)
+		}},
+		Column{Header: "CREATED", Value: func(item interface{}) string {
+			return FormatTime(item.(*v1.Secret).CreatedAt)
+		}},
+		Column{Header: "INSTANCE ID", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.Secret).InstanceID
+		}},
+		Column{Header: "DESCRIPTION", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.Secret).Description
+		}},
+	)
+
+	RegisterColumns(&v1.Policy{},
+		Column{Header: "NAME", Value: func(item interface{}) string { return item.(*v1.Policy).Name }},
+		Column{Header: "USERNAME", Value: func(item interface{}) string { return item.(*v1.Policy).Username }},
+		Column{Header: "EFFECT", Value: func(item interface{}) string { return item.(*v1.Policy).Policy.Effect }},
+		Column{Header: "SUBJECTS", Value: func(item interface{}) string {
+			return strings.Join(item.(*v1.Policy).Policy.Subjects, ",")
+		}},
+		Column{Header: "ACTIONS", Value: func(item interface{}) string {
+			return strings.Join(item.(*v1.Policy).Policy.Actions, ",")
+		}},
+		Column{Header: "RESOURCES", Value: func(item interface{}) string {
+			return strings.Join(item.(*v1.Policy).Policy.Resources, ",")
+		}},
+		Column{Header: "CREATED", Value: func(item interface{}) string {
+			return FormatTime(item.(*v1.Policy).CreatedAt)
+		}},
+		Column{Header: "INSTANCE ID", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.Policy).InstanceID
+		}},
+		Column{Header: "CONDITIONS", Wide: true, Value: func(item interface{}) string {
+			return conditionKeys(item.(*v1.Policy).Policy.Conditions)
+		}},
+		Column{Header: "DESCRIPTION", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.Policy).Policy.Description
+		}},
+	)
+
+	RegisterColumns(&v1.Indicator{},
+		Column{Header: "ITEM", Value: func(item interface{}) string { return item.(*v1.Indicator).ItemName }},
+		Column{Header: "KEY", Value: func(item interface{}) string { return item.(*v1.Indicator).ItemKey }},
+		Column{Header: "TYPE", Value: func(item interface{}) string {
+			return strconv.Itoa(item.(*v1.Indicator).ItemType)
+		}},
+		Column{Header: "COMPONENT", Value: func(item interface{}) string { return item.(*v1.Indicator).Component }},
+		Column{Header: "OBJECT TYPE", Wide: true, Value: func(item interface{}) string {
+			return item.(*v1.Indicator).ObjectType
+		}},
+		Column{Header: "SOURCE", Wide: true, Value: func(item interface{}) string { return item.(*v1.Indicator).Source }},
+		Column{Header: "WEB", Wide: true, Value: func(item interface{}) string { return yesNo(item.(*v1.Indicator).IsWeb) }},
+		Column{Header: "MULTI", Wide: true, Value: func(item interface{}) string {
+			return yesNo(item.(*v1.Indicator).IsMulti)
+		}},
+	)
+
+	RegisterColumns(&v1.ZbxHost{},
+		Column{Header: "HOST", Value: func(item interface{}) string { return item.(*v1.ZbxHost).HostName }},
+		Column{Header: "HOST ID", Value: func(item interface{}) string { return strconv.Itoa(item.(*v1.ZbxHost).HostId) }},
+		Column{Header: "APP ALIAS", Value: func(item interface{}) string { return item.(*v1.ZbxHost).AppAlias }},
+	)
+
+	RegisterColumns(&v1.ZbxItem{},
+		Column{Header: "ITEM", Value: func(item interface{}) string { return item.(*v1.ZbxItem).ItemName }},
+		Column{Header: "KEY", Value: func(item interface{}) string { return item.(*v1.ZbxItem).ItemKey }},
+		Column{Header: "HOST", Value: func(item interface{}) string { return item.(*v1.ZbxItem).HostName }},
+		Column{Header: "ITEM ID", Wide: true, Value: func(item interface{}) string {
+			return strconv.Itoa(item.(*v1.ZbxItem).ItemId)
+		}},
+		Column{Header: "HOST ID", Wide: true, Value: func(item interface{}) string {
+			return strconv.Itoa(item.(*v1.ZbxItem).HostId)
+		}},
+	)
+
+	RegisterColumns(zabbix.Host{},
+		Column{Header: "HOST", Value: func(item interface{}) string { return item.(zabbix.Host).Host }},
+		Column{Header: "NAME", Value: func(item interface{}) string { return item.(zabbix.Host).Name }},
+		Column{Header: "HOST ID", Value: func(item interface{}) string { return item.(zabbix.Host).HostID }},
+		Column{Header: "TAGS", Wide: true, Value: func(item interface{}) string {
+			return formatZabbixTags(item.(zabbix.Host).Tags)
+		}},
+	)
+
+	RegisterColumns(zabbix.Item{},
+		Column{Header: "NAME", Value: func(item interface{}) string { return item.(zabbix.Item).Name }},
+		Column{Header: "KEY", Value: func(item interface{}) string { return item.(zabbix.Item).Key }},
+		Column{Header: "HOST", Value: func(item interface{}) string { return zabbixItemHost(item.(zabbix.Item)) }},
+		Column{Header: "LAST VALUE", Value: func(item interface{}) string { return item.(zabbix.Item).LastValue }},
+		Column{Header: "ITEM ID", Wide: true, Value: func(item interface{}) string { return item.(zabbix.Item).ItemID }},
+		Column{Header: "UNITS", Wide: true, Value: func(item interface{}) string { return item.(zabbix.Item).Units }},
+	)
+
+	RegisterColumns(zabbix.History{},
+		Column{Header: "CLOCK", Value: func(item interface{}) string {
+			h := item.(zabbix.History)
+			clock, err := h.Time()
+			if err != nil {
+				return h.Clock
+			}
+
+			return FormatTime(clock)
+		}},
+		Column{Header: "VALUE", Value: func(item interface{}) string { return item.(zabbix.History).Value }},
+		Column{Header: "ITEM ID", Wide: true, Value: func(item interface{}) string { return item.(zabbix.History).ItemID }},
+	)
+
+	RegisterColumns(&authzv1.Response{},
+		Column{Header: "ALLOWED", Value: func(item interface{}) string { return yesNo(item.(*authzv1.Response).Allowed) }},
+		Column{Header: "REASON", Value: func(item interface{}) string { return responseReason(item.(*authzv1.Response)) }},
+		Column{Header: "DENIED", Wide: true, Value: func(item interface{}) string {
+			return yesNo(item.(*authzv1.Response).Denied)
+		}},
+	)
+
+	RegisterColumns(AuthzDecision{},
+		Column{Header: "SUBJECT", Value: func(item interface{}) string { return item.(AuthzDecision).Request.Subject }},
+		Column{Header: "ACTION", Value: func(item interface{}) string { return item.(AuthzDecision).Request.Action }},
+		Column{Header: "RESOURCE", Value: func(item interface{}) string { return item.(AuthzDecision).Request.Resource }},
+		Column{Header: "ALLOWED", Value: func(item interface{}) string {
+			return yesNo(item.(AuthzDecision).Response.Allowed)
+		}},
+		Column{Header: "REASON", Value: func(item interface{}) string {
+			return responseReason(item.(AuthzDecision).Response)
+		}},
+		Column{Header: "CONTEXT", Wide: true, Value: func(item interface{}) string {
+			data, _ := json.Marshal(item.(AuthzDecision).Request.Context)
+			return string(data)
+		}},
+	)
+}
+
+// FormatTime formats the times of the tables, the zero time being empty.
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// FormatExpires formats the expiration of a secret, a Unix time which is 0 if it never expires.
+func FormatExpires(expires int64) string {
+	if expires == 0 {
+		return "never"
+	}
+
+	t := time.Unix(expires, 0)
+	if t.Before(time.Now()) {
+		return FormatTime(t) + " (expired)"
+	}
+
+	return FormatTime(t)
+}
+
+// responseReason returns the reason of an authorization response, or its error.
+func responseReason(r *authzv1.Response) string {
+	if len(r.Error) > 0 {
+		return r.Error
+	}
+
+	return r.Reason
+}
+
+// conditionKeys returns the keys of the conditions of a policy and their type.
+func conditionKeys(conditions ladon.Conditions) string {
+	keys := make([]string, 0, len(conditions))
+	for key, condition := range conditions {
+		if condition != nil {
+			key += "=" + condition.GetName()
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
+
+// formatZabbixTags returns the tags of a Zabbix object as name=value, or name for an empty value.
+func formatZabbixTags(tags []zabbix.Tag) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = tag.Tag
+		if len(tag.Value) > 0 {
+			formatted[i] += "=" + tag.Value
+		}
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+// zabbixItemHost returns the technical names of the hosts of a Zabbix item, when they were selected.
+func zabbixItemHost(item zabbix.Item) string {
+	hosts := make([]string, len(item.Hosts))
+	for i, host := range item.Hosts {
+		hosts[i] = host.Host
+	}
+
+	return strings.Join(hosts, ",")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
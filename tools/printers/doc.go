@@ -0,0 +1,4 @@
+package printers
+
+// package printers
+// - print the ELMT resources as tables, JSON, YAML, JSONPath or text/template output, for humans and scripts
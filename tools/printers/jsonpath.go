@@ -0,0 +1,397 @@
+package printers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * JSONPath:
+ * - a template of JSONPath expressions between braces, like the one of kubectl
+ * - the expressions are made of .field, ['field'], [index], [*], .* and ..field steps, starting from
+ * the current object, or from the root object with $
+ * - {"text"} prints a quoted string, and {range .items[*]}...{end} repeats a part of the template for
+ * every result of an expression, which becomes the current object
+ * - a result is printed as is when it is a string, as JSON otherwise, the results of an expression
+ * being separated by spaces
+ *
+ *	{range .items[*]}{.metadata.name}{"\t"}{.policy.effect}{"\n"}{end}
+ */
+
+type JSONPath struct {
+	nodes []jsonPathNode
+}
+
+type jsonPathNode struct {
+	// text is printed as is, for the text outside of the braces and the quoted strings
+	text string
+
+	// expr is the expression of a path or a range node
+	expr     string
+	steps    []jsonPathStep
+	rooted   bool
+	wildcard bool
+
+	// children are the nodes repeated by a range node
+	isRange  bool
+	children []jsonPathNode
+}
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+	stepRecursive
+)
+
+type jsonPathStep struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+// ParseJSONPath parses a JSONPath template, such as {.items[*].metadata.name}.
+func ParseJSONPath(template string) (*JSONPath, error) {
+	if len(template) == 0 {
+		return nil, fmt.Errorf("the jsonpath output requires a template, given as jsonpath=TEMPLATE")
+	}
+
+	// stack holds the nodes of the template, then of every open range
+	stack := [][]jsonPathNode{nil}
+	ranges := []jsonPathNode{}
+
+	for len(template) > 0 {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			stack[len(stack)-1] = append(stack[len(stack)-1], jsonPathNode{text: template})
+			break
+		}
+
+		if start > 0 {
+			stack[len(stack)-1] = append(stack[len(stack)-1], jsonPathNode{text: template[:start]})
+		}
+
+		end := closingBrace(template, start+1)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed brace in jsonpath template %q", template[start:])
+		}
+
+		action := strings.TrimSpace(template[start+1 : end])
+		template = template[end+1:]
+
+		switch {
+		case strings.HasPrefix(action, `"`):
+			text, err := strconv.Unquote(action)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in jsonpath template: %v", action, err)
+			}
+
+			stack[len(stack)-1] = append(stack[len(stack)-1], jsonPathNode{text: text})
+		case action == "end":
+			if len(ranges) == 0 {
+				return nil, fmt.Errorf("{end} without {range} in jsonpath template")
+			}
+
+			node := ranges[len(ranges)-1]
+			node.children = stack[len(stack)-1]
+			ranges, stack = ranges[:len(ranges)-1], stack[:len(stack)-1]
+			stack[len(stack)-1] = append(stack[len(stack)-1], node)
+		case strings.HasPrefix(action, "range "):
+			node, err := parseJSONPathExpr(strings.TrimSpace(strings.TrimPrefix(action, "range ")))
+			if err != nil {
+				return nil, err
+			}
+
+			node.isRange = true
+			ranges = append(ranges, node)
+			stack = append(stack, nil)
+		default:
+			node, err := parseJSONPathExpr(action)
+			if err != nil {
+				return nil, err
+			}
+
+			stack[len(stack)-1] = append(stack[len(stack)-1], node)
+		}
+	}
+
+	if len(ranges) > 0 {
+		return nil, fmt.Errorf("{range %s} without {end} in jsonpath template", ranges[len(ranges)-1].expr)
+	}
+
+	return &JSONPath{nodes: stack[0]}, nil
+}
+
+// closingBrace returns the index of the brace closing an action, skipping the quoted strings.
+func closingBrace(s string, from int) int {
+	var quote byte
+
+	for i := from; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseJSONPathExpr(expr string) (jsonPathNode, error) {
+	node := jsonPathNode{expr: expr}
+
+	s := expr
+	switch {
+	case strings.HasPrefix(s, "$"):
+		node.rooted = true
+		s = s[1:]
+	case strings.HasPrefix(s, "@"):
+		s = s[1:]
+	}
+
+	invalid := func(msg string) (jsonPathNode, error) {
+		return jsonPathNode{}, fmt.Errorf("invalid jsonpath expression %q: %s", expr, msg)
+	}
+
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			name, rest := splitName(s[2:])
+			if len(name) == 0 {
+				return invalid("a field name must follow ..")
+			}
+
+			node.steps = append(node.steps, jsonPathStep{kind: stepRecursive, name: name})
+			node.wildcard = true
+			s = rest
+		case strings.HasPrefix(s, ".*"):
+			node.steps = append(node.steps, jsonPathStep{kind: stepWildcard})
+			node.wildcard = true
+			s = s[2:]
+		case s == ".":
+			s = ""
+		case strings.HasPrefix(s, "."):
+			name, rest := splitName(s[1:])
+			if len(name) == 0 {
+				return invalid("a field name must follow .")
+			}
+
+			node.steps = append(node.steps, jsonPathStep{kind: stepField, name: name})
+			s = rest
+		case strings.HasPrefix(s, "["):
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return invalid("unclosed [")
+			}
+
+			step, err := parseBracket(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return invalid(err.Error())
+			}
+
+			node.steps = append(node.steps, step)
+			node.wildcard = node.wildcard || step.kind == stepWildcard
+			s = s[end+1:]
+		case len(node.steps) == 0:
+			// a leading field without a dot, such as items[*]
+			name, rest := splitName(s)
+			if len(name) == 0 {
+				return invalid(fmt.Sprintf("unexpected %q", s))
+			}
+
+			node.steps = append(node.steps, jsonPathStep{kind: stepField, name: name})
+			s = rest
+		default:
+			return invalid(fmt.Sprintf("unexpected %q", s))
+		}
+	}
+
+	return node, nil
+}
+
+// splitName splits the field name at the start of s from the rest of the expression.
+func splitName(s string) (name, rest string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+
+	return s[:end], s[end:]
+}
+
+func parseBracket(s string) (jsonPathStep, error) {
+	switch {
+	case s == "*":
+		return jsonPathStep{kind: stepWildcard}, nil
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return jsonPathStep{kind: stepField, name: s[1 : len(s)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(s)
+	if err != nil {
+		return jsonPathStep{}, fmt.Errorf("[%s] is neither an index, * nor a quoted field name", s)
+	}
+
+	return jsonPathStep{kind: stepIndex, index: index}, nil
+}
+
+// Execute writes the template, evaluated on data, which are JSON values as decoded by encoding/json.
+func (j *JSONPath) Execute(w io.Writer, data interface{}) error {
+	var buf bytes.Buffer
+
+	if err := executeNodes(&buf, j.nodes, data, data); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+func executeNodes(buf *bytes.Buffer, nodes []jsonPathNode, root, current interface{}) error {
+	for _, node := range nodes {
+		if len(node.expr) == 0 && !node.isRange {
+			buf.WriteString(node.text)
+			continue
+		}
+
+		start := current
+		if node.rooted {
+			start = root
+		}
+
+		results := evaluate(node.steps, []interface{}{start})
+		if len(results) == 0 && !node.wildcard {
+			return fmt.Errorf("%s is not found", node.expr)
+		}
+
+		if node.isRange {
+			for _, result := range results {
+				if err := executeNodes(buf, node.children, root, result); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		for i, result := range results {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+
+			buf.WriteString(formatValue(result))
+		}
+	}
+
+	return nil
+}
+
+// evaluate applies the steps to the values, and returns the values found.
+func evaluate(steps []jsonPathStep, values []interface{}) []interface{} {
+	for _, step := range steps {
+		var next []interface{}
+
+		for _, value := range values {
+			switch step.kind {
+			case stepField:
+				if m, ok := value.(map[string]interface{}); ok {
+					if v, ok := m[step.name]; ok {
+						next = append(next, v)
+					}
+				}
+			case stepIndex:
+				if s, ok := value.([]interface{}); ok {
+					i := step.index
+					if i < 0 {
+						i += len(s)
+					}
+
+					if i >= 0 && i < len(s) {
+						next = append(next, s[i])
+					}
+				}
+			case stepWildcard:
+				next = append(next, children(value)...)
+			case stepRecursive:
+				next = append(next, descendants(value, step.name)...)
+			}
+		}
+
+		values = next
+	}
+
+	return values
+}
+
+// children returns the items of an array, or the values of an object sorted by key.
+func children(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+// descendants returns the values of the fields named name, at any depth.
+func descendants(value interface{}, name string) []interface{} {
+	var found []interface{}
+
+	if m, ok := value.(map[string]interface{}); ok {
+		if v, ok := m[name]; ok {
+			found = append(found, v)
+		}
+	}
+
+	for _, child := range children(value) {
+		found = append(found, descendants(child, name)...)
+	}
+
+	return found
+}
+
+// formatValue prints the strings as is and the other values as JSON.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
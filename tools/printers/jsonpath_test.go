@@ -0,0 +1,85 @@
+package printers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+	"kind": "PolicyList",
+	"items": [
+		{"metadata": {"name": "read-hosts"}, "policy": {"effect": "allow", "actions": ["get", "list"]}},
+		{"metadata": {"name": "keep-audit"}, "policy": {"effect": "deny", "actions": ["delete"]}}
+	]
+}`
+
+func TestJSONPath(t *testing.T) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(testDocument), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+		wantErr  string
+	}{
+		{template: "{.kind}", want: "PolicyList"},
+		{template: "kind: {.kind}\n", want: "kind: PolicyList\n"},
+		{template: "{.items[*].metadata.name}", want: "read-hosts keep-audit"},
+		{template: "{items[0].metadata.name}", want: "read-hosts"},
+		{template: "{.items[-1].metadata.name}", want: "keep-audit"},
+		{template: "{.items[0]['metadata'].name}", want: "read-hosts"},
+		{template: "{.items[0].policy.actions}", want: `["get","list"]`},
+		{template: "{.items[1].metadata.*}", want: "keep-audit"},
+		{template: "{..effect}", want: "allow deny"},
+		{template: "{$.kind}", want: "PolicyList"},
+		{template: "{.items[5].metadata.name}", wantErr: "is not found"},
+		{template: "{.items[*].missing}", want: ""},
+		{
+			template: `{range .items[*]}{.metadata.name}{"\t"}{.policy.effect}{"\n"}{end}`,
+			want:     "read-hosts\tallow\nkeep-audit\tdeny\n",
+		},
+		{
+			template: `{range .items[*]}{range .policy.actions[*]}{$.kind}/{@}{" "}{end}{end}`,
+			want:     "PolicyList/get PolicyList/list PolicyList/delete ",
+		},
+		{template: `{"{}"}`, want: "{}"},
+		{template: "", wantErr: "requires a template"},
+		{template: "{.kind", wantErr: "unclosed brace"},
+		{template: "{range .items[*]}{.kind}", wantErr: "without {end}"},
+		{template: "{end}", wantErr: "{end} without {range}"},
+		{template: "{.items[a]}", wantErr: "is neither an index"},
+		{template: "{.items[0}", wantErr: "unclosed ["},
+		{template: "{..}", wantErr: "a field name must follow .."},
+		{template: `{"\q"}`, wantErr: "invalid string"},
+	}
+
+	for _, tt := range tests {
+		jsonPath, err := ParseJSONPath(tt.template)
+
+		var out bytes.Buffer
+		if err == nil {
+			err = jsonPath.Execute(&out, data)
+		}
+
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: error = %v, want %q", tt.template, err, tt.wantErr)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.template, err)
+			continue
+		}
+
+		if out.String() != tt.want {
+			t.Errorf("%q: Execute() = %q, want %q", tt.template, out.String(), tt.want)
+		}
+	}
+}
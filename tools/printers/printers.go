@@ -0,0 +1,272 @@
+package printers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by NewPrinter. The JSONPath and template ones are followed by "=" and
+// their template, such as jsonpath={.items[*].metadata.name}.
+const (
+	OutputTable    = "table"
+	OutputWide     = "wide"
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputJSONPath = "jsonpath"
+	OutputTemplate = "template"
+)
+
+// RedactedValue replaces the values of the sensitive fields.
+const RedactedValue = "<redacted>"
+
+// SensitiveFields are the JSON names of the string fields redacted unless Options.ShowSensitive is set,
+// such as the Password of a v1.User and the SecretKey of a v1.Secret.
+var SensitiveFields = map[string]bool{
+	"password":   true,
+	"secret_key": true,
+}
+
+// Options defines how the objects are printed.
+type Options struct {
+	// NoHeaders omits the headers of the tables.
+	NoHeaders bool
+
+	// ShowSensitive prints the values of SensitiveFields instead of RedactedValue.
+	ShowSensitive bool
+}
+
+// Printer prints objects, such as the v1.UserList returned by a List call.
+type Printer interface {
+	PrintObj(obj interface{}, w io.Writer) error
+}
+
+// PrinterFunc is a function implementing Printer.
+type PrinterFunc func(obj interface{}, w io.Writer) error
+
+// PrintObj calls fn.
+func (fn PrinterFunc) PrintObj(obj interface{}, w io.Writer) error {
+	return fn(obj, w)
+}
+
+// NewPrinter
+// - return the printer of an output format, which is one of the Output constants
+// - "go-template=" is accepted for "template=", the templates being executed on the JSON fields of
+// the objects, such as {{.metadata.name}}
+//
+// - 示例:
+//
+//	printer, err := printers.NewPrinter("jsonpath={.items[*].metadata.name}", printers.Options{})
+//	list, err := clientset.Elmt().APIV1().Users().List(ctx, metav1.ListOptions{})
+//	err = printer.PrintObj(list, os.Stdout)
+func NewPrinter(output string, options Options) (Printer, error) {
+	format, arg := output, ""
+	if i := strings.Index(output, "="); i >= 0 {
+		format, arg = output[:i], output[i+1:]
+	}
+
+	var printer Printer
+
+	switch format {
+	case OutputTable, "":
+		return &TablePrinter{NoHeaders: options.NoHeaders}, nil
+	case OutputWide:
+		return &TablePrinter{Wide: true, NoHeaders: options.NoHeaders}, nil
+	case OutputJSON:
+		printer = PrinterFunc(printJSON)
+	case OutputYAML:
+		printer = PrinterFunc(printYAML)
+	case OutputJSONPath:
+		jsonPath, err := ParseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		printer = PrinterFunc(func(obj interface{}, w io.Writer) error {
+			data, err := toJSONValue(obj)
+			if err != nil {
+				return err
+			}
+
+			return jsonPath.Execute(w, data)
+		})
+	case OutputTemplate, "go-template":
+		if len(arg) == 0 {
+			return nil, fmt.Errorf("the %s output requires a template, given as %s=TEMPLATE", format, format)
+		}
+
+		tmpl, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		printer = PrinterFunc(func(obj interface{}, w io.Writer) error {
+			data, err := toJSONValue(obj)
+			if err != nil {
+				return err
+			}
+
+			return tmpl.Execute(w, data)
+		})
+	default:
+		return nil, fmt.Errorf("unsupported output format %q, it must be one of table, wide, json, yaml, "+
+			"jsonpath=TEMPLATE or template=TEMPLATE", output)
+	}
+
+	if options.ShowSensitive {
+		return printer, nil
+	}
+
+	return PrinterFunc(func(obj interface{}, w io.Writer) error {
+		return printer.PrintObj(Redact(obj), w)
+	}), nil
+}
+
+// printJSON does not escape the <> delimiters of the policies, nor the RedactedValue.
+func printJSON(obj interface{}, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(obj)
+}
+
+// printYAML goes through JSON, so that the fields are named after their json tags.
+func printYAML(obj interface{}, w io.Writer) error {
+	value, err := toJSONValue(obj)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// toJSONValue returns the JSON fields of an object, on which the JSONPath and the templates are executed.
+func toJSONValue(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Redact
+// - return a copy of obj whose non-empty SensitiveFields are set to RedactedValue
+// - obj is returned as is if it has no sensitive field, or if it cannot be copied
+func Redact(obj interface{}) interface{} {
+	if obj == nil || !hasSensitiveFields(reflect.TypeOf(obj), map[reflect.Type]bool{}) {
+		return obj
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return obj
+	}
+
+	t := reflect.TypeOf(obj)
+	isPtr := t.Kind() == reflect.Ptr
+
+	if isPtr {
+		t = t.Elem()
+	}
+
+	copied := reflect.New(t)
+	if err := json.Unmarshal(data, copied.Interface()); err != nil {
+		return obj
+	}
+
+	redactValue(copied)
+
+	if isPtr {
+		return copied.Interface()
+	}
+
+	return copied.Elem().Interface()
+}
+
+// hasSensitiveFields tells whether values of type t may hold sensitive fields.
+func hasSensitiveFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return hasSensitiveFields(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Type.Kind() == reflect.String && SensitiveFields[jsonName(f)] {
+				return true
+			}
+
+			if hasSensitiveFields(f.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func redactValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			redactValue(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+
+			if field.Kind() == reflect.String && SensitiveFields[jsonName(t.Field(i))] {
+				if field.Len() > 0 {
+					field.SetString(RedactedValue)
+				}
+
+				continue
+			}
+
+			redactValue(field)
+		}
+	}
+}
+
+// jsonName returns the JSON name of a struct field.
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if len(name) == 0 {
+		return f.Name
+	}
+
+	return name
+}
@@ -0,0 +1,178 @@
+package printers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+)
+
+func testUsers() *v1.UserList {
+	return &v1.UserList{
+		ListMeta: metav1.ListMeta{TotalCount: 2},
+		Items: []*v1.User{
+			{ObjectMeta: metav1.ObjectMeta{Name: "alice", InstanceID: "user-a"}, UID: 1, Password: "alice-hash",
+				IsAdmin: 1},
+			{ObjectMeta: metav1.ObjectMeta{Name: "bob", InstanceID: "user-b"}, UID: 2},
+		},
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		options Options
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name:    "table",
+			output:  OutputTable,
+			want:    []string{"NAME", "alice", "bob"},
+			notWant: []string{"INSTANCE ID", "alice-hash"},
+		},
+		{
+			name:    "default",
+			want:    []string{"NAME", "alice"},
+			notWant: []string{"INSTANCE ID"},
+		},
+		{
+			name:    "wide without headers",
+			output:  OutputWide,
+			options: Options{NoHeaders: true},
+			want:    []string{"user-a", "user-b"},
+			notWant: []string{"NAME"},
+		},
+		{
+			name:    "json",
+			output:  OutputJSON,
+			want:    []string{`"name": "alice"`, `"password": "<redacted>"`},
+			notWant: []string{"alice-hash"},
+		},
+		{
+			name:    "json with secrets",
+			output:  OutputJSON,
+			options: Options{ShowSensitive: true},
+			want:    []string{`"password": "alice-hash"`},
+		},
+		{
+			name:    "yaml",
+			output:  OutputYAML,
+			want:    []string{"name: alice", "password: <redacted>", "totalCount: 2"},
+			notWant: []string{"alice-hash"},
+		},
+		{
+			name:   "jsonpath",
+			output: "jsonpath={.items[*].metadata.name}",
+			want:   []string{"alice bob"},
+		},
+		{
+			name:    "jsonpath redacted",
+			output:  "jsonpath={.items[0].password}",
+			want:    []string{RedactedValue},
+			notWant: []string{"alice-hash"},
+		},
+		{
+			name:   "template",
+			output: "template={{range .items}}{{.metadata.name}};{{end}}",
+			want:   []string{"alice;bob;"},
+		},
+		{
+			name:   "go-template",
+			output: "go-template={{len .items}}",
+			want:   []string{"2"},
+		},
+		{
+			name:    "jsonpath without template",
+			output:  OutputJSONPath,
+			wantErr: "requires a template",
+		},
+		{
+			name:    "template without template",
+			output:  OutputTemplate,
+			wantErr: "requires a template",
+		},
+		{
+			name:    "invalid template",
+			output:  "template={{.items",
+			wantErr: "unclosed action",
+		},
+		{
+			name:    "unsupported output",
+			output:  "xml",
+			wantErr: `unsupported output format "xml"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			printer, err := NewPrinter(tt.output, tt.options)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewPrinter() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewPrinter() failed: %v", err)
+			}
+
+			var out bytes.Buffer
+			if err := printer.PrintObj(testUsers(), &out); err != nil {
+				t.Fatalf("PrintObj() failed: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("PrintObj() = %s, want it to contain %q", out.String(), want)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("PrintObj() = %s, want it without %q", out.String(), notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	users := testUsers()
+
+	redacted, ok := Redact(users).(*v1.UserList)
+	if !ok {
+		t.Fatalf("Redact() = %T, want *v1.UserList", Redact(users))
+	}
+
+	if redacted.Items[0].Password != RedactedValue || redacted.Items[0].Name != "alice" {
+		t.Errorf("Redact() = %+v, want the password redacted", redacted.Items[0])
+	}
+
+	// The empty values are kept, and the object is not modified
+	if redacted.Items[1].Password != "" || users.Items[0].Password != "alice-hash" {
+		t.Errorf("passwords = %q, %q, want an empty one and the original", redacted.Items[1].Password,
+			users.Items[0].Password)
+	}
+
+	secret, _ := Redact(v1.Secret{SecretID: "id", SecretKey: "key"}).(v1.Secret)
+	if secret.SecretKey != RedactedValue || secret.SecretID != "id" {
+		t.Errorf("Redact() = %+v, want the secret key redacted", secret)
+	}
+
+	// The objects without sensitive fields are returned as they are
+	policy := &v1.Policy{}
+	if got := Redact(policy); got != policy {
+		t.Errorf("Redact() = %v, want the policy itself", got)
+	}
+
+	if got := Redact(nil); got != nil {
+		t.Errorf("Redact(nil) = %v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package printers
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Column is a column of the table of a type.
+type Column struct {
+	Header string
+
+	// Wide columns are only printed by the wide output.
+	Wide bool
+
+	// Value returns the cell of an item, which is of the type the column was registered for.
+	Value func(item interface{}) string
+}
+
+var (
+	columnsMu sync.RWMutex
+	columns   = map[reflect.Type][]Column{}
+)
+
+// RegisterColumns
+// - register the table columns of the type of item, replacing the previous ones
+// - the lists of that type, whose Items field is a slice of it, are printed with the same columns
+//
+// - 示例:
+//
+//	printers.RegisterColumns(&v1.ZbxHost{},
+//		printers.Column{Header: "HOST", Value: func(item interface{}) string {
+//			return item.(*v1.ZbxHost).HostName
+//		}},
+//	)
+func RegisterColumns(item interface{}, cols ...Column) {
+	columnsMu.Lock()
+	defer columnsMu.Unlock()
+
+	columns[reflect.TypeOf(item)] = cols
+}
+
+func columnsOf(t reflect.Type) ([]Column, bool) {
+	columnsMu.RLock()
+	defer columnsMu.RUnlock()
+
+	cols, ok := columns[t]
+
+	return cols, ok
+}
+
+/*
+ * TablePrinter:
+ * - print an object, a slice or a list with an Items field as a table, with the columns registered
+ * for the type of the items
+ * - the tables never show the sensitive fields
+ */
+
+type TablePrinter struct {
+	// Wide also prints the wide columns.
+	Wide bool
+
+	// NoHeaders omits the header line.
+	NoHeaders bool
+}
+
+func (p *TablePrinter) PrintObj(obj interface{}, w io.Writer) error {
+	items, itemType := tableItems(obj)
+
+	cols, ok := columnsOf(itemType)
+	if !ok {
+		return fmt.Errorf("no table columns are registered for %v", itemType)
+	}
+
+	if !p.Wide {
+		narrow := make([]Column, 0, len(cols))
+		for _, col := range cols {
+			if !col.Wide {
+				narrow = append(narrow, col)
+			}
+		}
+
+		cols = narrow
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if !p.NoHeaders {
+		headers := make([]string, len(cols))
+		for i, col := range cols {
+			headers[i] = col.Header
+		}
+
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+
+	cells := make([]string, len(cols))
+
+	for _, item := range items {
+		for i, col := range cols {
+			cells[i] = cleanCell(col.Value(item))
+		}
+
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// tableItems returns the items of obj and their type.
+func tableItems(obj interface{}) ([]interface{}, reflect.Type) {
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if _, ok := columnsOf(v.Type()); ok {
+		return []interface{}{obj}, v.Type()
+	}
+
+	list := v
+	if list.Kind() == reflect.Ptr && !list.IsNil() && list.Elem().Kind() == reflect.Struct {
+		if items := list.Elem().FieldByName("Items"); items.IsValid() && items.Kind() == reflect.Slice {
+			list = items
+		}
+	}
+
+	if list.Kind() != reflect.Slice {
+		return []interface{}{obj}, v.Type()
+	}
+
+	items := make([]interface{}, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		if item := list.Index(i); item.Kind() != reflect.Ptr || !item.IsNil() {
+			items = append(items, item.Interface())
+		}
+	}
+
+	return items, list.Type().Elem()
+}
+
+// cleanCell keeps a cell on one line, so that it does not break the table.
+func cleanCell(s string) string {
+	if len(s) == 0 {
+		return "<none>"
+	}
+
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,157 @@
+package printers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
+)
+
+// tableLines prints obj as a table, and returns its lines with their cells separated by single spaces.
+func tableLines(t *testing.T, printer *TablePrinter, obj interface{}) []string {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := printer.PrintObj(obj, &out); err != nil {
+		t.Fatalf("PrintObj() failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+
+	return lines
+}
+
+func TestTablePrinter(t *testing.T) {
+	clock := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		printer *TablePrinter
+		obj     interface{}
+		want    []string
+	}{
+		{
+			name:    "list",
+			printer: &TablePrinter{},
+			obj:     testUsers(),
+			want:    []string{"NAME UID ADMIN ACTIVATED CREATED", "alice 1 yes no <none>", "bob 2 no no <none>"},
+		},
+		{
+			name:    "wide object without headers",
+			printer: &TablePrinter{Wide: true, NoHeaders: true},
+			obj:     testUsers().Items[0],
+			want:    []string{"alice 1 yes no <none> user-a 0 <none>"},
+		},
+		{
+			name:    "slice skipping nil items",
+			printer: &TablePrinter{NoHeaders: true},
+			obj:     []*v1.User{nil, {ObjectMeta: metav1.ObjectMeta{Name: "carol"}, UID: 3}},
+			want:    []string{"carol 3 no no <none>"},
+		},
+		{
+			name:    "cell on one line",
+			printer: &TablePrinter{Wide: true, NoHeaders: true},
+			obj: &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "key"}, Username: "alice", SecretID: "id",
+				Description: "line 1\nline 2"},
+			want: []string{"key alice id never <none> <none> line 1 line 2"},
+		},
+		{
+			name:    "zabbix hosts",
+			printer: &TablePrinter{Wide: true},
+			obj: []zabbix.Host{{HostID: "10084", Host: "web-1", Name: "Web 1",
+				Tags: []zabbix.Tag{{Tag: "env", Value: "prod"}, {Tag: "web"}}}},
+			want: []string{"HOST NAME HOST ID TAGS", "web-1 Web 1 10084 env=prod,web"},
+		},
+		{
+			name:    "zabbix items",
+			printer: &TablePrinter{Wide: true, NoHeaders: true},
+			obj: []zabbix.Item{
+				{ItemID: "23296", Name: "CPU load", Key: "system.cpu.load", LastValue: "0.5", Units: "%",
+					Hosts: []zabbix.Host{{Host: "web-1"}, {Host: "web-2"}}},
+				{ItemID: "23297", Name: "Free memory", Key: "vm.memory.size"},
+			},
+			want: []string{
+				"CPU load system.cpu.load web-1,web-2 0.5 23296 %",
+				"Free memory vm.memory.size <none> <none> 23297 <none>",
+			},
+		},
+		{
+			name:    "zabbix history",
+			printer: &TablePrinter{NoHeaders: true},
+			obj: []zabbix.History{
+				{ItemID: "23296", Clock: "1709296200", Value: "0.5"},
+				{ItemID: "23296", Clock: "soon", Value: "0.7"},
+			},
+			want: []string{FormatTime(clock) + " 0.5", "soon 0.7"},
+		},
+		{
+			name:    "authorization decisions",
+			printer: &TablePrinter{Wide: true, NoHeaders: true},
+			obj: []AuthzDecision{
+				{Request: &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:1"},
+					Response: &authzv1.Response{Allowed: true}},
+				{Request: &ladon.Request{Subject: "users:alice", Action: "delete", Resource: "hosts:1",
+					Context: ladon.Context{"ip": "10.0.0.1"}},
+					Response: &authzv1.Response{Denied: true, Reason: "denied", Error: "policy failed"}},
+			},
+			want: []string{
+				"users:alice get hosts:1 yes <none> null",
+				`users:alice delete hosts:1 no policy failed {"ip":"10.0.0.1"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := tableLines(t, tt.printer, tt.obj)
+
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("PrintObj() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestTablePrinterUnregistered(t *testing.T) {
+	var out bytes.Buffer
+
+	err := (&TablePrinter{}).PrintObj([]string{"a"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "no table columns are registered for string") {
+		t.Errorf("PrintObj() error = %v, want no columns registered", err)
+	}
+}
+
+func TestFormatExpires(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		expires int64
+		want    string
+	}{
+		{expires: 0, want: "never"},
+		{expires: future, want: FormatTime(time.Unix(future, 0))},
+		{expires: past, want: FormatTime(time.Unix(past, 0)) + " (expired)"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatExpires(tt.expires); got != tt.want {
+			t.Errorf("FormatExpires(%d) = %q, want %q", tt.expires, got, tt.want)
+		}
+	}
+
+	if got := FormatTime(time.Time{}); got != "" {
+		t.Errorf("FormatTime() of the zero time = %q, want it empty", got)
+	}
+}
@@ -0,0 +1,5 @@
+package proxy
+
+// package proxy
+// - forward the requests of local clients, such as a browser or curl, to an ELMT server with the
+// credentials of an elmtconfig, so that the clients never handle them
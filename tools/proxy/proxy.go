@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	apiserverv1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// DefaultAddress is the address the proxy listens on when none is given, which is only reachable
+// from the local host.
+const DefaultAddress = "127.0.0.1:8001"
+
+// DefaultAcceptHosts are the hosts accepted in the Host header of the requests when
+// Options.AcceptHosts is nil, they keep the pages of other sites from reaching the proxy through
+// DNS rebinding.
+var DefaultAcceptHosts = []string{`^localhost$`, `^127\.0\.0\.1$`, `^\[::1\]$`}
+
+// authzPath is the path of the authorization endpoints, which are served for the authz group.
+var authzPath = path.Join("/", authzv1.SchemeGroupVersion.Version, "authz")
+
+// Options restrict the requests forwarded by a Proxy.
+type Options struct {
+	// AllowedPaths are the path prefixes of the requests forwarded, such as /v1/users, a prefix
+	// matching whole path segments. Every path is forwarded when empty.
+	AllowedPaths []string
+
+	// AllowedMethods are the HTTP methods of the requests forwarded, such as GET. Every method
+	// is forwarded when empty.
+	AllowedMethods []string
+
+	// AcceptHosts are the regular expressions the host of the Host header of a request, without
+	// its port, must match. DefaultAcceptHosts if nil, and every host is accepted when empty.
+	AcceptHosts []string
+
+	// ErrorLog receives the errors of the requests forwarded, the standard logger if nil.
+	ErrorLog *log.Logger
+}
+
+/*
+ * Proxy:
+ * - an http.Handler forwarding the requests to the server of a rest.Config
+ * - the Authorization header of a request is replaced by the one of the credentials of the
+ * config, as set by rest.NewRequest, and a JWT signed with a secret ID and key is made for every request
+ * - the requests are sent through the TLS, proxy and timeout settings of the config
+ * - the requests which are not allowed by the Options are answered with 403 Forbidden
+ */
+
+type Proxy struct {
+	content rest.ClientContentConfig
+	target  *url.URL
+
+	paths   []string
+	methods map[string]bool
+	hosts   []*regexp.Regexp
+
+	reverseProxy *httputil.ReverseProxy
+}
+
+// New creates a Proxy to the server of config.
+func New(config *rest.Config, opts Options) (*Proxy, error) {
+	c := *config
+	if c.GroupVersion == nil {
+		gv := apiserverv1.SchemeGroupVersion
+		c.GroupVersion = &gv
+	}
+
+	target, err := rest.ServerURLFor(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := rest.TransportFor(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		content: rest.ClientContentConfig{
+			Username:        c.Username,
+			Password:        c.Password,
+			SecretID:        c.SecretID,
+			SecretKey:       c.SecretKey,
+			BearerToken:     c.BearerToken,
+			BearerTokenFile: c.BearerTokenFile,
+		},
+		target:  target,
+		methods: map[string]bool{},
+	}
+
+	// fail now rather than on every request when the credentials conflict
+	if _, err := p.content.AuthorizationHeader(apiserverv1.GroupName); err != nil {
+		return nil, err
+	}
+
+	for _, prefix := range opts.AllowedPaths {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("allowed path %q must start with /", prefix)
+		}
+
+		p.paths = append(p.paths, path.Clean(prefix))
+	}
+
+	for _, method := range opts.AllowedMethods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+
+	acceptHosts := opts.AcceptHosts
+	if acceptHosts == nil {
+		acceptHosts = DefaultAcceptHosts
+	}
+
+	for _, expr := range acceptHosts {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid accepted host %q: %v", expr, err)
+		}
+
+		p.hosts = append(p.hosts, re)
+	}
+
+	p.reverseProxy = &httputil.ReverseProxy{
+		Director:  p.direct,
+		Transport: transport,
+		ErrorLog:  opts.ErrorLog,
+	}
+
+	return p, nil
+}
+
+// ServeHTTP forwards the request to the server if it is allowed.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.acceptHost(r.Host) {
+		http.Error(w, fmt.Sprintf("host %q is not accepted", r.Host), http.StatusForbidden)
+		return
+	}
+
+	if !p.allowed(r.Method, r.URL.Path) {
+		http.Error(w, fmt.Sprintf("%s %s is not allowed", r.Method, r.URL.Path), http.StatusForbidden)
+		return
+	}
+
+	p.reverseProxy.ServeHTTP(w, r)
+}
+
+// direct rewrites a request for the server, with the credentials of the config.
+func (p *Proxy) direct(r *http.Request) {
+	group := apiserverv1.GroupName
+	if hasPathPrefix(r.URL.Path, authzPath) {
+		group = authzv1.GroupName
+	}
+
+	r.URL.Scheme = p.target.Scheme
+	r.URL.Host = p.target.Host
+	r.URL.Path = path.Join("/", p.target.Path, r.URL.Path)
+	r.URL.RawPath = ""
+	r.Host = p.target.Host
+
+	// the credentials of the clients, if any, are never forwarded
+	r.Header.Del("Authorization")
+	r.Header.Del("Cookie")
+
+	if _, ok := r.Header["User-Agent"]; !ok {
+		// keep the transport from adding its own
+		r.Header.Set("User-Agent", "")
+	}
+
+	// New checked the credentials, the error cannot happen
+	if authorization, _ := p.content.AuthorizationHeader(group); len(authorization) > 0 {
+		r.Header.Set("Authorization", authorization)
+	}
+}
+
+// acceptHost tells whether the host of a Host header matches one of the accepted hosts.
+func (p *Proxy) acceptHost(hostport string) bool {
+	if len(p.hosts) == 0 {
+		return true
+	}
+
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+
+	for _, re := range p.hosts {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed tells whether a request of method to urlPath may be forwarded.
+func (p *Proxy) allowed(method, urlPath string) bool {
+	if len(p.methods) > 0 && !p.methods[method] {
+		return false
+	}
+
+	if len(p.paths) == 0 {
+		return true
+	}
+
+	// a cleaned path cannot escape an allowed prefix with ..
+	urlPath = path.Clean("/" + urlPath)
+
+	for _, prefix := range p.paths {
+		if hasPathPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPathPrefix tells whether p starts with the segments of prefix.
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
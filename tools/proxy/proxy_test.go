@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// forwarded is a request as received by the server behind the proxy.
+type forwarded struct {
+	method, path, authorization, cookie, userAgent string
+}
+
+// newBackend stands in for the ELMT server, and sends the requests it receives to the channel.
+func newBackend(t *testing.T) (*httptest.Server, chan forwarded) {
+	t.Helper()
+
+	received := make(chan forwarded, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- forwarded{
+			method:        r.Method,
+			path:          r.URL.Path,
+			authorization: r.Header.Get("Authorization"),
+			cookie:        r.Header.Get("Cookie"),
+			userAgent:     r.Header.Get("User-Agent"),
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, received
+}
+
+// jwtClaims returns the key ID and the claims of a Bearer JWT, without checking its signature.
+func jwtClaims(t *testing.T, authorization string) (kid string, claims map[string]interface{}) {
+	t.Helper()
+
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		t.Fatalf("Authorization = %q, want a Bearer JWT", authorization)
+	}
+
+	var header map[string]interface{}
+
+	for i, v := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kid, _ = header["kid"].(string)
+
+	return kid, claims
+}
+
+func TestProxy(t *testing.T) {
+	backend, received := newBackend(t)
+
+	tests := []struct {
+		name   string
+		config rest.Config
+		opts   Options
+		method string
+		host   string
+		path   string
+		// header is set on the request sent to the proxy
+		header     http.Header
+		wantStatus int
+		want       forwarded
+		// wantAudience is the audience of the JWT signed with a secret
+		wantAudience string
+	}{
+		{
+			name:       "basic auth",
+			config:     rest.Config{Username: "alice", Password: "passw0rd"},
+			path:       "/v1/users",
+			wantStatus: http.StatusOK,
+			want: forwarded{method: http.MethodGet, path: "/v1/users",
+				authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:passw0rd"))},
+		},
+		{
+			name:       "credentials of the client replaced",
+			config:     rest.Config{BearerToken: "t0ken"},
+			path:       "/v1/users",
+			header:     http.Header{"Authorization": {"Bearer other"}, "Cookie": {"session=1"}, "User-Agent": {"curl"}},
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/v1/users", authorization: "Bearer t0ken", userAgent: "curl"},
+		},
+		{
+			name:       "credentials of the client removed",
+			path:       "/v1/users",
+			header:     http.Header{"Authorization": {"Bearer other"}},
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/v1/users"},
+		},
+		{
+			name:         "secret signed for the api group",
+			config:       rest.Config{SecretID: "id", SecretKey: "key"},
+			path:         "/v1/secrets",
+			wantStatus:   http.StatusOK,
+			want:         forwarded{method: http.MethodGet, path: "/v1/secrets"},
+			wantAudience: "api.elmt",
+		},
+		{
+			name:         "secret signed for the authz group",
+			config:       rest.Config{SecretID: "id", SecretKey: "key"},
+			method:       http.MethodPost,
+			path:         "/v1/authz/batch",
+			wantStatus:   http.StatusOK,
+			want:         forwarded{method: http.MethodPost, path: "/v1/authz/batch"},
+			wantAudience: "elmt.authz.elmt",
+		},
+		{
+			name:       "path of the server",
+			config:     rest.Config{Host: backend.URL + "/elmt"},
+			path:       "/v1/users",
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/elmt/v1/users"},
+		},
+		{
+			name:       "allowed path",
+			opts:       Options{AllowedPaths: []string{"/v1/users/"}, AllowedMethods: []string{"get"}},
+			path:       "/v1/users/alice",
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/v1/users/alice"},
+		},
+		{
+			name:       "path sharing the prefix of an allowed path",
+			opts:       Options{AllowedPaths: []string{"/v1/users"}},
+			path:       "/v1/userspace",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "path escaping an allowed path",
+			opts:       Options{AllowedPaths: []string{"/v1/users"}},
+			path:       "/v1/users/../secrets",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "method not allowed",
+			opts:       Options{AllowedMethods: []string{"GET"}},
+			method:     http.MethodDelete,
+			path:       "/v1/users/alice",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "host not accepted",
+			host:       "evil.example.com",
+			path:       "/v1/users",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "IPv6 loopback accepted",
+			host:       "[::1]:8001",
+			path:       "/v1/users",
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/v1/users"},
+		},
+		{
+			name:       "every host accepted",
+			opts:       Options{AcceptHosts: []string{}},
+			host:       "evil.example.com",
+			path:       "/v1/users",
+			wantStatus: http.StatusOK,
+			want:       forwarded{method: http.MethodGet, path: "/v1/users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if len(config.Host) == 0 {
+				config.Host = backend.URL
+			}
+
+			p, err := New(&config, tt.opts)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+
+			method, host := tt.method, tt.host
+			if len(method) == 0 {
+				method = http.MethodGet
+			}
+
+			if len(host) == 0 {
+				host = "localhost:8001"
+			}
+
+			r := httptest.NewRequest(method, "http://"+host+tt.path, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				if len(received) > 0 {
+					t.Errorf("the request was forwarded: %+v", <-received)
+				}
+
+				return
+			}
+
+			got := <-received
+			if len(tt.wantAudience) > 0 {
+				kid, claims := jwtClaims(t, got.authorization)
+				if kid != "id" || claims["aud"] != tt.wantAudience || claims["iss"] != "elmt-sdk" {
+					t.Errorf("JWT kid = %s, claims = %v, want audience %s", kid, claims, tt.wantAudience)
+				}
+
+				got.authorization = ""
+			}
+
+			if got != tt.want {
+				t.Errorf("forwarded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  rest.Config
+		opts    Options
+		wantErr string
+	}{
+		{
+			name:    "conflicting credentials",
+			config:  rest.Config{Host: "http://127.0.0.1:8080", BearerToken: "t0ken", Username: "alice", Password: "p"},
+			wantErr: "should use only one of them",
+		},
+		{
+			name:    "relative path",
+			config:  rest.Config{Host: "http://127.0.0.1:8080"},
+			opts:    Options{AllowedPaths: []string{"v1/users"}},
+			wantErr: `allowed path "v1/users" must start with /`,
+		},
+		{
+			name:    "invalid host expression",
+			config:  rest.Config{Host: "http://127.0.0.1:8080"},
+			opts:    Options{AcceptHosts: []string{"("}},
+			wantErr: `invalid accepted host "("`,
+		},
+		{
+			name:    "socket without path",
+			config:  rest.Config{Host: "unix://"},
+			wantErr: "unix socket host has no path",
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(&tt.config, tt.opts); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: New() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProxyUnreachableServer(t *testing.T) {
+	p, err := New(&rest.Config{Host: "http://127.0.0.1:1"}, Options{ErrorLog: log.New(ioutil.Discard, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/v1/users", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+}
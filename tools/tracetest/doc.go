@@ -0,0 +1,4 @@
+package tracetest
+
+// package tracetest
+// - provide an in-memory span exporter to assert on the client spans of the rest package in unit tests
@@ -0,0 +1,54 @@
+package tracetest
+
+import (
+	"sync"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// SpanRecorder is a rest.SpanExporter keeping every exported span in memory.
+//
+// - 示例:
+//
+//	recorder := tracetest.NewSpanRecorder()
+//	config.Tracer = rest.NewTracer(recorder)
+//	...
+//	spans := recorder.Spans()
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []rest.SpanData
+}
+
+var _ rest.SpanExporter = &SpanRecorder{}
+
+// NewSpanRecorder returns an empty SpanRecorder.
+func NewSpanRecorder() *SpanRecorder {
+	return &SpanRecorder{}
+}
+
+// ExportSpan implements rest.SpanExporter.
+func (r *SpanRecorder) ExportSpan(span rest.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = append(r.spans, span)
+}
+
+// Spans returns a copy of the recorded spans, in the order they ended.
+func (r *SpanRecorder) Spans() []rest.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spans := make([]rest.SpanData, len(r.spans))
+	copy(spans, r.spans)
+
+	return spans
+}
+
+// Reset drops the recorded spans.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = nil
+}
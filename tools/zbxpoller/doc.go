@@ -0,0 +1,4 @@
+package zbxpoller
+
+// package zbxpoller
+// - poll the history of Zabbix items and stream their new samples on a channel
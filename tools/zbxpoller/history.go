@@ -0,0 +1,105 @@
+package zbxpoller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
+)
+
+// HistorySource
+// - return the values of an item whose clock, in seconds, is between the ones of from and till,
+// both included, at most limit of them
+// - a zero till leaves the range open, a limit <= 0 returns every value
+// - the values are ordered by clock, the ones sharing a second may come in any order
+type HistorySource interface {
+	History(ctx context.Context, item string, from, till time.Time, limit int64) ([]zabbix.History, error)
+}
+
+// ZabbixHistory
+// - return a HistorySource reading the history from the Zabbix API directly
+// - itemIDs maps the names of the polled items to their Zabbix item ids
+func ZabbixHistory(client zabbix.ZabbixInterface, itemIDs map[string]string) HistorySource {
+	return &zabbixHistory{
+		client:     client,
+		itemIDs:    itemIDs,
+		valueTypes: make(map[string]int),
+	}
+}
+
+type zabbixHistory struct {
+	client  zabbix.ZabbixInterface
+	itemIDs map[string]string
+
+	// valueTypes caches the value type of the items, which selects their history table
+	mu         sync.Mutex
+	valueTypes map[string]int
+}
+
+func (h *zabbixHistory) History(ctx context.Context, item string, from, till time.Time,
+	limit int64) ([]zabbix.History, error) {
+	itemID, ok := h.itemIDs[item]
+	if !ok {
+		return nil, fmt.Errorf("no Zabbix item id is known for item %s", item)
+	}
+
+	valueType, err := h.valueType(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := zabbix.HistoryGetParams{
+		GetParams: zabbix.GetParams{
+			SortField: []string{"clock"},
+			SortOrder: zabbix.SortOrderAsc,
+		},
+		History:  valueType,
+		ItemIDs:  []string{itemID},
+		TimeFrom: from.Unix(),
+	}
+
+	if !till.IsZero() {
+		params.TimeTill = till.Unix()
+	}
+
+	if limit > 0 {
+		params.Limit = int(limit)
+	}
+
+	return h.client.History().Get(ctx, params)
+}
+
+func (h *zabbixHistory) valueType(ctx context.Context, itemID string) (int, error) {
+	h.mu.Lock()
+	valueType, ok := h.valueTypes[itemID]
+	h.mu.Unlock()
+
+	if ok {
+		return valueType, nil
+	}
+
+	items, err := h.client.Items().Get(ctx, zabbix.ItemGetParams{
+		GetParams: zabbix.GetParams{Output: []string{"itemid", "value_type"}},
+		ItemIDs:   []string{itemID},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(items) == 0 {
+		return 0, fmt.Errorf("zabbix item %s not found", itemID)
+	}
+
+	if valueType, err = strconv.Atoi(items[0].ValueType); err != nil {
+		return 0, fmt.Errorf("invalid value type %q of zabbix item %s", items[0].ValueType, itemID)
+	}
+
+	h.mu.Lock()
+	h.valueTypes[itemID] = valueType
+	h.mu.Unlock()
+
+	return valueType, nil
+}
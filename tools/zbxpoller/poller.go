@@ -0,0 +1,356 @@
+package zbxpoller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+)
+
+const (
+	// DefaultInterval is the poll interval used when Config.Interval is not set.
+	DefaultInterval = 30 * time.Second
+
+	// DefaultMaxBackoff is the longest delay between two polls of a failing item, unless
+	// Config.MaxBackoff is set.
+	DefaultMaxBackoff = 5 * time.Minute
+
+	// DefaultLimit is the maximum number of points fetched per item and poll, unless Config.Limit is set.
+	DefaultLimit = 1000
+)
+
+// Sample is a new value of a polled item.
+type Sample struct {
+	Item      string
+	Indicator *v1.Indicator
+	Clock     time.Time
+	Value     string
+}
+
+// Config defines the items to poll and how.
+type Config struct {
+	// Items are the names of the polled items, as given to ZbxCmd.GetZbxItem.
+	Items []string
+
+	// Interval is the delay between two polls, DefaultInterval if zero.
+	Interval time.Duration
+
+	// MaxBackoff caps the delay between two polls of an item which keeps failing, the delay
+	// doubling after each failure. DefaultMaxBackoff if zero.
+	MaxBackoff time.Duration
+
+	// Limit is the maximum number of values fetched per history request, DefaultLimit if zero.
+	// The next poll fetches the following ones, the values of a second holding more than Limit
+	// of them are fetched whole.
+	Limit int64
+
+	// Since is the time of the oldest sample emitted, when zero the first poll starts one
+	// interval before Run is called.
+	Since time.Time
+
+	// OnError is called when polling an item fails, the item is polled again after a backoff.
+	OnError func(item string, err error)
+}
+
+/*
+ * Poller:
+ * - poll the history of a set of items and emit their new samples on a channel
+ * - the last second of each item and the values seen in it are tracked, so a sample is emitted
+ * once even when two polls overlap
+ * - an item failing to be polled is backed off, without delaying the other items
+ */
+
+type Poller struct {
+	cmd     apiv1.ZbxCmdInterface
+	history HistorySource
+	config  Config
+}
+
+// New creates a Poller, cmd provides the indicators of the items and history their values, such
+// as ZabbixHistory.
+func New(cmd apiv1.ZbxCmdInterface, history HistorySource, config Config) *Poller {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if config.Limit <= 0 {
+		config.Limit = DefaultLimit
+	}
+
+	return &Poller{cmd: cmd, history: history, config: config}
+}
+
+// Run
+// - poll the items until ctx is done, then close the returned channel
+// - the first poll is immediate, the samples of an item are emitted oldest first
+//
+// - 示例:
+//
+//	history := zbxpoller.ZabbixHistory(cs.Zabbix(), itemIDs)
+//	p := zbxpoller.New(cs.Elmt().APIV1().ZbxCmd(), history, zbxpoller.Config{Items: items, Interval: time.Minute})
+//	for sample := range p.Run(ctx) {
+//		fmt.Println(sample.Item, sample.Clock, sample.Value)
+//	}
+func (p *Poller) Run(ctx context.Context) <-chan Sample {
+	since := p.config.Since
+	if since.IsZero() {
+		since = time.Now().Add(-p.config.Interval)
+	}
+
+	states := make([]*itemState, 0, len(p.config.Items))
+	for _, item := range p.config.Items {
+		states = append(states, &itemState{item: item, since: since, emitted: emitted{last: since.Unix()}})
+	}
+
+	out := make(chan Sample)
+
+	go p.run(ctx, states, out)
+
+	return out
+}
+
+// itemState is what the poller knows about an item.
+type itemState struct {
+	item      string
+	indicator *v1.Indicator
+
+	// since is the time of the oldest sample emitted
+	since time.Time
+
+	emitted emitted
+
+	failures int
+	next     time.Time
+}
+
+// emitted is the second of the newest samples emitted, seen the nanoseconds of their clocks: like
+// the history tables of Zabbix, the values of an item are told apart by their clock and ns.
+type emitted struct {
+	last int64
+	seen map[int64]bool
+}
+
+func (e emitted) clone() emitted {
+	seen := make(map[int64]bool, len(e.seen))
+	for ns := range e.seen {
+		seen[ns] = true
+	}
+
+	return emitted{last: e.last, seen: seen}
+}
+
+// point is a value of the history of an item.
+type point struct {
+	clock time.Time
+	value string
+}
+
+func (p *Poller) run(ctx context.Context, states []*itemState, out chan<- Sample) {
+	defer close(out)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+
+		for _, state := range states {
+			if now.Before(state.next) {
+				continue
+			}
+
+			if !p.poll(ctx, state, out) {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll emits the new samples of an item, it returns false once ctx is done.
+func (p *Poller) poll(ctx context.Context, state *itemState, out chan<- Sample) bool {
+	samples, err := p.fetch(ctx, state)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		state.failures++
+		state.next = time.Now().Add(p.backoff(state.failures))
+
+		if p.config.OnError != nil {
+			p.config.OnError(state.item, err)
+		}
+
+		return true
+	}
+
+	state.failures = 0
+	state.next = time.Time{}
+
+	for _, sample := range samples {
+		select {
+		case out <- sample:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
+
+// fetch returns the samples of an item newer than the ones already emitted.
+func (p *Poller) fetch(ctx context.Context, state *itemState) ([]Sample, error) {
+	if state.indicator == nil {
+		indicator, err := p.cmd.GetZbxItem(ctx, state.item, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		state.indicator = indicator
+	}
+
+	// The samples are only recorded as emitted once the whole history was fetched, a failing
+	// request leaves them to the next poll
+	pending := state.emitted.clone()
+
+	samples, err := p.fetchHistory(ctx, state, &pending)
+	if err != nil {
+		return nil, err
+	}
+
+	state.emitted = pending
+
+	return samples, nil
+}
+
+// fetchHistory returns the samples of an item which are not in emitted, and adds them to it.
+func (p *Poller) fetchHistory(ctx context.Context, state *itemState, emitted *emitted) ([]Sample, error) {
+	// The last second is fetched whole again, since more values may have come for it
+	last := time.Unix(emitted.last, 0)
+
+	points, err := p.points(ctx, state.item, last, last, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := p.newSamples(state, emitted, points, nil)
+
+	for from := last.Add(time.Second); ; {
+		points, err := p.points(ctx, state.item, from, time.Time{}, p.config.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(points)) < p.config.Limit {
+			return p.newSamples(state, emitted, points, samples), nil
+		}
+
+		first, last := points[0].clock.Unix(), points[len(points)-1].clock.Unix()
+
+		if first != last {
+			// The values of the last second may be cut by the limit, the next poll fetches them
+			for points[len(points)-1].clock.Unix() == last {
+				points = points[:len(points)-1]
+			}
+
+			return p.newSamples(state, emitted, points, samples), nil
+		}
+
+		// A single second holds Limit values or more: it is fetched whole, since the limit would
+		// return the same ones again, and the following seconds right after
+		second := time.Unix(first, 0)
+
+		if points, err = p.points(ctx, state.item, second, second, 0); err != nil {
+			return nil, err
+		}
+
+		samples = p.newSamples(state, emitted, points, samples)
+		from = second.Add(time.Second)
+	}
+}
+
+// points returns the history of an item, oldest first.
+func (p *Poller) points(ctx context.Context, item string, from, till time.Time, limit int64) ([]point, error) {
+	values, err := p.history.History(ctx, item, from, till, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]point, 0, len(values))
+
+	for _, value := range values {
+		clock, err := value.Time()
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, point{clock: clock, value: value.Value})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].clock.Before(points[j].clock)
+	})
+
+	return points, nil
+}
+
+// newSamples appends to samples the points of an item which are not in emitted, and adds them to it.
+func (p *Poller) newSamples(state *itemState, emitted *emitted, points []point, samples []Sample) []Sample {
+	for _, point := range points {
+		second, ns := point.clock.Unix(), int64(point.clock.Nanosecond())
+
+		switch {
+		case point.clock.Before(state.since), second < emitted.last:
+			continue
+		case second > emitted.last:
+			emitted.last = second
+			emitted.seen = make(map[int64]bool)
+		case emitted.seen[ns]:
+			continue
+		}
+
+		if emitted.seen == nil {
+			emitted.seen = make(map[int64]bool)
+		}
+
+		emitted.seen[ns] = true
+
+		samples = append(samples, Sample{
+			Item:      state.item,
+			Indicator: state.indicator,
+			Clock:     point.clock,
+			Value:     point.value,
+		})
+	}
+
+	return samples
+}
+
+// backoff returns the delay before polling again an item which failed failures times in a row.
+func (p *Poller) backoff(failures int) time.Duration {
+	delay := p.config.Interval
+
+	for i := 0; i < failures && delay < p.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > p.config.MaxBackoff {
+		delay = p.config.MaxBackoff
+	}
+
+	return delay
+}
@@ -0,0 +1,426 @@
+package zbxpoller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
+)
+
+// fakeCmd returns an indicator named after the item.
+type fakeCmd struct {
+	err error
+}
+
+func (c *fakeCmd) GetZbxItem(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Indicator, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &v1.Indicator{ItemName: name}, nil
+}
+
+func (c *fakeCmd) GetZbxHost(ctx context.Context, name string, opts metav1.GetOptions) (*v1.ZbxHost, error) {
+	return &v1.ZbxHost{HostName: name}, nil
+}
+
+/*
+ * fakeSource:
+ * - be a HistorySource serving the values added to it
+ * - like history.get, the values are only ordered by second: the ones sharing a second come
+ * newest first, so a limit cutting a second returns an arbitrary part of it
+ */
+
+type fakeSource struct {
+	mu       sync.Mutex
+	values   map[string][]zabbix.History
+	failures int
+	requests int
+
+	// failAt is the number of the request failing, none if zero
+	failAt int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{values: map[string][]zabbix.History{}}
+}
+
+// add adds values of item, clocks given as second.nanosecond.
+func (s *fakeSource) add(item string, clocks ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, clock := range clocks {
+		value := zabbix.History{ItemID: item, Clock: clock, Value: item + "@" + clock}
+
+		for i, c := range clock {
+			if c == '.' {
+				value.Clock, value.NS = clock[:i], clock[i+1:]
+			}
+		}
+
+		s.values[item] = append(s.values[item], value)
+	}
+}
+
+func (s *fakeSource) History(ctx context.Context, item string, from, till time.Time,
+	limit int64) ([]zabbix.History, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if s.failures > 0 || s.requests == s.failAt {
+		if s.failures > 0 {
+			s.failures--
+		}
+
+		return nil, errors.New("zabbix is down")
+	}
+
+	var result []zabbix.History
+
+	for _, value := range s.values[item] {
+		clock, _ := strconv.ParseInt(value.Clock, 10, 64)
+		if clock < from.Unix() || !till.IsZero() && clock > till.Unix() {
+			continue
+		}
+
+		result = append(result, value)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Clock != result[j].Clock {
+			return result[i].Clock < result[j].Clock
+		}
+
+		ni, _ := strconv.Atoi(result[i].NS)
+		nj, _ := strconv.Atoi(result[j].NS)
+
+		return ni > nj
+	})
+
+	if limit > 0 && int64(len(result)) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// collect reads samples until want of them were received and the poller stayed quiet for a few
+// intervals, or the timeout expires.
+func collect(t *testing.T, samples <-chan Sample, want int, interval time.Duration) []Sample {
+	t.Helper()
+
+	var got []Sample
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		quiet := time.After(5 * interval)
+		if len(got) < want {
+			quiet = nil
+		}
+
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				return got
+			}
+
+			got = append(got, sample)
+		case <-quiet:
+			return got
+		case <-timeout:
+			t.Fatalf("received %d samples, want %d", len(got), want)
+		}
+	}
+}
+
+func values(samples []Sample) []string {
+	out := make([]string, len(samples))
+	for i, sample := range samples {
+		out[i] = sample.Value
+	}
+
+	return out
+}
+
+func TestPoller(t *testing.T) {
+	since := time.Unix(100, 500)
+
+	tests := []struct {
+		name  string
+		limit int64
+		// initial values, then the ones added once they were emitted
+		initial, later []string
+		want           []string
+	}{
+		{
+			name:    "after since",
+			limit:   10,
+			initial: []string{"99.0", "100.100", "100.500", "101.0", "102.0"},
+			later:   []string{"103.0"},
+			want:    []string{"a@100.500", "a@101.0", "a@102.0", "a@103.0"},
+		},
+		{
+			name:    "value arriving late in the last second",
+			limit:   10,
+			initial: []string{"101.0", "102.200"},
+			later:   []string{"102.100", "102.300", "103.0"},
+			want:    []string{"a@101.0", "a@102.200", "a@102.100", "a@102.300", "a@103.0"},
+		},
+		{
+			name:    "limit cutting a second",
+			limit:   3,
+			initial: []string{"101.0", "101.1", "102.0", "102.1", "102.2", "103.0"},
+			later:   []string{"104.0"},
+			want:    []string{"a@101.0", "a@101.1", "a@102.0", "a@102.1", "a@102.2", "a@103.0", "a@104.0"},
+		},
+		{
+			name:    "more values in a second than the limit",
+			limit:   2,
+			initial: []string{"101.0", "101.1", "101.2", "101.3", "101.4", "102.0"},
+			later:   []string{"102.1", "103.0"},
+			want:    []string{"a@101.0", "a@101.1", "a@101.2", "a@101.3", "a@101.4", "a@102.0", "a@102.1", "a@103.0"},
+		},
+		{
+			name:    "several crowded seconds",
+			limit:   1,
+			initial: []string{"101.0", "101.1", "102.0", "102.1", "103.0"},
+			want:    []string{"a@101.0", "a@101.1", "a@102.0", "a@102.1", "a@103.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeSource()
+			source.add("a", tt.initial...)
+
+			const interval = 5 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := New(&fakeCmd{}, source, Config{Items: []string{"a"}, Interval: interval, Limit: tt.limit, Since: since})
+			samples := p.Run(ctx)
+
+			got := collect(t, samples, len(tt.want)-len(tt.later), interval)
+			source.add("a", tt.later...)
+			got = append(got, collect(t, samples, len(tt.later), interval)...)
+
+			if fmt.Sprint(values(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("samples = %v, want %v", values(got), tt.want)
+			}
+
+			for _, sample := range got {
+				if sample.Item != "a" || sample.Indicator == nil || sample.Indicator.ItemName != "a" {
+					t.Errorf("sample = %+v, want item a and its indicator", sample)
+				}
+
+				if sample.Clock.Before(since) {
+					t.Errorf("sample %s is older than since", sample.Value)
+				}
+			}
+
+			// The values are ordered by clock within a poll, the late ones come after
+			for i := 1; i < len(got)-len(tt.later); i++ {
+				if got[i].Clock.Before(got[i-1].Clock) {
+					t.Errorf("sample %s emitted after %s", got[i].Value, got[i-1].Value)
+				}
+			}
+		})
+	}
+}
+
+func TestPollerBackoff(t *testing.T) {
+	const interval = 5 * time.Millisecond
+
+	source := newFakeSource()
+	source.add("a", "101.0")
+	source.add("b", "101.0")
+	source.failures = 3
+
+	var (
+		mu     sync.Mutex
+		errs   []string
+		cmdErr = errors.New("no such item")
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := New(&fakeCmd{}, source, Config{
+		Items:      []string{"a", "b"},
+		Interval:   interval,
+		MaxBackoff: 4 * interval,
+		Since:      time.Unix(100, 0),
+		OnError: func(item string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			errs = append(errs, item+": "+err.Error())
+		},
+	})
+
+	got := collect(t, p.Run(ctx), 2, interval)
+	if fmt.Sprint(values(got)) != "[a@101.0 b@101.0]" && fmt.Sprint(values(got)) != "[b@101.0 a@101.0]" {
+		t.Errorf("samples = %v, want the value of a and b once", values(got))
+	}
+
+	mu.Lock()
+	if len(errs) != 3 {
+		t.Errorf("OnError called with %v, want 3 errors", errs)
+	}
+	mu.Unlock()
+
+	// The indicator is looked up before the history, its errors are reported the same way
+	p = New(&fakeCmd{err: cmdErr}, source, Config{
+		Items:    []string{"a"},
+		Interval: interval,
+		OnError: func(item string, err error) {
+			if !errors.Is(err, cmdErr) {
+				t.Errorf("OnError(%s, %v), want %v", item, err, cmdErr)
+			}
+
+			cancel()
+		},
+	})
+
+	if got := collect(t, p.Run(ctx), 0, interval); len(got) != 0 {
+		t.Errorf("samples = %v, want none", values(got))
+	}
+}
+
+func TestPollerFailingFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		initial []string
+		// failAt is the number of the history request failing in the first poll
+		failAt int
+		want   []string
+	}{
+		{
+			name:    "after the last second",
+			limit:   10,
+			initial: []string{"100.5", "101.0", "102.0"},
+			failAt:  2,
+			want:    []string{"a@100.5", "a@101.0", "a@102.0"},
+		},
+		{
+			name:    "fetching a crowded second",
+			limit:   2,
+			initial: []string{"100.5", "101.0", "101.1", "101.2", "102.0"},
+			failAt:  3,
+			want:    []string{"a@100.5", "a@101.0", "a@101.1", "a@101.2", "a@102.0"},
+		},
+		{
+			name:    "after a crowded second",
+			limit:   2,
+			initial: []string{"100.5", "101.0", "101.1", "101.2", "102.0"},
+			failAt:  4,
+			want:    []string{"a@100.5", "a@101.0", "a@101.1", "a@101.2", "a@102.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const interval = 5 * time.Millisecond
+
+			source := newFakeSource()
+			source.add("a", tt.initial...)
+			source.failAt = tt.failAt
+
+			var failures int32
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := New(&fakeCmd{}, source, Config{
+				Items:      []string{"a"},
+				Interval:   interval,
+				MaxBackoff: interval,
+				Limit:      tt.limit,
+				Since:      time.Unix(100, 0),
+				OnError:    func(string, error) { atomic.AddInt32(&failures, 1) },
+			})
+
+			// Every sample is emitted once, after the poll which failed
+			got := collect(t, p.Run(ctx), len(tt.want), interval)
+			if fmt.Sprint(values(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("samples = %v, want %v", values(got), tt.want)
+			}
+
+			if n := atomic.LoadInt32(&failures); n != 1 {
+				t.Errorf("%d failures, want 1", n)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := New(&fakeCmd{}, newFakeSource(), Config{Interval: time.Second, MaxBackoff: 10 * time.Second})
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 2 * time.Second},
+		{failures: 2, want: 4 * time.Second},
+		{failures: 3, want: 8 * time.Second},
+		{failures: 4, want: 10 * time.Second},
+		{failures: 100, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := p.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	defaults := New(&fakeCmd{}, newFakeSource(), Config{})
+	if defaults.config.Interval != DefaultInterval || defaults.config.MaxBackoff != DefaultMaxBackoff ||
+		defaults.config.Limit != DefaultLimit {
+		t.Errorf("defaults = %+v", defaults.config)
+	}
+}
+
+func TestPollerStops(t *testing.T) {
+	source := newFakeSource()
+	source.add("a", "101.0", "102.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := New(&fakeCmd{}, source, Config{Items: []string{"a"}, Interval: time.Hour, Since: time.Unix(100, 0)})
+	samples := p.Run(ctx)
+
+	// The poller blocks sending the second sample until it is canceled
+	if sample := <-samples; sample.Value != "a@101.0" {
+		t.Errorf("sample = %s, want a@101.0", sample.Value)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-samples:
+		if ok {
+			// the pending sample may have been sent before the cancellation was seen
+			if _, ok := <-samples; ok {
+				t.Errorf("the channel is still open after the cancellation")
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the channel is not closed after the cancellation")
+	}
+}
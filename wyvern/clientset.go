@@ -7,14 +7,17 @@ package wyvern
 import (
 	"github.com/opsdata/elmt-sdk/rest"
 	"github.com/opsdata/elmt-sdk/wyvern/service/elmt"
+	"github.com/opsdata/elmt-sdk/wyvern/service/zabbix"
 )
 
 type Interface interface {
 	Elmt() elmt.ElmtInterface
+	Zabbix() zabbix.ZabbixInterface
 }
 
 type Clientset struct {
-	elmt *elmt.ElmtClient
+	elmt   *elmt.ElmtClient
+	zabbix *zabbix.ZabbixClient
 }
 
 var _ Interface = &Clientset{}
@@ -23,6 +26,24 @@ func (c *Clientset) Elmt() elmt.ElmtInterface {
 	return c.elmt
 }
 
+// Zabbix returns the client of the Zabbix API configured by the ZabbixApi fields of the config.
+// Its calls fail with zabbix.ErrNotConfigured when there is none.
+func (c *Clientset) Zabbix() zabbix.ZabbixInterface {
+	return c.zabbix
+}
+
+// Close releases the idle connections kept by the clientset. It does not interrupt the requests
+// in flight, and the clientset may still be used afterwards.
+func (c *Clientset) Close() {
+	if c.elmt != nil {
+		c.elmt.Close()
+	}
+
+	if closer, ok := c.zabbix.RESTClient().(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
 func NewForConfig(c *rest.Config) (*Clientset, error) {
 	var (
 		cs  Clientset
@@ -34,6 +55,11 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 		return nil, err
 	}
 
+	cs.zabbix, err = zabbix.NewForConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
 	return &cs, nil
 }
 
@@ -43,6 +69,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 	var cs Clientset
 
 	cs.elmt = elmt.NewForConfigOrDie(c)
+	cs.zabbix = zabbix.NewForConfigOrDie(c)
 
 	return &cs
 }
@@ -52,6 +79,8 @@ func New(c rest.Interface) *Clientset {
 	var cs Clientset
 
 	cs.elmt = elmt.New(c)
+	// Without a config there is no Zabbix API url, the Zabbix calls fail with ErrNotConfigured
+	cs.zabbix = zabbix.NewForConfigOrDie(&rest.Config{})
 
 	return &cs
 }
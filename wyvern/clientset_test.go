@@ -0,0 +1,94 @@
+package wyvern
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+func TestClientsetClose(t *testing.T) {
+	tests := []struct {
+		name   string
+		zabbix bool
+	}{
+		{name: "elmt only"},
+		{name: "elmt and zabbix", zabbix: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				active = map[net.Conn]bool{}
+			)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{}"))
+			}))
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				switch state {
+				case http.StateNew:
+					active[conn] = true
+				case http.StateClosed:
+					delete(active, conn)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			config := &rest.Config{Host: server.URL}
+			if tt.zabbix {
+				config.ZabbixApiUrl = server.URL
+			}
+
+			cs, err := NewForConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clients := []rest.Interface{cs.Elmt().APIV1().RESTClient(), cs.Elmt().AuthzV1().RESTClient()}
+			if tt.zabbix {
+				clients = append(clients, cs.Zabbix().RESTClient())
+			}
+
+			for _, client := range clients {
+				if err := client.Get().AbsPath("/healthz").Do(context.Background()).Error(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cs.Close()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				mu.Lock()
+				n := len(active)
+				mu.Unlock()
+
+				if n == 0 {
+					break
+				}
+
+				if time.Now().After(deadline) {
+					t.Fatalf("%d connections still open after Close", n)
+				}
+
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// A clientset created from a rest.Interface has no Zabbix client and may hold no *rest.RESTClient.
+func TestClientsetCloseWithoutConfig(t *testing.T) {
+	New(nil).Close()
+}
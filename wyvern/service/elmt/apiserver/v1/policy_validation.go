@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/compiler"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	"github.com/opsdata/common-base/pkg/validation"
+	"github.com/opsdata/common-base/pkg/validation/field"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+)
+
+// ValidatePolicy
+// - check a policy before it is sent to the server, which reports the same problems as an opaque error
+// - the name and the effect are required, the subjects, actions and resources must not be empty
+// nor contain duplicates, and their <...> regular expressions must compile
+// - every condition must be of a type registered in ladon.ConditionFactories and survive a JSON round trip
+func ValidatePolicy(policy *v1.Policy) field.ErrorList {
+	var allErrs field.ErrorList
+
+	namePath := field.NewPath("metadata", "name")
+	if len(policy.Name) == 0 {
+		allErrs = append(allErrs, field.Required(namePath, "a policy name is required"))
+	} else {
+		for _, msg := range validation.IsQualifiedName(policy.Name) {
+			allErrs = append(allErrs, field.Invalid(namePath, policy.Name, msg))
+		}
+	}
+
+	p := &policy.Policy.DefaultPolicy
+	policyPath := field.NewPath("policy")
+
+	switch p.Effect {
+	case ladon.AllowAccess, ladon.DenyAccess:
+	case "":
+		allErrs = append(allErrs, field.Required(policyPath.Child("effect"), "the effect must be allow or deny"))
+	default:
+		allErrs = append(allErrs, field.NotSupported(policyPath.Child("effect"), p.Effect,
+			[]string{ladon.AllowAccess, ladon.DenyAccess}))
+	}
+
+	allErrs = append(allErrs, validatePatterns(p, p.Subjects, policyPath.Child("subjects"))...)
+	allErrs = append(allErrs, validatePatterns(p, p.Actions, policyPath.Child("actions"))...)
+	allErrs = append(allErrs, validatePatterns(p, p.Resources, policyPath.Child("resources"))...)
+	allErrs = append(allErrs, validateConditions(p.Conditions, policyPath.Child("conditions"))...)
+
+	return allErrs
+}
+
+// validatePatterns checks the subjects, actions or resources of a policy.
+func validatePatterns(p *ladon.DefaultPolicy, patterns []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(patterns) == 0 {
+		return append(allErrs, field.Required(fldPath, "at least one entry is required"))
+	}
+
+	seen := make(map[string]bool, len(patterns))
+
+	for i, pattern := range patterns {
+		idxPath := fldPath.Index(i)
+
+		switch {
+		case len(pattern) == 0:
+			allErrs = append(allErrs, field.Required(idxPath, "must not be empty"))
+			continue
+		case seen[pattern]:
+			allErrs = append(allErrs, field.Duplicate(idxPath, pattern))
+			continue
+		}
+
+		seen[pattern] = true
+
+		if _, err := compiler.CompileRegex(pattern, p.GetStartDelimiter(), p.GetEndDelimiter()); err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath, pattern, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateConditions checks the conditions can be stored and loaded back by the server.
+func validateConditions(conditions ladon.Conditions, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	keys := make([]string, 0, len(conditions))
+	for key := range conditions {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		condition := conditions[key]
+		keyPath := fldPath.Key(key)
+
+		if condition == nil {
+			allErrs = append(allErrs, field.Required(keyPath, "the condition must not be null"))
+			continue
+		}
+
+		if _, ok := ladon.ConditionFactories[condition.GetName()]; !ok {
+			allErrs = append(allErrs, field.NotSupported(keyPath.Child("type"), condition.GetName(),
+				conditionTypes()))
+
+			continue
+		}
+
+		data, err := json.Marshal(ladon.Conditions{key: condition})
+		if err == nil {
+			err = ladon.Conditions{}.UnmarshalJSON(data)
+		}
+
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(keyPath, condition.GetName(), err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// conditionTypes returns the names of the condition types registered in ladon.
+func conditionTypes() []string {
+	types := make([]string, 0, len(ladon.ConditionFactories))
+	for name := range ladon.ConditionFactories {
+		types = append(types, name)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// validatingPolicies validates the policies before creating or updating them.
+type validatingPolicies struct {
+	PolicyInterface
+}
+
+// WithPolicyValidation
+// - return a PolicyInterface which runs ValidatePolicy before Create and Update
+// - an invalid policy is not sent, the returned error is the aggregate of the field errors
+func WithPolicyValidation(policies PolicyInterface) PolicyInterface {
+	return &validatingPolicies{PolicyInterface: policies}
+}
+
+func (c *validatingPolicies) Create(ctx context.Context, policy *v1.Policy,
+	opts metav1.CreateOptions) (*v1.Policy, error) {
+	if errs := ValidatePolicy(policy); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+
+	return c.PolicyInterface.Create(ctx, policy, opts)
+}
+
+func (c *validatingPolicies) Update(ctx context.Context, policy *v1.Policy,
+	opts metav1.UpdateOptions) (*v1.Policy, error) {
+	if errs := ValidatePolicy(policy); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+
+	return c.PolicyInterface.Update(ctx, policy, opts)
+}
@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+)
+
+// unencodableCondition is a registered condition type which cannot be encoded as JSON.
+type unencodableCondition struct {
+	Values chan string `json:"values"`
+}
+
+func (c *unencodableCondition) GetName() string {
+	return "UnencodableCondition"
+}
+
+func (c *unencodableCondition) Fulfills(value interface{}, r *ladon.Request) bool {
+	return false
+}
+
+// unknownCondition is a condition type which is not registered in ladon.
+type unknownCondition struct{}
+
+func (c *unknownCondition) GetName() string {
+	return "UnknownCondition"
+}
+
+func (c *unknownCondition) Fulfills(value interface{}, r *ladon.Request) bool {
+	return false
+}
+
+func validPolicy() *v1.Policy {
+	return &v1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-hosts"},
+		Policy: v1.AuthzPolicy{DefaultPolicy: ladon.DefaultPolicy{
+			Subjects:   []string{"users:<.+>"},
+			Actions:    []string{"get", "list"},
+			Resources:  []string{"hosts:<.+>"},
+			Effect:     ladon.AllowAccess,
+			Conditions: ladon.Conditions{"ip": &ladon.CIDRCondition{CIDR: "10.0.0.0/8"}},
+		}},
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	ladon.ConditionFactories["UnencodableCondition"] = func() ladon.Condition { return &unencodableCondition{} }
+	defer delete(ladon.ConditionFactories, "UnencodableCondition")
+
+	tests := []struct {
+		name   string
+		mutate func(p *v1.Policy)
+		// want are the type and the field of the expected errors
+		want []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(p *v1.Policy) {},
+		},
+		{
+			name:   "no name",
+			mutate: func(p *v1.Policy) { p.Name = "" },
+			want:   []string{"FieldValueRequired metadata.name"},
+		},
+		{
+			name:   "invalid name",
+			mutate: func(p *v1.Policy) { p.Name = "read hosts!" },
+			want:   []string{"FieldValueInvalid metadata.name"},
+		},
+		{
+			name:   "no effect",
+			mutate: func(p *v1.Policy) { p.Policy.Effect = "" },
+			want:   []string{"FieldValueRequired policy.effect"},
+		},
+		{
+			name:   "unknown effect",
+			mutate: func(p *v1.Policy) { p.Policy.Effect = "permit" },
+			want:   []string{"FieldValueNotSupported policy.effect"},
+		},
+		{
+			name: "no subjects, actions nor resources",
+			mutate: func(p *v1.Policy) {
+				p.Policy.Subjects, p.Policy.Actions, p.Policy.Resources = nil, []string{}, nil
+			},
+			want: []string{
+				"FieldValueRequired policy.subjects",
+				"FieldValueRequired policy.actions",
+				"FieldValueRequired policy.resources",
+			},
+		},
+		{
+			name:   "empty and duplicate entries",
+			mutate: func(p *v1.Policy) { p.Policy.Actions = []string{"get", "", "get"} },
+			want:   []string{"FieldValueRequired policy.actions[1]", "FieldValueDuplicate policy.actions[2]"},
+		},
+		{
+			name:   "invalid regular expression",
+			mutate: func(p *v1.Policy) { p.Policy.Resources = []string{"hosts:<.+>", "hosts:<[>"} },
+			want:   []string{"FieldValueInvalid policy.resources[1]"},
+		},
+		{
+			name:   "unbalanced delimiters",
+			mutate: func(p *v1.Policy) { p.Policy.Subjects = []string{"users:<.+"} },
+			want:   []string{"FieldValueInvalid policy.subjects[0]"},
+		},
+		{
+			name:   "null condition",
+			mutate: func(p *v1.Policy) { p.Policy.Conditions["owner"] = nil },
+			want:   []string{"FieldValueRequired policy.conditions[owner]"},
+		},
+		{
+			name:   "unknown condition type",
+			mutate: func(p *v1.Policy) { p.Policy.Conditions["owner"] = &unknownCondition{} },
+			want:   []string{"FieldValueNotSupported policy.conditions[owner].type"},
+		},
+		{
+			name:   "condition which cannot be stored",
+			mutate: func(p *v1.Policy) { p.Policy.Conditions["owner"] = &unencodableCondition{} },
+			want:   []string{"FieldValueInvalid policy.conditions[owner]"},
+		},
+		{
+			name: "errors sorted by field",
+			mutate: func(p *v1.Policy) {
+				p.Policy.Effect = ""
+				p.Policy.Conditions["b"] = nil
+				p.Policy.Conditions["a"] = &unknownCondition{}
+			},
+			want: []string{
+				"FieldValueRequired policy.effect",
+				"FieldValueNotSupported policy.conditions[a].type",
+				"FieldValueRequired policy.conditions[b]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := validPolicy()
+			tt.mutate(policy)
+
+			got := []string{}
+			for _, err := range ValidatePolicy(policy) {
+				got = append(got, string(err.Type)+" "+err.Field)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = []string{}
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ValidatePolicy() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// recordingPolicies records the policies created and updated through it.
+type recordingPolicies struct {
+	PolicyInterface
+
+	created, updated []*v1.Policy
+}
+
+func (c *recordingPolicies) Create(ctx context.Context, policy *v1.Policy,
+	opts metav1.CreateOptions) (*v1.Policy, error) {
+	c.created = append(c.created, policy)
+
+	return policy, nil
+}
+
+func (c *recordingPolicies) Update(ctx context.Context, policy *v1.Policy,
+	opts metav1.UpdateOptions) (*v1.Policy, error) {
+	c.updated = append(c.updated, policy)
+
+	return policy, nil
+}
+
+func TestWithPolicyValidation(t *testing.T) {
+	ctx := context.Background()
+
+	invalid := validPolicy()
+	invalid.Name = ""
+	invalid.Policy.Effect = "permit"
+
+	tests := []struct {
+		name    string
+		policy  *v1.Policy
+		wantErr []string
+	}{
+		{
+			name:   "valid",
+			policy: validPolicy(),
+		},
+		{
+			name:    "invalid",
+			policy:  invalid,
+			wantErr: []string{"metadata.name", "policy.effect"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingPolicies{}
+			policies := WithPolicyValidation(recorder)
+
+			_, createErr := policies.Create(ctx, tt.policy, metav1.CreateOptions{})
+			_, updateErr := policies.Update(ctx, tt.policy, metav1.UpdateOptions{})
+
+			for _, err := range []error{createErr, updateErr} {
+				if (err != nil) != (len(tt.wantErr) > 0) {
+					t.Fatalf("error = %v, want the errors of %v", err, tt.wantErr)
+				}
+
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error = %v, want it to mention %s", err, want)
+					}
+				}
+			}
+
+			wantSent := 1
+			if len(tt.wantErr) > 0 {
+				wantSent = 0
+			}
+
+			if len(recorder.created) != wantSent || len(recorder.updated) != wantSent {
+				t.Errorf("%d policies created and %d updated, want %d", len(recorder.created),
+					len(recorder.updated), wantSent)
+			}
+		})
+	}
+}
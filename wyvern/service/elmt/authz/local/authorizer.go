@@ -0,0 +1,246 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ory/ladon"
+	"github.com/ory/ladon/manager/memory"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	apiv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/apiserver/v1"
+	authzclientv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/authz/v1"
+)
+
+const (
+	// DefaultResyncPeriod is the delay between two relists of the policies, unless Options.ResyncPeriod is set.
+	DefaultResyncPeriod = time.Minute
+
+	// DefaultPageSize is the number of policies fetched per List call, unless Options.PageSize is set.
+	DefaultPageSize = 500
+)
+
+// ErrNotSynced is returned by the evaluations made before the policies were listed once.
+var ErrNotSynced = errors.New("the policies have not been synced yet")
+
+// Options defines how the policies are kept in sync.
+type Options struct {
+	// ResyncPeriod is the delay between two relists of the policies by Run.
+	ResyncPeriod time.Duration
+
+	// PageSize is the number of policies fetched per List call.
+	PageSize int64
+
+	// OnError is called when a relist made by Run fails, the previous policies are kept.
+	OnError func(err error)
+}
+
+/*
+ * Authorizer:
+ * - evaluate ladon requests against an in-memory copy of the policies of the ELMT server
+ * - implement the AuthzInterface interface, so it can replace the remote authorization
+ * - the policies are loaded by Sync, and kept in sync by Run
+ */
+
+type Authorizer struct {
+	client  apiv1.PolicyInterface
+	options Options
+
+	mu       sync.RWMutex
+	warden   *ladon.Ladon
+	policies ladon.Policies
+	lastSync time.Time
+}
+
+var _ authzclientv1.AuthzInterface = &Authorizer{}
+
+// New
+// - create an Authorizer loading the policies through policies
+// - it denies every request until Sync succeeded once
+//
+// - 示例:
+//
+//	authorizer := local.New(clientset.Elmt().APIV1().Policies(), local.Options{})
+//	if err := authorizer.Sync(ctx); err != nil {
+//		return err
+//	}
+//	go authorizer.Run(ctx)
+func New(policies apiv1.PolicyInterface, options Options) *Authorizer {
+	if options.ResyncPeriod <= 0 {
+		options.ResyncPeriod = DefaultResyncPeriod
+	}
+
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+
+	return &Authorizer{
+		client:  policies,
+		options: options,
+	}
+}
+
+// Sync lists every policy and replaces the ones in memory, which are kept if listing fails.
+func (a *Authorizer) Sync(ctx context.Context) error {
+	list, err := a.listPolicies(ctx)
+	if err != nil {
+		return err
+	}
+
+	policies := ladonPolicies(list)
+
+	manager := memory.NewMemoryManager()
+	for _, p := range policies {
+		if err := manager.Create(p); err != nil {
+			return fmt.Errorf("unable to load policy %s: %v", p.GetID(), err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Every field is set: ladon fills the missing ones on first use, racing the concurrent calls
+	a.warden = &ladon.Ladon{
+		Manager:     manager,
+		Matcher:     ladon.DefaultMatcher,
+		AuditLogger: &ladon.AuditLoggerNoOp{},
+		Metric:      ladon.DefaultMetric,
+	}
+	a.policies = policies
+	a.lastSync = time.Now()
+
+	return nil
+}
+
+// Run relists the policies every ResyncPeriod until ctx is done.
+func (a *Authorizer) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.options.ResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.Sync(ctx); err != nil && ctx.Err() == nil && a.options.OnError != nil {
+			a.options.OnError(err)
+		}
+	}
+}
+
+// HasSynced returns true once the policies were listed.
+func (a *Authorizer) HasSynced() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.warden != nil
+}
+
+// LastSync returns the time of the last successful relist.
+func (a *Authorizer) LastSync() time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.lastSync
+}
+
+// Authorize evaluates the request against the policies in memory, like the authz server does.
+func (a *Authorizer) Authorize(ctx context.Context, request *ladon.Request,
+	opts metav1.AuthorizeOptions) (*authzv1.Response, error) {
+	a.mu.RLock()
+	warden := a.warden
+	a.mu.RUnlock()
+
+	if warden == nil {
+		return nil, ErrNotSynced
+	}
+
+	if err := warden.IsAllowed(request); err != nil {
+		return &authzv1.Response{
+			Denied: true,
+			Reason: err.Error(),
+		}, nil
+	}
+
+	return &authzv1.Response{
+		Allowed: true,
+	}, nil
+}
+
+// AuthorizeBatch evaluates the requests one after the other, the evaluation being local.
+func (a *Authorizer) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	results := make([]*authzv1.Response, len(requests))
+
+	for i, request := range requests {
+		result, err := a.Authorize(ctx, request, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// listPolicies lists every policy, page by page.
+func (a *Authorizer) listPolicies(ctx context.Context) ([]*v1.Policy, error) {
+	var list []*v1.Policy
+
+	for offset := int64(0); ; {
+		limit := a.options.PageSize
+		start := offset
+
+		page, err := a.client.List(ctx, metav1.ListOptions{Offset: &start, Limit: &limit})
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, page.Items...)
+		offset += int64(len(page.Items))
+
+		if int64(len(page.Items)) < limit || (page.TotalCount > 0 && offset >= page.TotalCount) {
+			return list, nil
+		}
+	}
+}
+
+// ladonPolicies returns the ladon policies of the ELMT policies, in the same order.
+func ladonPolicies(list []*v1.Policy) ladon.Policies {
+	policies := make(ladon.Policies, 0, len(list))
+	ids := make(map[string]bool, len(list))
+
+	for i, policy := range list {
+		p := policy.Policy.DefaultPolicy
+		if p.ID = policyID(policy); len(p.ID) == 0 || ids[p.ID] {
+			p.ID = "policy-" + strconv.Itoa(i)
+		}
+
+		ids[p.ID] = true
+		policies = append(policies, &p)
+	}
+
+	return policies
+}
+
+// policyID returns the ID of a policy in the ladon manager, which keys the policies by ID.
+func policyID(policy *v1.Policy) string {
+	switch {
+	case len(policy.InstanceID) > 0:
+		return policy.InstanceID
+	case len(policy.Name) > 0:
+		return policy.Name
+	}
+
+	return policy.Policy.ID
+}
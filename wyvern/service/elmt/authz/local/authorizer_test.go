@@ -0,0 +1,476 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+)
+
+// fakePolicies is a PolicyInterface listing its policies page by page.
+type fakePolicies struct {
+	mu       sync.Mutex
+	policies []*v1.Policy
+	err      error
+	lists    int
+}
+
+func (f *fakePolicies) set(policies []*v1.Policy, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.policies, f.err = policies, err
+}
+
+func (f *fakePolicies) List(ctx context.Context, opts metav1.ListOptions) (*v1.PolicyList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lists++
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	start, end := int64(0), int64(len(f.policies))
+	if opts.Offset != nil && *opts.Offset < end {
+		start = *opts.Offset
+	}
+
+	if opts.Limit != nil && start+*opts.Limit < end {
+		end = start + *opts.Limit
+	}
+
+	return &v1.PolicyList{
+		ListMeta: metav1.ListMeta{TotalCount: int64(len(f.policies))},
+		Items:    f.policies[start:end],
+	}, nil
+}
+
+func (f *fakePolicies) Create(ctx context.Context, policy *v1.Policy, opts metav1.CreateOptions) (*v1.Policy, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakePolicies) Update(ctx context.Context, policy *v1.Policy, opts metav1.UpdateOptions) (*v1.Policy, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakePolicies) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakePolicies) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions,
+	listOpts metav1.ListOptions) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakePolicies) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Policy, error) {
+	return nil, errors.New("not implemented")
+}
+
+func testPolicy(name, effect string, subjects, actions, resources []string, conditions ladon.Conditions) *v1.Policy {
+	return &v1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Policy: v1.AuthzPolicy{DefaultPolicy: ladon.DefaultPolicy{
+			Subjects:   subjects,
+			Actions:    actions,
+			Resources:  resources,
+			Effect:     effect,
+			Conditions: conditions,
+		}},
+	}
+}
+
+// testPolicies lets the users read the hosts, the admins do anything but delete the audit logs,
+// and the users of the office network write the hosts.
+func testPolicies() []*v1.Policy {
+	return []*v1.Policy{
+		testPolicy("read-hosts", ladon.AllowAccess, []string{"users:<.+>"}, []string{"get", "list"},
+			[]string{"hosts:<.+>"}, nil),
+		testPolicy("admins", ladon.AllowAccess, []string{"admins:<.+>"}, []string{"<.+>"}, []string{"<.+>"}, nil),
+		testPolicy("keep-audit", ladon.DenyAccess, []string{"<.+>"}, []string{"delete"}, []string{"audit:<.+>"}, nil),
+		testPolicy("office-writes", ladon.AllowAccess, []string{"users:<.+>"}, []string{"update"},
+			[]string{"hosts:<.+>"}, ladon.Conditions{"ip": &ladon.CIDRCondition{CIDR: "10.0.0.0/8"}}),
+	}
+}
+
+func newTestAuthorizer(t *testing.T, policies []*v1.Policy, options Options) (*Authorizer, *fakePolicies) {
+	t.Helper()
+
+	client := &fakePolicies{policies: policies}
+
+	a := New(client, options)
+	if err := a.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	return a, client
+}
+
+func TestAuthorize(t *testing.T) {
+	a, _ := newTestAuthorizer(t, testPolicies(), Options{})
+
+	tests := []struct {
+		name        string
+		request     *ladon.Request
+		wantAllowed bool
+	}{
+		{
+			name:        "allowed",
+			request:     &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+			wantAllowed: true,
+		},
+		{
+			name:    "no policy matching",
+			request: &ladon.Request{Subject: "users:alice", Action: "delete", Resource: "hosts:web-1"},
+		},
+		{
+			name:        "allowed by a wildcard policy",
+			request:     &ladon.Request{Subject: "admins:bob", Action: "delete", Resource: "hosts:web-1"},
+			wantAllowed: true,
+		},
+		{
+			name:    "deny wins",
+			request: &ladon.Request{Subject: "admins:bob", Action: "delete", Resource: "audit:2024"},
+		},
+		{
+			name: "condition fulfilled",
+			request: &ladon.Request{Subject: "users:alice", Action: "update", Resource: "hosts:web-1",
+				Context: ladon.Context{"ip": "10.1.2.3"}},
+			wantAllowed: true,
+		},
+		{
+			name: "condition not fulfilled",
+			request: &ladon.Request{Subject: "users:alice", Action: "update", Resource: "hosts:web-1",
+				Context: ladon.Context{"ip": "192.168.1.1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := a.Authorize(context.Background(), tt.request, metav1.AuthorizeOptions{})
+			if err != nil {
+				t.Fatalf("Authorize() failed: %v", err)
+			}
+
+			if result.Allowed != tt.wantAllowed || result.Denied == tt.wantAllowed {
+				t.Errorf("Authorize() = %+v, want allowed %v", result, tt.wantAllowed)
+			}
+
+			if !tt.wantAllowed && len(result.Reason) == 0 {
+				t.Errorf("Authorize() denied without a reason")
+			}
+		})
+	}
+}
+
+func TestAuthorizeBatch(t *testing.T) {
+	a, _ := newTestAuthorizer(t, testPolicies(), Options{})
+
+	results, err := a.AuthorizeBatch(context.Background(), []*ladon.Request{
+		{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+		{Subject: "users:alice", Action: "delete", Resource: "hosts:web-1"},
+	}, metav1.AuthorizeOptions{})
+	if err != nil {
+		t.Fatalf("AuthorizeBatch() failed: %v", err)
+	}
+
+	if len(results) != 2 || !results[0].Allowed || !results[1].Denied {
+		t.Errorf("AuthorizeBatch() = %+v, want allowed then denied", results)
+	}
+}
+
+func TestNotSynced(t *testing.T) {
+	a := New(&fakePolicies{err: errors.New("server unavailable")}, Options{})
+
+	if a.HasSynced() || !a.LastSync().IsZero() {
+		t.Errorf("a new Authorizer has synced")
+	}
+
+	request := &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"}
+
+	if _, err := a.Authorize(context.Background(), request, metav1.AuthorizeOptions{}); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("Authorize() = %v, want %v", err, ErrNotSynced)
+	}
+
+	if _, err := a.AuthorizeBatch(context.Background(), []*ladon.Request{request},
+		metav1.AuthorizeOptions{}); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("AuthorizeBatch() = %v, want %v", err, ErrNotSynced)
+	}
+
+	if err := a.Sync(context.Background()); err == nil || a.HasSynced() {
+		t.Errorf("Sync() = %v, want the error of List", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		policies  int
+		pageSize  int64
+		wantLists int
+	}{
+		{name: "no policy", policies: 0, pageSize: 2, wantLists: 1},
+		{name: "a single page", policies: 1, pageSize: 2, wantLists: 1},
+		{name: "full pages", policies: 4, pageSize: 2, wantLists: 2},
+		{name: "last page partial", policies: 5, pageSize: 2, wantLists: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := make([]*v1.Policy, tt.policies)
+			for i := range list {
+				list[i] = testPolicy(fmt.Sprintf("p%d", i), ladon.AllowAccess, []string{fmt.Sprintf("users:%d", i)},
+					[]string{"get"}, []string{"hosts:<.+>"}, nil)
+			}
+
+			a, client := newTestAuthorizer(t, list, Options{PageSize: tt.pageSize})
+
+			if client.lists != tt.wantLists {
+				t.Errorf("%d List calls, want %d", client.lists, tt.wantLists)
+			}
+
+			if len(a.policies) != tt.policies {
+				t.Errorf("%d policies loaded, want %d", len(a.policies), tt.policies)
+			}
+
+			// Every policy was loaded, the last one allows its user
+			if tt.policies > 0 {
+				request := &ladon.Request{Subject: fmt.Sprintf("users:%d", tt.policies-1), Action: "get", Resource: "hosts:a"}
+				if result, err := a.Authorize(context.Background(), request, metav1.AuthorizeOptions{}); err != nil || !result.Allowed {
+					t.Errorf("Authorize() = %+v, %v, want allowed by the last policy", result, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncKeepsPolicies(t *testing.T) {
+	a, client := newTestAuthorizer(t, testPolicies(), Options{})
+	synced := a.LastSync()
+
+	client.set(nil, errors.New("server unavailable"))
+
+	if err := a.Sync(context.Background()); err == nil {
+		t.Fatalf("Sync() succeeded while List fails")
+	}
+
+	request := &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"}
+	if result, err := a.Authorize(context.Background(), request, metav1.AuthorizeOptions{}); err != nil || !result.Allowed {
+		t.Errorf("Authorize() = %+v, %v, want the previous policies kept", result, err)
+	}
+
+	if !a.LastSync().Equal(synced) {
+		t.Errorf("LastSync() changed by a failed relist")
+	}
+}
+
+func TestLadonPolicies(t *testing.T) {
+	list := []*v1.Policy{
+		{ObjectMeta: metav1.ObjectMeta{InstanceID: "policy-abc", Name: "read"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "write"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "write"}},
+		{Policy: v1.AuthzPolicy{DefaultPolicy: ladon.DefaultPolicy{ID: "ladon-id"}}},
+		{},
+	}
+
+	want := []string{"policy-abc", "write", "policy-2", "ladon-id", "policy-4"}
+
+	policies := ladonPolicies(list)
+	for i, p := range policies {
+		if p.GetID() != want[i] {
+			t.Errorf("policy %d has ID %q, want %q", i, p.GetID(), want[i])
+		}
+	}
+}
+
+// TestConcurrentAuthorize is meant to be run with -race: the evaluations share the warden, and
+// the relists replace it while they run.
+func TestConcurrentAuthorize(t *testing.T) {
+	a, _ := newTestAuthorizer(t, testPolicies(), Options{})
+
+	requests := []*ladon.Request{
+		{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+		{Subject: "admins:bob", Action: "delete", Resource: "audit:2024"},
+		{Subject: "users:alice", Action: "update", Resource: "hosts:web-1", Context: ladon.Context{"ip": "10.1.2.3"}},
+	}
+	want := []bool{true, false, true}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 100)
+
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				j := (g + i) % len(requests)
+
+				var (
+					result *authzv1.Response
+					err    error
+				)
+
+				if g%2 == 0 {
+					result, err = a.Authorize(context.Background(), requests[j], metav1.AuthorizeOptions{})
+				} else {
+					var results []*authzv1.Response
+					if results, err = a.AuthorizeBatch(context.Background(), requests[j:j+1],
+						metav1.AuthorizeOptions{}); err == nil {
+						result = results[0]
+					}
+				}
+
+				if err == nil && result.Allowed != want[j] {
+					err = fmt.Errorf("request %d allowed %v, want %v", j, result.Allowed, want[j])
+				}
+
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := a.Sync(context.Background()); err != nil {
+			t.Errorf("Sync() failed: %v", err)
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// failingAuthz fails the calls, or the requests of a batch, of the subjects in failing.
+type failingAuthz struct {
+	failing map[string]bool
+	err     error
+}
+
+func (f *failingAuthz) Authorize(ctx context.Context, request *ladon.Request,
+	opts metav1.AuthorizeOptions) (*authzv1.Response, error) {
+	if f.err != nil || f.failing[request.Subject] {
+		return nil, errors.New("server unavailable")
+	}
+
+	return &authzv1.Response{Denied: true, Reason: "remote"}, nil
+}
+
+func (f *failingAuthz) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	results := make([]*authzv1.Response, len(requests))
+	for i, request := range requests {
+		if f.failing[request.Subject] {
+			results[i] = &authzv1.Response{Denied: true, Error: "server unavailable"}
+			continue
+		}
+
+		results[i], _ = f.Authorize(ctx, request, opts)
+	}
+
+	return results, nil
+}
+
+func TestWithFallback(t *testing.T) {
+	ctx := context.Background()
+	alice := &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"}
+	bob := &ladon.Request{Subject: "users:bob", Action: "get", Resource: "hosts:web-1"}
+
+	tests := []struct {
+		name   string
+		remote *failingAuthz
+		synced bool
+		// want is the reason of the responses of alice and bob, local when evaluated locally
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "remote answers",
+			remote: &failingAuthz{},
+			synced: true,
+			want:   []string{"remote", "remote"},
+		},
+		{
+			name:   "remote fails",
+			remote: &failingAuthz{err: errors.New("server unavailable")},
+			synced: true,
+			want:   []string{"local", "local"},
+		},
+		{
+			name:   "remote fails a request of the batch",
+			remote: &failingAuthz{failing: map[string]bool{"users:bob": true}},
+			synced: true,
+			want:   []string{"remote", "local"},
+		},
+		{
+			name:    "remote fails before the policies were synced",
+			remote:  &failingAuthz{err: errors.New("server unavailable")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := New(&fakePolicies{policies: testPolicies()}, Options{})
+			if tt.synced {
+				if err := local.Sync(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			authz := WithFallback(tt.remote, local)
+
+			results, err := authz.AuthorizeBatch(ctx, []*ladon.Request{alice, bob}, metav1.AuthorizeOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuthorizeBatch() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			single, singleErr := authz.Authorize(ctx, bob, metav1.AuthorizeOptions{})
+			if (singleErr != nil) != tt.wantErr {
+				t.Fatalf("Authorize() error = %v, want error %v", singleErr, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			// the single call is made for bob
+			wants := append(tt.want, tt.want[1])
+
+			for i, result := range append(results, single) {
+				if got := source(result); got != wants[i] {
+					t.Errorf("response %d = %+v, want it from %s", i, result, wants[i])
+				}
+			}
+		})
+	}
+}
+
+// source tells whether a response came from failingAuthz or from the local policies.
+func source(result *authzv1.Response) string {
+	if result.Reason == "remote" {
+		return "remote"
+	}
+
+	return "local"
+}
@@ -0,0 +1,4 @@
+package local
+
+// package local
+// - evaluate authorization requests offline, against a copy of the policies of the ELMT server
@@ -0,0 +1,282 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ory/ladon"
+
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+)
+
+// Effects of an Explanation.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Explanation tells how a request was evaluated against every policy.
+type Explanation struct {
+	Request *ladon.Request `json:"request"`
+
+	// Allowed and Effect are the final decision
+	Allowed bool   `json:"allowed"`
+	Effect  string `json:"effect"`
+	Reason  string `json:"reason"`
+
+	// DecidingPolicy is the policy which denied the request, or the first one which allowed it.
+	// It is empty when no policy matched and the request was denied by default.
+	DecidingPolicy string `json:"deciding_policy,omitempty"`
+
+	// Error is set when a policy could not be evaluated, which denies the whole request like the
+	// authz server does; DecidingPolicy is then that policy.
+	Error string `json:"error,omitempty"`
+
+	Policies []PolicyExplanation `json:"policies"`
+}
+
+// PolicyExplanation tells which parts of a policy matched the request.
+type PolicyExplanation struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Effect      string `json:"effect"`
+
+	SubjectsMatched  bool                   `json:"subjects_matched"`
+	ActionsMatched   bool                   `json:"actions_matched"`
+	ResourcesMatched bool                   `json:"resources_matched"`
+	Conditions       []ConditionExplanation `json:"conditions,omitempty"`
+
+	// Matched is true when the subjects, actions, resources and conditions all matched.
+	Matched bool `json:"matched"`
+
+	// Error is set when a pattern of the policy could not be evaluated.
+	Error string `json:"error,omitempty"`
+}
+
+// ConditionExplanation tells whether the value of the request context fulfills a condition.
+type ConditionExplanation struct {
+	Key       string      `json:"key"`
+	Type      string      `json:"type"`
+	Value     interface{} `json:"value"`
+	Fulfilled bool        `json:"fulfilled"`
+}
+
+// Explain
+// - evaluate the request against the synced policies, syncing them first if needed
+// - unlike Authorize, every part of every policy is evaluated, to tell which ones did not match
+func (a *Authorizer) Explain(ctx context.Context, request *ladon.Request) (*Explanation, error) {
+	if !a.HasSynced() {
+		if err := a.Sync(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	a.mu.RLock()
+	policies := a.policies
+	a.mu.RUnlock()
+
+	return explain(policies, request), nil
+}
+
+// ExplainPolicies evaluates the request against the given policies, such as the items of a PolicyList.
+func ExplainPolicies(list []*v1.Policy, request *ladon.Request) *Explanation {
+	return explain(ladonPolicies(list), request)
+}
+
+// decide
+// - evaluate the request against the policies with ladon, exactly like Authorize and the authz server
+// - it returns nil when the request is allowed, otherwise why it is denied: a policy denying it,
+// no policy matching it, or a policy which could not be evaluated
+func decide(policies ladon.Policies, request *ladon.Request) error {
+	warden := &ladon.Ladon{
+		Matcher:     ladon.DefaultMatcher,
+		AuditLogger: &ladon.AuditLoggerNoOp{},
+		Metric:      ladon.DefaultMetric,
+	}
+
+	return warden.DoPoliciesAllow(request, policies)
+}
+
+// explain
+// - take the decision from ladon, and explain it with the evaluation of every part of every policy
+// - ladon denies the whole request when a pattern of a policy it reaches cannot be evaluated,
+// even if another policy allows it
+func explain(policies ladon.Policies, request *ladon.Request) *Explanation {
+	e := &Explanation{
+		Request:  request,
+		Policies: make([]PolicyExplanation, 0, len(policies)),
+	}
+
+	var allowedBy, deniedBy string
+
+	for _, p := range policies {
+		pe := explainPolicy(p, request)
+		e.Policies = append(e.Policies, pe)
+
+		switch {
+		case !pe.Matched:
+		case !p.AllowAccess() && len(deniedBy) == 0:
+			deniedBy = pe.ID
+		case p.AllowAccess() && len(allowedBy) == 0:
+			allowedBy = pe.ID
+		}
+	}
+
+	err := decide(policies, request)
+
+	switch {
+	case err == nil:
+		e.Allowed, e.Effect, e.DecidingPolicy = true, EffectAllow, allowedBy
+		e.Reason = fmt.Sprintf("allowed by policy %s", allowedBy)
+	case errors.Is(err, ladon.ErrRequestForcefullyDenied):
+		e.Effect, e.DecidingPolicy = EffectDeny, deniedBy
+		e.Reason = fmt.Sprintf("forcefully denied by policy %s", deniedBy)
+	case errors.Is(err, ladon.ErrRequestDenied):
+		e.Effect = EffectDeny
+		e.Reason = "denied by default, no policy matched"
+	default:
+		e.Effect, e.Error = EffectDeny, err.Error()
+		e.DecidingPolicy = failingPolicy(policies, request)
+		e.Reason = fmt.Sprintf("denied, policy %s could not be evaluated", e.DecidingPolicy)
+	}
+
+	return e
+}
+
+// failingPolicy returns the policy on which ladon stopped with an error. ladon evaluates the
+// policies in order and stops at the first deny, so it is the first one failing on its own.
+func failingPolicy(policies ladon.Policies, request *ladon.Request) string {
+	for _, p := range policies {
+		err := decide(ladon.Policies{p}, request)
+		if err != nil && !errors.Is(err, ladon.ErrRequestDenied) && !errors.Is(err, ladon.ErrRequestForcefullyDenied) {
+			return p.GetID()
+		}
+	}
+
+	return ""
+}
+
+func explainPolicy(p ladon.Policy, request *ladon.Request) PolicyExplanation {
+	pe := PolicyExplanation{
+		ID:          p.GetID(),
+		Description: p.GetDescription(),
+		Effect:      p.GetEffect(),
+	}
+
+	var errs []string
+
+	match := func(haystack []string, needle string) bool {
+		ok, err := ladon.DefaultMatcher.Matches(p, haystack, needle)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		return ok
+	}
+
+	pe.SubjectsMatched = match(p.GetSubjects(), request.Subject)
+	pe.ActionsMatched = match(p.GetActions(), request.Action)
+	pe.ResourcesMatched = match(p.GetResources(), request.Resource)
+	pe.Error = strings.Join(errs, "; ")
+
+	conditions := p.GetConditions()
+
+	keys := make([]string, 0, len(conditions))
+	for key := range conditions {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	fulfilled := true
+
+	for _, key := range keys {
+		condition := conditions[key]
+		value := request.Context[key]
+
+		ce := ConditionExplanation{
+			Key:       key,
+			Type:      condition.GetName(),
+			Value:     value,
+			Fulfilled: condition.Fulfills(value, request),
+		}
+
+		fulfilled = fulfilled && ce.Fulfilled
+		pe.Conditions = append(pe.Conditions, ce)
+	}
+
+	pe.Matched = pe.SubjectsMatched && pe.ActionsMatched && pe.ResourcesMatched && fulfilled
+
+	return pe
+}
+
+// PrintTable writes the explanation as a table of the policies, followed by the decision.
+func (e *Explanation) PrintTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "POLICY\tEFFECT\tSUBJECTS\tACTIONS\tRESOURCES\tCONDITIONS\tMATCHED")
+
+	for _, p := range e.Policies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.Effect, yesNo(p.SubjectsMatched),
+			yesNo(p.ActionsMatched), yesNo(p.ResourcesMatched), p.conditionsSummary(), yesNo(p.Matched))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\nDecision: %s (%s)\n", e.Effect, e.Reason); err != nil || len(e.Error) == 0 {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Error: %s\n", e.Error)
+
+	return err
+}
+
+// PrintJSON writes the explanation as indented JSON.
+func (e *Explanation) PrintJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// conditionsSummary returns "-" without conditions, "yes", or the conditions which failed.
+func (p PolicyExplanation) conditionsSummary() string {
+	if len(p.Conditions) == 0 {
+		return "-"
+	}
+
+	var failed []string
+
+	for _, c := range p.Conditions {
+		if !c.Fulfilled {
+			failed = append(failed, fmt.Sprintf("%s (%s)", c.Key, c.Type))
+		}
+	}
+
+	if len(failed) == 0 {
+		return "yes"
+	}
+
+	return "failed: " + strings.Join(failed, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
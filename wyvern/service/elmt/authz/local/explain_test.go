@@ -0,0 +1,190 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	v1 "github.com/opsdata/elmt-api/apiserver/v1"
+)
+
+func TestExplainPolicies(t *testing.T) {
+	// broken cannot be evaluated for the get action, and does not match the others
+	broken := testPolicy("broken", ladon.AllowAccess, []string{"users:<[>"}, []string{"get"}, []string{"<.+>"}, nil)
+	denyAll := testPolicy("deny-all", ladon.DenyAccess, []string{"<.+>"}, []string{"<.+>"}, []string{"<.+>"}, nil)
+
+	tests := []struct {
+		name        string
+		policies    []*v1.Policy
+		request     *ladon.Request
+		wantAllowed bool
+		wantPolicy  string
+		wantReason  string
+		wantError   bool
+	}{
+		{
+			name:        "allowed",
+			policies:    testPolicies(),
+			request:     &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+			wantAllowed: true,
+			wantPolicy:  "read-hosts",
+			wantReason:  "allowed by policy read-hosts",
+		},
+		{
+			name:       "forcefully denied",
+			policies:   testPolicies(),
+			request:    &ladon.Request{Subject: "admins:bob", Action: "delete", Resource: "audit:2024"},
+			wantPolicy: "keep-audit",
+			wantReason: "forcefully denied by policy keep-audit",
+		},
+		{
+			name:       "denied by default",
+			policies:   testPolicies(),
+			request:    &ladon.Request{Subject: "users:alice", Action: "delete", Resource: "hosts:web-1"},
+			wantReason: "denied by default, no policy matched",
+		},
+		{
+			name:     "condition not fulfilled",
+			policies: testPolicies(),
+			request: &ladon.Request{Subject: "users:alice", Action: "update", Resource: "hosts:web-1",
+				Context: ladon.Context{"ip": "192.168.1.1"}},
+			wantReason: "denied by default, no policy matched",
+		},
+		{
+			name:       "policy failing before an allowing one",
+			policies:   append([]*v1.Policy{broken}, testPolicies()...),
+			request:    &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+			wantPolicy: "broken",
+			wantReason: "denied, policy broken could not be evaluated",
+			wantError:  true,
+		},
+		{
+			name:       "policy failing after an allowing one",
+			policies:   append(testPolicies(), broken),
+			request:    &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+			wantPolicy: "broken",
+			wantReason: "denied, policy broken could not be evaluated",
+			wantError:  true,
+		},
+		{
+			name:       "deny reached before the failing policy",
+			policies:   []*v1.Policy{denyAll, broken},
+			request:    &ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"},
+			wantPolicy: "deny-all",
+			wantReason: "forcefully denied by policy deny-all",
+		},
+		{
+			name:        "failing policy skipped on its action",
+			policies:    append(testPolicies(), broken),
+			request:     &ladon.Request{Subject: "users:alice", Action: "list", Resource: "hosts:web-1"},
+			wantAllowed: true,
+			wantPolicy:  "read-hosts",
+			wantReason:  "allowed by policy read-hosts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ExplainPolicies(tt.policies, tt.request)
+
+			if e.Allowed != tt.wantAllowed || e.DecidingPolicy != tt.wantPolicy || e.Reason != tt.wantReason {
+				t.Errorf("ExplainPolicies() = allowed %v by %q (%s), want allowed %v by %q (%s)", e.Allowed,
+					e.DecidingPolicy, e.Reason, tt.wantAllowed, tt.wantPolicy, tt.wantReason)
+			}
+
+			if (len(e.Error) > 0) != tt.wantError {
+				t.Errorf("ExplainPolicies().Error = %q, want an error %v", e.Error, tt.wantError)
+			}
+
+			if wantEffect := map[bool]string{true: EffectAllow, false: EffectDeny}[tt.wantAllowed]; e.Effect != wantEffect {
+				t.Errorf("ExplainPolicies().Effect = %s, want %s", e.Effect, wantEffect)
+			}
+
+			if len(e.Policies) != len(tt.policies) {
+				t.Errorf("%d policies explained, want %d", len(e.Policies), len(tt.policies))
+			}
+
+			// The explanation always agrees with the authorizer
+			a, _ := newTestAuthorizer(t, tt.policies, Options{})
+
+			result, err := a.Authorize(context.Background(), tt.request, metav1.AuthorizeOptions{})
+			if err != nil {
+				t.Fatalf("Authorize() failed: %v", err)
+			}
+
+			if result.Allowed != e.Allowed {
+				t.Errorf("Authorize() allowed %v, the explanation %v", result.Allowed, e.Allowed)
+			}
+		})
+	}
+}
+
+func TestExplainPolicy(t *testing.T) {
+	policies := ladonPolicies(testPolicies())
+	request := &ladon.Request{Subject: "users:alice", Action: "update", Resource: "hosts:web-1",
+		Context: ladon.Context{"ip": "192.168.1.1"}}
+
+	tests := []struct {
+		policy    int
+		subjects  bool
+		actions   bool
+		resources bool
+		matched   bool
+		summary   string
+	}{
+		{policy: 0, subjects: true, resources: true, summary: "-"},
+		{policy: 1, actions: true, resources: true, summary: "-"},
+		{policy: 2, subjects: true, summary: "-"},
+		{policy: 3, subjects: true, actions: true, resources: true, summary: "failed: ip (CIDRCondition)"},
+	}
+
+	for _, tt := range tests {
+		pe := explainPolicy(policies[tt.policy], request)
+
+		if pe.SubjectsMatched != tt.subjects || pe.ActionsMatched != tt.actions ||
+			pe.ResourcesMatched != tt.resources || pe.Matched != tt.matched {
+			t.Errorf("policy %s explained as %+v", pe.ID, pe)
+		}
+
+		if summary := pe.conditionsSummary(); summary != tt.summary {
+			t.Errorf("policy %s conditions = %q, want %q", pe.ID, summary, tt.summary)
+		}
+	}
+}
+
+func TestExplanationPrint(t *testing.T) {
+	broken := testPolicy("broken", ladon.AllowAccess, []string{"users:<[>"}, []string{"get"}, []string{"<.+>"}, nil)
+	e := ExplainPolicies(append(testPolicies(), broken),
+		&ladon.Request{Subject: "users:alice", Action: "get", Resource: "hosts:web-1"})
+
+	var table bytes.Buffer
+	if err := e.PrintTable(&table); err != nil {
+		t.Fatalf("PrintTable() failed: %v", err)
+	}
+
+	for _, want := range []string{"POLICY", "read-hosts", "Decision: deny (denied, policy broken could not be evaluated)",
+		"Error: "} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("PrintTable() = %s, want it to contain %q", table.String(), want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := e.PrintJSON(&out); err != nil {
+		t.Fatalf("PrintJSON() failed: %v", err)
+	}
+
+	var decoded Explanation
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("PrintJSON() wrote invalid JSON: %v", err)
+	}
+
+	if decoded.Allowed || decoded.DecidingPolicy != "broken" || len(decoded.Error) == 0 || len(decoded.Policies) != 5 {
+		t.Errorf("PrintJSON() = %s", out.String())
+	}
+}
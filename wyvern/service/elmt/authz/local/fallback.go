@@ -0,0 +1,59 @@
+package local
+
+import (
+	"context"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	authzclientv1 "github.com/opsdata/elmt-sdk/wyvern/service/elmt/authz/v1"
+)
+
+// fallback calls the remote authorization, and evaluates the requests locally when it fails.
+type fallback struct {
+	remote authzclientv1.AuthzInterface
+	local  *Authorizer
+}
+
+// WithFallback
+// - return an AuthzInterface calling remote, and evaluating the requests with local when a call fails
+// - the requests of a batch which the server failed to evaluate are evaluated locally too
+func WithFallback(remote authzclientv1.AuthzInterface, local *Authorizer) authzclientv1.AuthzInterface {
+	return &fallback{remote: remote, local: local}
+}
+
+func (f *fallback) Authorize(ctx context.Context, request *ladon.Request,
+	opts metav1.AuthorizeOptions) (*authzv1.Response, error) {
+	result, err := f.remote.Authorize(ctx, request, opts)
+	if err == nil || !f.local.HasSynced() {
+		return result, err
+	}
+
+	return f.local.Authorize(ctx, request, opts)
+}
+
+func (f *fallback) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	results, err := f.remote.AuthorizeBatch(ctx, requests, opts)
+	if !f.local.HasSynced() {
+		return results, err
+	}
+
+	if err != nil {
+		return f.local.AuthorizeBatch(ctx, requests, opts)
+	}
+
+	for i, result := range results {
+		if result != nil && len(result.Error) == 0 {
+			continue
+		}
+
+		if results[i], err = f.local.Authorize(ctx, requests[i], opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
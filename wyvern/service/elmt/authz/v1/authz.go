@@ -2,6 +2,10 @@ package v1
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ory/ladon"
 
@@ -10,20 +14,37 @@ import (
 	rest "github.com/opsdata/elmt-sdk/rest"
 )
 
+// BatchParallelism is the maximum number of concurrent Authorize calls made by AuthorizeBatch
+// when the server has no batch endpoint.
+const BatchParallelism = 8
+
 // AuthzInterface interface
 // - methods to work with Authz resources
 //
 type AuthzInterface interface {
 	Authorize(ctx context.Context, request *ladon.Request, opts metav1.AuthorizeOptions) (*authzv1.Response, error)
+	AuthorizeBatch(ctx context.Context, requests []*ladon.Request, opts metav1.AuthorizeOptions) ([]*authzv1.Response, error)
+}
+
+// batchRequest is the body of the batch authorization endpoint.
+type batchRequest struct {
+	Requests []*ladon.Request `json:"requests"`
+}
+
+// batchResponse is the response of the batch authorization endpoint, in the order of the requests.
+type batchResponse struct {
+	Responses []*authzv1.Response `json:"responses"`
 }
 
 type authz struct {
-	client rest.Interface
+	client          rest.Interface
+	noBatchEndpoint *int32
 }
 
 func newAuthz(c *AuthzV1Client) *authz {
 	return &authz{
-		client: c.RESTClient(),
+		client:          c.RESTClient(),
+		noBatchEndpoint: &c.noBatchEndpoint,
 	}
 }
 
@@ -39,3 +60,103 @@ func (c *authz) Authorize(ctx context.Context, request *ladon.Request, opts meta
 
 	return
 }
+
+// AuthorizeBatch
+// - evaluate many requests at once and return their responses in the same order
+// - the batch endpoint of the server is used when it has one, otherwise the requests are sent one
+// by one, at most BatchParallelism at a time
+// - a request which could not be evaluated gets a response denying it, whose Error tells why; the
+// returned error is only set when the whole batch failed
+func (c *authz) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	if len(requests) == 0 {
+		return []*authzv1.Response{}, nil
+	}
+
+	if atomic.LoadInt32(c.noBatchEndpoint) == 0 {
+		results, supported, err := c.authorizeBatch(ctx, requests, opts)
+		if supported {
+			return results, err
+		}
+
+		atomic.StoreInt32(c.noBatchEndpoint, 1)
+	}
+
+	return c.authorizeEach(ctx, requests, opts)
+}
+
+// authorizeBatch calls the batch endpoint, supported is false if the server does not have one.
+func (c *authz) authorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) (results []*authzv1.Response, supported bool, err error) {
+	result := c.client.Post().
+		Resource("authz").
+		Suffix("batch").
+		VersionedParams(opts).
+		Body(batchRequest{Requests: requests}).
+		Do(ctx)
+
+	switch result.StatusCode() {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, false, nil
+	}
+
+	var resp batchResponse
+	if err := result.Into(&resp); err != nil {
+		return nil, true, err
+	}
+
+	if len(resp.Responses) != len(requests) {
+		return nil, true, fmt.Errorf("the batch authorization returned %d responses for %d requests",
+			len(resp.Responses), len(requests))
+	}
+
+	for i, r := range resp.Responses {
+		if r == nil {
+			resp.Responses[i] = &authzv1.Response{Denied: true, Error: "no response to this request"}
+		}
+	}
+
+	return resp.Responses, true, nil
+}
+
+// authorizeEach calls Authorize for every request, at most BatchParallelism at a time.
+func (c *authz) authorizeEach(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	results := make([]*authzv1.Response, len(requests))
+	sem := make(chan struct{}, BatchParallelism)
+
+	var wg sync.WaitGroup
+
+	for i := range requests {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result, err := c.Authorize(ctx, requests[i], opts)
+			if err != nil {
+				result = &authzv1.Response{Denied: true, Error: err.Error()}
+			}
+
+			results[i] = result
+		}(i)
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -23,6 +23,9 @@ type AuthzV1Interface interface {
 
 type AuthzV1Client struct {
 	restClient rest.Interface
+
+	// noBatchEndpoint is set once the server answered that it has no batch authorization endpoint
+	noBatchEndpoint int32
 }
 
 func (c *AuthzV1Client) Authz() AuthzInterface {
@@ -44,7 +47,7 @@ func (c *AuthzV1Client) RESTClient() rest.Interface {
  */
 
 func New(c rest.Interface) *AuthzV1Client {
-	return &AuthzV1Client{c}
+	return &AuthzV1Client{restClient: c}
 }
 
 func NewForConfig(c *rest.Config) (*AuthzV1Client, error) {
@@ -56,7 +59,7 @@ func NewForConfig(c *rest.Config) (*AuthzV1Client, error) {
 		return nil, err
 	}
 
-	return &AuthzV1Client{client}, nil
+	return &AuthzV1Client{restClient: client}, nil
 }
 
 func NewForConfigOrDie(c *rest.Config) *AuthzV1Client {
@@ -0,0 +1,403 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+/*
+ * authzServer:
+ * - stand in for the authorization endpoints of the ELMT server
+ * - a request is allowed when its action is get, a subject named broken fails with a server error
+ * - without batch, the batch endpoint answers batchStatus, 404 by default
+ */
+
+type authzServer struct {
+	*httptest.Server
+
+	batch       bool
+	batchStatus int
+	batchBody   string
+	delay       time.Duration
+
+	mu          sync.Mutex
+	singles     int
+	batches     int
+	inFlight    int
+	maxInFlight int
+}
+
+func newAuthzServer(t *testing.T, configure func(s *authzServer)) *authzServer {
+	t.Helper()
+
+	s := &authzServer{batchStatus: http.StatusNotFound}
+	if configure != nil {
+		configure(s)
+	}
+
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func decide(request *ladon.Request) *authzv1.Response {
+	if request.Action == "get" {
+		return &authzv1.Response{Allowed: true}
+	}
+
+	return &authzv1.Response{Denied: true, Reason: "no policy allows " + request.Action}
+}
+
+func (s *authzServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/authz/batch") {
+		s.mu.Lock()
+		s.batches++
+		s.mu.Unlock()
+
+		if !s.batch {
+			w.WriteHeader(s.batchStatus)
+			return
+		}
+
+		if len(s.batchBody) > 0 {
+			_, _ = w.Write([]byte(s.batchBody))
+			return
+		}
+
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := batchResponse{}
+		for _, request := range req.Requests {
+			resp.Responses = append(resp.Responses, decide(request))
+		}
+
+		_ = json.NewEncoder(w).Encode(resp)
+
+		return
+	}
+
+	s.mu.Lock()
+	s.singles++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(s.delay)
+
+	var request ladon.Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Subject == "broken" {
+		http.Error(w, `{"message":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(decide(&request))
+}
+
+func (s *authzServer) counts() (singles, batches int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.singles, s.batches
+}
+
+func (s *authzServer) client(t *testing.T) AuthzInterface {
+	t.Helper()
+
+	client, err := NewForConfig(&rest.Config{Host: s.URL})
+	if err != nil {
+		t.Fatalf("NewForConfig() failed: %v", err)
+	}
+
+	return client.Authz()
+}
+
+func testRequests(actions ...string) []*ladon.Request {
+	requests := make([]*ladon.Request, len(actions))
+	for i, action := range actions {
+		subject := "alice"
+		if action == "broken" {
+			subject, action = "broken", "get"
+		}
+
+		requests[i] = &ladon.Request{Subject: subject, Action: action, Resource: fmt.Sprintf("resources:%d", i)}
+	}
+
+	return requests
+}
+
+func TestAuthorize(t *testing.T) {
+	server := newAuthzServer(t, nil)
+	authz := server.client(t)
+
+	tests := []struct {
+		action      string
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{action: "get", wantAllowed: true},
+		{action: "delete"},
+		{action: "broken", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := authz.Authorize(context.Background(), testRequests(tt.action)[0], metav1.AuthorizeOptions{})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Authorize(%s) error = %v, want error %v", tt.action, err, tt.wantErr)
+		}
+
+		if err == nil && result.Allowed != tt.wantAllowed {
+			t.Errorf("Authorize(%s) = %+v, want allowed %v", tt.action, result, tt.wantAllowed)
+		}
+	}
+}
+
+func TestAuthorizeBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		batch       bool
+		batchStatus int
+		actions     []string
+		wantAllowed []bool
+		wantErrors  []bool
+		wantSingles int
+		wantBatches int
+	}{
+		{
+			name:        "batch endpoint",
+			batch:       true,
+			actions:     []string{"get", "delete", "get"},
+			wantAllowed: []bool{true, false, true},
+			wantErrors:  []bool{false, false, false},
+			wantBatches: 1,
+		},
+		{
+			name:        "fallback on 404",
+			actions:     []string{"get", "delete", "broken", "get"},
+			wantAllowed: []bool{true, false, false, true},
+			wantErrors:  []bool{false, false, true, false},
+			wantSingles: 4,
+			wantBatches: 1,
+		},
+		{
+			name:        "fallback on 405",
+			batchStatus: http.StatusMethodNotAllowed,
+			actions:     []string{"delete"},
+			wantAllowed: []bool{false},
+			wantErrors:  []bool{false},
+			wantSingles: 1,
+			wantBatches: 1,
+		},
+		{
+			name:        "fallback on 501",
+			batchStatus: http.StatusNotImplemented,
+			actions:     []string{"get"},
+			wantAllowed: []bool{true},
+			wantErrors:  []bool{false},
+			wantSingles: 1,
+			wantBatches: 1,
+		},
+		{
+			name:        "no requests",
+			actions:     []string{},
+			wantAllowed: []bool{},
+			wantErrors:  []bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthzServer(t, func(s *authzServer) {
+				s.batch = tt.batch
+				if tt.batchStatus != 0 {
+					s.batchStatus = tt.batchStatus
+				}
+			})
+			authz := server.client(t)
+
+			results, err := authz.AuthorizeBatch(context.Background(), testRequests(tt.actions...), metav1.AuthorizeOptions{})
+			if err != nil {
+				t.Fatalf("AuthorizeBatch() failed: %v", err)
+			}
+
+			if len(results) != len(tt.actions) {
+				t.Fatalf("AuthorizeBatch() returned %d responses, want %d", len(results), len(tt.actions))
+			}
+
+			for i, result := range results {
+				if result.Allowed != tt.wantAllowed[i] || (len(result.Error) > 0) != tt.wantErrors[i] {
+					t.Errorf("response %d = %+v, want allowed %v, error %v", i, result, tt.wantAllowed[i], tt.wantErrors[i])
+				}
+
+				if !result.Allowed && !result.Denied {
+					t.Errorf("response %d neither allows nor denies", i)
+				}
+			}
+
+			if singles, batches := server.counts(); singles != tt.wantSingles || batches != tt.wantBatches {
+				t.Errorf("%d single and %d batch calls, want %d and %d", singles, batches, tt.wantSingles, tt.wantBatches)
+			}
+		})
+	}
+}
+
+func TestAuthorizeBatchRemembersFallback(t *testing.T) {
+	server := newAuthzServer(t, nil)
+
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Authz() of the same client share what was learned about the server
+	for i := 0; i < 3; i++ {
+		if _, err := client.Authz().AuthorizeBatch(context.Background(), testRequests("get"), metav1.AuthorizeOptions{}); err != nil {
+			t.Fatalf("AuthorizeBatch() failed: %v", err)
+		}
+	}
+
+	if singles, batches := server.counts(); singles != 3 || batches != 1 {
+		t.Errorf("%d single and %d batch calls, want 3 and 1", singles, batches)
+	}
+}
+
+func TestAuthorizeBatchErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchStatus int
+		batchBody   string
+		wantErr     string
+		wantDenied  []bool
+	}{
+		{
+			name:      "fewer responses",
+			batchBody: `{"responses":[{"allowed":true}]}`,
+			wantErr:   "the batch authorization returned 1 responses for 2 requests",
+		},
+		{
+			name:       "missing response",
+			batchBody:  `{"responses":[{"allowed":true},null]}`,
+			wantDenied: []bool{false, true},
+		},
+		{
+			name:        "server error",
+			batchStatus: http.StatusInternalServerError,
+			wantErr:     "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAuthzServer(t, func(s *authzServer) {
+				s.batch = tt.batchStatus == 0
+				s.batchBody = tt.batchBody
+				if tt.batchStatus != 0 {
+					s.batchStatus = tt.batchStatus
+				}
+			})
+
+			if tt.batchStatus != 0 {
+				server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, `{"message":"internal error"}`, tt.batchStatus)
+				})
+			}
+
+			results, err := server.client(t).AuthorizeBatch(context.Background(), testRequests("get", "get"), metav1.AuthorizeOptions{})
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("AuthorizeBatch() = %v, want an error containing %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("AuthorizeBatch() failed: %v", err)
+			}
+
+			for i, result := range results {
+				if result.Denied != tt.wantDenied[i] {
+					t.Errorf("response %d = %+v, want denied %v", i, result, tt.wantDenied[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeBatchParallelism(t *testing.T) {
+	server := newAuthzServer(t, func(s *authzServer) {
+		s.delay = 20 * time.Millisecond
+	})
+
+	actions := make([]string, 3*BatchParallelism)
+	for i := range actions {
+		actions[i] = "get"
+	}
+
+	results, err := server.client(t).AuthorizeBatch(context.Background(), testRequests(actions...), metav1.AuthorizeOptions{})
+	if err != nil {
+		t.Fatalf("AuthorizeBatch() failed: %v", err)
+	}
+
+	for i, result := range results {
+		if result == nil || !result.Allowed {
+			t.Errorf("response %d = %+v, want allowed", i, result)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.maxInFlight > BatchParallelism || server.maxInFlight < 2 {
+		t.Errorf("%d concurrent calls, want between 2 and %d", server.maxInFlight, BatchParallelism)
+	}
+}
+
+func TestAuthorizeBatchCanceled(t *testing.T) {
+	server := newAuthzServer(t, func(s *authzServer) {
+		s.delay = 50 * time.Millisecond
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	actions := make([]string, 2*BatchParallelism)
+	for i := range actions {
+		actions[i] = "get"
+	}
+
+	if _, err := server.client(t).AuthorizeBatch(ctx, testRequests(actions...), metav1.AuthorizeOptions{}); err == nil {
+		t.Errorf("AuthorizeBatch() succeeded after the context expired")
+	}
+}
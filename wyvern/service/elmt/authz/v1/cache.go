@@ -0,0 +1,332 @@
+package v1
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+
+	"github.com/opsdata/elmt-sdk/third_party/forked/murmur3"
+)
+
+const (
+	// DefaultCacheSize is the number of decisions kept by a CachedAuthz, unless CacheOptions.Size is set.
+	DefaultCacheSize = 10000
+
+	// DefaultAllowTTL is how long an allow decision is cached, unless CacheOptions.AllowTTL is set.
+	DefaultAllowTTL = time.Minute
+
+	// DefaultDenyTTL is how long a deny decision is cached, unless CacheOptions.DenyTTL is set.
+	DefaultDenyTTL = 10 * time.Second
+)
+
+// CacheOptions defines the size and the lifetimes of the decisions of a CachedAuthz.
+type CacheOptions struct {
+	// Size is the maximum number of decisions, the least recently used is evicted first.
+	Size int
+
+	// AllowTTL and DenyTTL are how long the allow and deny decisions are cached, a negative
+	// TTL disables caching of the decisions of that kind.
+	AllowTTL time.Duration
+	DenyTTL  time.Duration
+}
+
+// CacheStats are the counters of a CachedAuthz.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// cacheKey is the 128 bits murmur3 hash of a normalized request.
+type cacheKey [2]uint64
+
+// cacheEntry keeps the normalized request next to its decision: two requests with the same hash
+// are told apart by comparing it, so that a collision can never return the decision of another
+// request.
+type cacheEntry struct {
+	key      cacheKey
+	request  []byte
+	subject  string
+	response authzv1.Response
+	expires  time.Time
+}
+
+/*
+ * CachedAuthz:
+ * - decorate an AuthzInterface with a bounded LRU cache of its decisions
+ * - the requests are identified by the murmur3 hash of their subject, action, resource, context
+ * and authorize options, and compared in full on a hit
+ * - failed calls and responses carrying an error are never cached
+ */
+
+type CachedAuthz struct {
+	delegate AuthzInterface
+	options  CacheOptions
+	now      func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[cacheKey]*list.Element
+	stats   CacheStats
+}
+
+var _ AuthzInterface = &CachedAuthz{}
+
+// NewCachedAuthz
+// - create a CachedAuthz calling delegate on cache misses
+// - the zero fields of options take their default value
+//
+// - 示例:
+//
+//	authz := v1.NewCachedAuthz(clientset.Elmt().AuthzV1().Authz(), v1.CacheOptions{DenyTTL: time.Second})
+//	ret, err := authz.Authorize(ctx, request, metav1.AuthorizeOptions{})
+func NewCachedAuthz(delegate AuthzInterface, options CacheOptions) *CachedAuthz {
+	if options.Size <= 0 {
+		options.Size = DefaultCacheSize
+	}
+
+	if options.AllowTTL == 0 {
+		options.AllowTTL = DefaultAllowTTL
+	}
+
+	if options.DenyTTL == 0 {
+		options.DenyTTL = DefaultDenyTTL
+	}
+
+	return &CachedAuthz{
+		delegate: delegate,
+		options:  options,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+	}
+}
+
+func (c *CachedAuthz) Authorize(ctx context.Context, request *ladon.Request,
+	opts metav1.AuthorizeOptions) (*authzv1.Response, error) {
+	key, data, ok := requestKey(request, opts)
+	if !ok {
+		return c.delegate.Authorize(ctx, request, opts)
+	}
+
+	if result, ok := c.get(key, data); ok {
+		return result, nil
+	}
+
+	result, err := c.delegate.Authorize(ctx, request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(key, data, request.Subject, result)
+
+	return result, nil
+}
+
+// AuthorizeBatch serves the cached decisions and sends the other requests in a single batch.
+func (c *CachedAuthz) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	results := make([]*authzv1.Response, len(requests))
+	keys := make([]cacheKey, len(requests))
+	data := make([][]byte, len(requests))
+	cacheable := make([]bool, len(requests))
+
+	var (
+		missed  []*ladon.Request
+		indexes []int
+	)
+
+	for i, request := range requests {
+		if keys[i], data[i], cacheable[i] = requestKey(request, opts); cacheable[i] {
+			if result, ok := c.get(keys[i], data[i]); ok {
+				results[i] = result
+				continue
+			}
+		}
+
+		missed = append(missed, request)
+		indexes = append(indexes, i)
+	}
+
+	if len(missed) == 0 {
+		return results, nil
+	}
+
+	missedResults, err := c.delegate.AuthorizeBatch(ctx, missed, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, result := range missedResults {
+		i := indexes[j]
+		results[i] = result
+
+		if cacheable[i] {
+			c.add(keys[i], data[i], requests[i].Subject, result)
+		}
+	}
+
+	return results, nil
+}
+
+// Invalidate drops the cached decision of the request authorized with opts, if any.
+func (c *CachedAuthz) Invalidate(request *ladon.Request, opts metav1.AuthorizeOptions) {
+	key, data, ok := requestKey(request, opts)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok && bytes.Equal(elem.Value.(*cacheEntry).request, data) {
+		c.remove(elem)
+	}
+}
+
+// InvalidateSubject drops the cached decisions of a subject, such as after its policies changed.
+func (c *CachedAuthz) InvalidateSubject(subject string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).subject == subject {
+			c.remove(elem)
+		}
+
+		elem = next
+	}
+}
+
+// InvalidateAll drops every cached decision.
+func (c *CachedAuthz) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[cacheKey]*list.Element)
+}
+
+// Stats returns the counters of the cache since it was created.
+func (c *CachedAuthz) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+
+	return stats
+}
+
+// get returns a copy of the cached response, so that the callers cannot alter the cache.
+func (c *CachedAuthz) get(key cacheKey, data []byte) (*authzv1.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok && !bytes.Equal(elem.Value.(*cacheEntry).request, data) {
+		// another request with the same hash, the next add replaces it
+		ok = false
+	} else if ok && c.now().After(elem.Value.(*cacheEntry).expires) {
+		c.remove(elem)
+
+		ok = false
+	}
+
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+
+	response := elem.Value.(*cacheEntry).response
+
+	return &response, true
+}
+
+func (c *CachedAuthz) add(key cacheKey, data []byte, subject string, response *authzv1.Response) {
+	if response == nil || len(response.Error) > 0 {
+		return
+	}
+
+	ttl := c.options.DenyTTL
+	if response.Allowed {
+		ttl = c.options.AllowTTL
+	}
+
+	if ttl < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{
+		key:      key,
+		request:  data,
+		subject:  subject,
+		response: *response,
+		expires:  c.now().Add(ttl),
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.options.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *CachedAuthz) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// requestKey
+// - normalize the subject, action, resource and context of the request, and the options it is
+// authorized with, and hash them
+// - the context is encoded as JSON, which sorts the keys of the maps, so that equal contexts
+// give the same key whatever the order they were built in
+// - the options are part of the key since the server may decide differently for other options,
+// such as another API version
+// - ok is false if the request cannot be cached, because its context cannot be encoded
+func requestKey(request *ladon.Request, opts metav1.AuthorizeOptions) (key cacheKey, data []byte, ok bool) {
+	if request == nil {
+		return key, nil, false
+	}
+
+	data, err := json.Marshal(struct {
+		Subject  string                  `json:"s"`
+		Action   string                  `json:"a"`
+		Resource string                  `json:"r"`
+		Context  ladon.Context           `json:"c"`
+		Options  metav1.AuthorizeOptions `json:"o"`
+	}{request.Subject, request.Action, request.Resource, request.Context, opts})
+	if err != nil {
+		return key, nil, false
+	}
+
+	key[0], key[1] = murmur3.Sum128(data)
+
+	return key, data, true
+}
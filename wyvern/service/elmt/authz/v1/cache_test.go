@@ -0,0 +1,363 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ory/ladon"
+
+	metav1 "github.com/opsdata/common-base/pkg/meta/v1"
+	authzv1 "github.com/opsdata/elmt-api/authz/v1"
+)
+
+// fakeAuthz allows the get action, fails the subject broken and counts the requests it evaluates.
+type fakeAuthz struct {
+	mu      sync.Mutex
+	calls   int
+	batches [][]*ladon.Request
+}
+
+func (a *fakeAuthz) Authorize(ctx context.Context, request *ladon.Request,
+	opts metav1.AuthorizeOptions) (*authzv1.Response, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.calls++
+
+	switch {
+	case request.Subject == "broken":
+		return nil, errors.New("authorization server unavailable")
+	case request.Subject == "erroneous":
+		return &authzv1.Response{Denied: true, Error: "no policy could be evaluated"}, nil
+	}
+
+	return decide(request), nil
+}
+
+func (a *fakeAuthz) AuthorizeBatch(ctx context.Context, requests []*ladon.Request,
+	opts metav1.AuthorizeOptions) ([]*authzv1.Response, error) {
+	a.mu.Lock()
+	a.batches = append(a.batches, requests)
+	a.mu.Unlock()
+
+	results := make([]*authzv1.Response, len(requests))
+
+	for i, request := range requests {
+		result, err := a.Authorize(ctx, request, opts)
+		if err != nil {
+			result = &authzv1.Response{Denied: true, Error: err.Error()}
+		}
+
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+func (a *fakeAuthz) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.calls
+}
+
+// testClock is a settable CachedAuthz.now.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestCache(options CacheOptions) (*CachedAuthz, *fakeAuthz, *testClock) {
+	delegate := &fakeAuthz{}
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+
+	cache := NewCachedAuthz(delegate, options)
+	cache.now = clock.Now
+
+	return cache, delegate, clock
+}
+
+func TestCachedAuthz(t *testing.T) {
+	ctx := context.Background()
+	v2 := metav1.AuthorizeOptions{TypeMeta: metav1.TypeMeta{APIVersion: "v2"}}
+
+	tests := []struct {
+		name    string
+		options CacheOptions
+		first   *ladon.Request
+		// then is authorized after advancing the clock by after
+		then      *ladon.Request
+		thenOpts  metav1.AuthorizeOptions
+		after     time.Duration
+		wantCalls int
+	}{
+		{
+			name:      "allow cached",
+			first:     &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			after:     DefaultAllowTTL,
+			wantCalls: 1,
+		},
+		{
+			name:      "allow expired",
+			first:     &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			after:     DefaultAllowTTL + time.Second,
+			wantCalls: 2,
+		},
+		{
+			name:      "deny expires sooner",
+			first:     &ladon.Request{Subject: "alice", Action: "delete", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "delete", Resource: "hosts:1"},
+			after:     DefaultDenyTTL + time.Second,
+			wantCalls: 2,
+		},
+		{
+			name:      "deny caching disabled",
+			options:   CacheOptions{DenyTTL: -1},
+			first:     &ladon.Request{Subject: "alice", Action: "delete", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "delete", Resource: "hosts:1"},
+			wantCalls: 2,
+		},
+		{
+			name:      "other resource",
+			first:     &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:2"},
+			wantCalls: 2,
+		},
+		{
+			name: "same context built in another order",
+			first: &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1",
+				Context: ladon.Context{"ip": "10.0.0.1", "owner": "alice"}},
+			then: &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1",
+				Context: ladon.Context{"owner": "alice", "ip": "10.0.0.1"}},
+			wantCalls: 1,
+		},
+		{
+			name: "other context",
+			first: &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1",
+				Context: ladon.Context{"ip": "10.0.0.1"}},
+			then: &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1",
+				Context: ladon.Context{"ip": "10.0.0.2"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "other options",
+			first:     &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			then:      &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"},
+			thenOpts:  v2,
+			wantCalls: 2,
+		},
+		{
+			name:      "failed call",
+			first:     &ladon.Request{Subject: "broken", Action: "get"},
+			then:      &ladon.Request{Subject: "broken", Action: "get"},
+			wantCalls: 2,
+		},
+		{
+			name:      "response with an error",
+			first:     &ladon.Request{Subject: "erroneous", Action: "get"},
+			then:      &ladon.Request{Subject: "erroneous", Action: "get"},
+			wantCalls: 2,
+		},
+		{
+			name: "context which cannot be encoded",
+			first: &ladon.Request{Subject: "alice", Action: "get",
+				Context: ladon.Context{"callback": func() {}}},
+			then: &ladon.Request{Subject: "alice", Action: "get",
+				Context: ladon.Context{"callback": func() {}}},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, delegate, clock := newTestCache(tt.options)
+
+			first, firstErr := cache.Authorize(ctx, tt.first, metav1.AuthorizeOptions{})
+			clock.now = clock.now.Add(tt.after)
+			then, thenErr := cache.Authorize(ctx, tt.then, tt.thenOpts)
+
+			if calls := delegate.count(); calls != tt.wantCalls {
+				t.Errorf("the delegate was called %d times, want %d", calls, tt.wantCalls)
+			}
+
+			if (firstErr != nil) != (thenErr != nil) {
+				t.Fatalf("errors = %v, %v, want the same outcome", firstErr, thenErr)
+			}
+
+			if firstErr == nil && tt.first.Resource == tt.then.Resource && *first != *then {
+				t.Errorf("responses = %+v, %+v, want the same decision", first, then)
+			}
+		})
+	}
+}
+
+func TestCachedAuthzCollision(t *testing.T) {
+	ctx := context.Background()
+	cache, delegate, _ := newTestCache(CacheOptions{})
+
+	alice := &ladon.Request{Subject: "alice", Action: "get", Resource: "hosts:1"}
+	mallory := &ladon.Request{Subject: "mallory", Action: "delete", Resource: "hosts:1"}
+
+	// Give mallory the hash of alice, as a murmur3 collision would
+	aliceKey, _, _ := requestKey(alice, metav1.AuthorizeOptions{})
+	_, malloryData, _ := requestKey(mallory, metav1.AuthorizeOptions{})
+	cache.add(aliceKey, malloryData, mallory.Subject, &authzv1.Response{Denied: true})
+
+	result, err := cache.Authorize(ctx, alice, metav1.AuthorizeOptions{})
+	if err != nil || !result.Allowed {
+		t.Fatalf("Authorize(alice) = %+v, %v, want the decision of the delegate", result, err)
+	}
+
+	if calls := delegate.count(); calls != 1 {
+		t.Errorf("the delegate was called %d times, want 1", calls)
+	}
+
+	// The entry of alice replaced the colliding one
+	if _, err := cache.Authorize(ctx, alice, metav1.AuthorizeOptions{}); err != nil || delegate.count() != 1 {
+		t.Errorf("Authorize(alice) not served from the cache, %d calls", delegate.count())
+	}
+
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("Stats() = %+v, want 1 hit, 1 miss and 1 entry", stats)
+	}
+}
+
+func TestCachedAuthzEviction(t *testing.T) {
+	ctx := context.Background()
+	cache, delegate, _ := newTestCache(CacheOptions{Size: 2})
+
+	request := func(resource string) *ladon.Request {
+		return &ladon.Request{Subject: "alice", Action: "get", Resource: resource}
+	}
+
+	for _, resource := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := cache.Authorize(ctx, request(resource), metav1.AuthorizeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b is evicted by c as a was used more recently, then c by b
+	if calls := delegate.count(); calls != 4 {
+		t.Errorf("the delegate was called %d times, want 4", calls)
+	}
+
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 4 || stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCachedAuthzInvalidate(t *testing.T) {
+	ctx := context.Background()
+	v2 := metav1.AuthorizeOptions{TypeMeta: metav1.TypeMeta{APIVersion: "v2"}}
+
+	requests := []*ladon.Request{
+		{Subject: "alice", Action: "get", Resource: "hosts:1"},
+		{Subject: "alice", Action: "get", Resource: "hosts:2"},
+		{Subject: "bob", Action: "get", Resource: "hosts:1"},
+	}
+
+	tests := []struct {
+		name       string
+		invalidate func(cache *CachedAuthz)
+		wantSize   int
+	}{
+		{
+			name:       "request",
+			invalidate: func(cache *CachedAuthz) { cache.Invalidate(requests[0], metav1.AuthorizeOptions{}) },
+			wantSize:   2,
+		},
+		{
+			name:       "request with other options",
+			invalidate: func(cache *CachedAuthz) { cache.Invalidate(requests[0], v2) },
+			wantSize:   3,
+		},
+		{
+			name:       "subject",
+			invalidate: func(cache *CachedAuthz) { cache.InvalidateSubject("alice") },
+			wantSize:   1,
+		},
+		{
+			name:       "all",
+			invalidate: func(cache *CachedAuthz) { cache.InvalidateAll() },
+			wantSize:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, delegate, _ := newTestCache(CacheOptions{})
+
+			for _, request := range requests {
+				if _, err := cache.Authorize(ctx, request, metav1.AuthorizeOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tt.invalidate(cache)
+
+			if size := cache.Stats().Size; size != tt.wantSize {
+				t.Errorf("%d cached decisions, want %d", size, tt.wantSize)
+			}
+
+			for _, request := range requests {
+				if _, err := cache.Authorize(ctx, request, metav1.AuthorizeOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if calls := delegate.count(); calls != 2*len(requests)-tt.wantSize {
+				t.Errorf("the delegate was called %d times, want %d", calls, 2*len(requests)-tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestCachedAuthzBatch(t *testing.T) {
+	ctx := context.Background()
+	cache, delegate, _ := newTestCache(CacheOptions{})
+
+	requests := testRequests("get", "delete", "broken", "get")
+
+	if _, err := cache.Authorize(ctx, requests[1], metav1.AuthorizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := cache.AuthorizeBatch(ctx, requests, metav1.AuthorizeOptions{})
+	if err != nil {
+		t.Fatalf("AuthorizeBatch() failed: %v", err)
+	}
+
+	for i, want := range []bool{true, false, false, true} {
+		if results[i].Allowed != want {
+			t.Errorf("response %d = %+v, want allowed %v", i, results[i], want)
+		}
+	}
+
+	if len(delegate.batches) != 1 || len(delegate.batches[0]) != 3 {
+		t.Fatalf("batches = %v, want a single batch of the 3 requests not cached", delegate.batches)
+	}
+
+	// The failed request is not cached, the others are
+	if _, err := cache.AuthorizeBatch(ctx, requests, metav1.AuthorizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(delegate.batches) != 2 || len(delegate.batches[1]) != 1 || delegate.batches[1][0] != requests[2] {
+		t.Errorf("batches = %v, want a second batch of the failed request", delegate.batches)
+	}
+
+	// A cached response cannot be altered by a caller
+	results[0].Allowed = false
+
+	if result, _ := cache.Authorize(ctx, requests[0], metav1.AuthorizeOptions{}); !result.Allowed {
+		t.Errorf("the cached decision was altered by a caller")
+	}
+}
@@ -31,6 +31,15 @@ func (c *ElmtClient) AuthzV1() authzv1.AuthzV1Interface {
 	return c.authzV1
 }
 
+// Close releases the idle connections kept by the clients of every elmt service.
+func (c *ElmtClient) Close() {
+	for _, client := range []rest.Interface{c.apiV1.RESTClient(), c.authzV1.RESTClient()} {
+		if closer, ok := client.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+}
+
 // NewForConfig creates a new ElmtV1Client for the given config.
 func NewForConfig(c *rest.Config) (*ElmtClient, error) {
 	configShallowCopy := *c
@@ -58,6 +67,7 @@ func NewForConfig(c *rest.Config) (*ElmtClient, error) {
 func NewForConfigOrDie(c *rest.Config) *ElmtClient {
 	var ec ElmtClient
 	ec.apiV1 = apiv1.NewForConfigOrDie(c)
+	ec.authzV1 = authzv1.NewForConfigOrDie(c)
 	return &ec
 }
 
@@ -65,5 +75,6 @@ func NewForConfigOrDie(c *rest.Config) *ElmtClient {
 func New(c rest.Interface) *ElmtClient {
 	var ec ElmtClient
 	ec.apiV1 = apiv1.New(c)
+	ec.authzV1 = authzv1.New(c)
 	return &ec
 }
@@ -0,0 +1,223 @@
+package zabbix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultBatchSize is the maximum number of calls a Batch sends in a single request, unless
+// configured otherwise by rest.Config.ZabbixBatchSize.
+const DefaultBatchSize = 100
+
+// BatchCall is a call queued in a Batch. Once the batch is sent, Err holds the error of the call,
+// and Result its decoded result.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+}
+
+/*
+ * Batch:
+ * - queue calls to the Zabbix API and send them as JSON-RPC batch requests
+ * - the queued calls are split into requests of at most MaxSize calls
+ * - each call fails or succeeds on its own, its error is set in BatchCall.Err
+ */
+
+type Batch struct {
+	client  *ZabbixClient
+	maxSize int
+
+	calls []*BatchCall
+	sent  int
+}
+
+// Batch returns a new empty Batch, sending at most the configured batch size of calls per request.
+func (c *ZabbixClient) Batch() *Batch {
+	return &Batch{client: c, maxSize: c.batchSize}
+}
+
+// SetMaxSize sets the maximum number of calls sent in a single request, a size <= 0 means DefaultBatchSize.
+func (b *Batch) SetMaxSize(size int) *Batch {
+	b.maxSize = size
+	return b
+}
+
+// Add queues a call of method, whose result will be decoded into result, unless it is nil.
+func (b *Batch) Add(method string, params interface{}, result interface{}) *BatchCall {
+	call := &BatchCall{Method: method, Params: params, Result: result}
+	b.calls = append(b.calls, call)
+
+	return call
+}
+
+// Len returns the number of calls which were queued and not sent yet.
+func (b *Batch) Len() int {
+	return len(b.calls) - b.sent
+}
+
+// Calls returns every call added to the batch, sent or not.
+func (b *Batch) Calls() []*BatchCall {
+	return b.calls
+}
+
+// Err returns the error of the first failed call of the batch, or nil if they all succeeded.
+func (b *Batch) Err() error {
+	for _, call := range b.calls[:b.sent] {
+		if call.Err != nil {
+			return call.Err
+		}
+	}
+
+	return nil
+}
+
+// Send
+// - send the queued calls, in as many requests as needed, and set their Result and Err
+// - the returned error is not nil only when a request failed as a whole, such as when the
+// Zabbix API cannot be reached, the calls of the failed and following requests then carry it too
+// - with user credentials, the calls failing because the session expired are sent again once
+// after logging in again
+func (b *Batch) Send(ctx context.Context) error {
+	calls := b.calls[b.sent:]
+	b.sent = len(b.calls)
+
+	if len(calls) == 0 {
+		return nil
+	}
+
+	if err := b.client.err; err != nil {
+		return failCalls(calls, err)
+	}
+
+	auth, err := b.auth(ctx, calls)
+	if err != nil {
+		return failCalls(calls, err)
+	}
+
+	if err := b.send(ctx, calls, auth); err != nil {
+		return err
+	}
+
+	if len(b.client.credentials.Token) > 0 {
+		return nil
+	}
+
+	var expired []*BatchCall
+
+	for _, call := range calls {
+		if IsSessionExpired(call.Err) {
+			expired = append(expired, call)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	b.client.invalidate(auth)
+
+	if auth, err = b.auth(ctx, expired); err != nil {
+		return failCalls(expired, err)
+	}
+
+	return b.send(ctx, expired, auth)
+}
+
+// auth returns the value of the auth member of the requests, or "" if none of the calls needs it.
+func (b *Batch) auth(ctx context.Context, calls []*BatchCall) (string, error) {
+	for _, call := range calls {
+		if requiresAuth(call.Method) {
+			return b.client.auth(ctx)
+		}
+	}
+
+	return "", nil
+}
+
+// send sends the calls in requests of at most maxSize calls.
+func (b *Batch) send(ctx context.Context, calls []*BatchCall, auth string) error {
+	size := b.maxSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	for start := 0; start < len(calls); start += size {
+		end := start + size
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		if err := b.client.callBatch(ctx, calls[start:end], auth); err != nil {
+			return failCalls(calls[start:], err)
+		}
+	}
+
+	return nil
+}
+
+// failCalls sets err as the error of the calls and returns it.
+func failCalls(calls []*BatchCall, err error) error {
+	for _, call := range calls {
+		call.Err = err
+	}
+
+	return err
+}
+
+// callBatch sends the calls as a single JSON-RPC batch request and correlates the responses by id.
+func (c *ZabbixClient) callBatch(ctx context.Context, calls []*BatchCall, auth string) error {
+	reqs := make([]request, len(calls))
+	pending := make(map[uint64]*BatchCall, len(calls))
+
+	for i, call := range calls {
+		callAuth := ""
+		if requiresAuth(call.Method) {
+			callAuth = auth
+		}
+
+		reqs[i] = c.newRequest(call.Method, call.Params, callAuth)
+		pending[reqs[i].ID] = call
+	}
+
+	body, err := c.post(ctx, "batch", reqs)
+	if err != nil {
+		return err
+	}
+
+	// The whole batch is rejected with a single error response, such as when it cannot be parsed
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '{' {
+		var resp response
+		if err := json.Unmarshal(body, &resp); err != nil || resp.Error == nil {
+			return fmt.Errorf("zabbix: invalid JSON-RPC batch response: expected an array")
+		}
+
+		resp.Error.Method = "batch"
+
+		return resp.Error
+	}
+
+	var resps []response
+	if err := json.Unmarshal(body, &resps); err != nil {
+		return fmt.Errorf("zabbix: invalid JSON-RPC batch response: %v", err)
+	}
+
+	for i := range resps {
+		call, ok := pending[resps[i].ID]
+		if !ok {
+			continue
+		}
+
+		delete(pending, resps[i].ID)
+		call.Err = resps[i].into(call.Method, call.Result)
+	}
+
+	for id, call := range pending {
+		call.Err = fmt.Errorf("zabbix: no response to %s in the JSON-RPC batch response (id %d)", call.Method, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package zabbix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// itemMethod answers item.get with an item whose key is the itemid filtered on, and fails for itemid 0.
+func itemMethod(params json.RawMessage) (interface{}, *Error) {
+	var p ItemGetParams
+	if err := json.Unmarshal(params, &p); err != nil || len(p.ItemIDs) != 1 {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: "one itemid expected"}
+	}
+
+	if p.ItemIDs[0] == "0" {
+		return nil, &Error{Code: ErrCodeApplication, Message: "Application error.", Data: "No permissions to referred object or it does not exist!"}
+	}
+
+	return []Item{{ItemID: p.ItemIDs[0], Key: "key." + p.ItemIDs[0]}}, nil
+}
+
+func TestBatchSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchSize   int
+		maxSize     int
+		ids         []string
+		wantBatches []int
+		wantFailed  []string
+	}{
+		{name: "single request", ids: []string{"1", "2", "3"}, wantBatches: []int{3}},
+		{name: "split by the configured size", batchSize: 2, ids: []string{"1", "2", "3", "4", "5"}, wantBatches: []int{2, 2, 1}},
+		{name: "split by SetMaxSize", batchSize: 2, maxSize: 3, ids: []string{"1", "2", "3", "4"}, wantBatches: []int{3, 1}},
+		{name: "exact multiple", batchSize: 2, ids: []string{"1", "2", "3", "4"}, wantBatches: []int{2, 2}},
+		{name: "partial failure", batchSize: 2, ids: []string{"1", "0", "3"}, wantBatches: []int{2, 1}, wantFailed: []string{"0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.token = "api-token"
+				s.reverse = true
+				s.methods["item.get"] = itemMethod
+			})
+			client := server.client(t, "", "", "api-token")
+			if tt.batchSize > 0 {
+				client.batchSize = tt.batchSize
+			}
+
+			batch := client.Batch()
+			if tt.maxSize > 0 {
+				batch.SetMaxSize(tt.maxSize)
+			}
+
+			results := make([][]Item, len(tt.ids))
+			calls := make([]*BatchCall, len(tt.ids))
+
+			for i, id := range tt.ids {
+				calls[i] = batch.Add("item.get", ItemGetParams{ItemIDs: []string{id}}, &results[i])
+			}
+
+			if batch.Len() != len(tt.ids) {
+				t.Errorf("Len() = %d before Send, want %d", batch.Len(), len(tt.ids))
+			}
+
+			if err := batch.Send(context.Background()); err != nil {
+				t.Fatalf("Send() failed: %v", err)
+			}
+
+			if batch.Len() != 0 || len(batch.Calls()) != len(tt.ids) {
+				t.Errorf("Len() = %d, %d calls after Send, want 0, %d", batch.Len(), len(batch.Calls()), len(tt.ids))
+			}
+
+			server.mu.Lock()
+			batches := server.batches
+			server.mu.Unlock()
+
+			if !reflect.DeepEqual(batches, tt.wantBatches) {
+				t.Errorf("batch sizes = %v, want %v", batches, tt.wantBatches)
+			}
+
+			var failed []string
+
+			for i, id := range tt.ids {
+				if calls[i].Err != nil {
+					failed = append(failed, id)
+
+					if !IsErrorCode(calls[i].Err, ErrCodeApplication) || calls[i].Err.(*Error).Method != "item.get" {
+						t.Errorf("call %s error = %v, want an item.get application error", id, calls[i].Err)
+					}
+
+					continue
+				}
+
+				// The responses come in the reverse order, they are correlated by id
+				if len(results[i]) != 1 || results[i][0].ItemID != id {
+					t.Errorf("call %s result = %+v", id, results[i])
+				}
+			}
+
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("failed calls = %v, want %v", failed, tt.wantFailed)
+			}
+
+			if err := batch.Err(); (err != nil) != (len(tt.wantFailed) > 0) {
+				t.Errorf("Err() = %v", err)
+			}
+		})
+	}
+}
+
+func TestBatchResponseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		wantErr  string
+		wantCode int
+
+		// wantCallErr is the error of every call, when the request did not fail as a whole
+		wantCallErr string
+	}{
+		{
+			name:     "rejected batch",
+			body:     `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request.","data":"Invalid JSON-RPC request."},"id":null}`,
+			wantErr:  "zabbix: batch failed: Invalid request: Invalid JSON-RPC request. (code -32600)",
+			wantCode: ErrCodeInvalidRequest,
+		},
+		{
+			name:    "object without error",
+			body:    `{"jsonrpc":"2.0","result":[],"id":1}`,
+			wantErr: "expected an array",
+		},
+		{
+			name:    "invalid json",
+			body:    `[{"jsonrpc":"2.0",`,
+			wantErr: "zabbix: invalid JSON-RPC batch response",
+		},
+		{
+			name:    "http error",
+			status:  http.StatusBadGateway,
+			wantErr: "zabbix: batch failed: HTTP 502: Bad Gateway",
+		},
+		{
+			name:        "missing responses",
+			body:        `[{"jsonrpc":"2.0","result":[],"id":4242}]`,
+			wantCallErr: "zabbix: no response to item.get in the JSON-RPC batch response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.raw = func(w http.ResponseWriter, r *http.Request) {
+					if tt.status > 0 {
+						w.WriteHeader(tt.status)
+						return
+					}
+
+					_, _ = w.Write([]byte(tt.body))
+				}
+			})
+			client := server.client(t, "", "", "api-token")
+			client.batchSize = 1
+
+			batch := client.Batch()
+			first := batch.Add("item.get", nil, nil)
+			second := batch.Add("item.get", nil, nil)
+
+			err := batch.Send(context.Background())
+
+			if len(tt.wantCallErr) > 0 {
+				if err != nil {
+					t.Fatalf("Send() = %v, want nil", err)
+				}
+
+				for _, call := range []*BatchCall{first, second} {
+					if call.Err == nil || !strings.Contains(call.Err.Error(), tt.wantCallErr) {
+						t.Errorf("call error = %v, want %q", call.Err, tt.wantCallErr)
+					}
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Send() = %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			if tt.wantCode != 0 && !IsErrorCode(err, tt.wantCode) {
+				t.Errorf("Send() = %v, want code %d", err, tt.wantCode)
+			}
+
+			// The calls of the failed request and of the following ones carry the error
+			if first.Err != err || second.Err != err || batch.Err() != err {
+				t.Errorf("call errors = %v, %v, want %v", first.Err, second.Err, err)
+			}
+		})
+	}
+}
+
+func TestBatchSessionExpiry(t *testing.T) {
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.reverse = true
+		s.methods["item.get"] = itemMethod
+	})
+	client := server.client(t, "Admin", "zabbix", "")
+	client.batchSize = 2
+
+	ctx := context.Background()
+
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	server.expire()
+
+	var version string
+
+	batch := client.Batch()
+	versionCall := batch.Add("apiinfo.version", nil, &version)
+	items := batch.Add("item.get", ItemGetParams{ItemIDs: []string{"1"}}, nil)
+	failed := batch.Add("item.get", ItemGetParams{ItemIDs: []string{"0"}}, nil)
+
+	if err := batch.Send(ctx); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	if versionCall.Err != nil || items.Err != nil || version != "6.0.0" {
+		t.Errorf("call errors = %v, %v, version %q, want nil, nil, 6.0.0", versionCall.Err, items.Err, version)
+	}
+
+	if !IsErrorCode(failed.Err, ErrCodeApplication) {
+		t.Errorf("call error = %v, want an application error", failed.Err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	// Only the calls failing because of the session are sent again, after a single login
+	want := []string{"user.login", "apiinfo.version", "item.get", "item.get", "user.login", "item.get", "item.get"}
+
+	var got []string
+	for _, req := range server.requests {
+		got = append(got, req.Method)
+
+		if req.Method == "apiinfo.version" && len(req.Auth) > 0 {
+			t.Errorf("apiinfo.version sent with auth %q", req.Auth)
+		}
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+
+	if !reflect.DeepEqual(server.batches, []int{2, 1, 2}) {
+		t.Errorf("batch sizes = %v, want [2 1 2]", server.batches)
+	}
+
+	if auth := server.requests[len(server.requests)-1].Auth; auth != "session-2" {
+		t.Errorf("retried calls auth = %q, want session-2", auth)
+	}
+}
+
+func TestBatchSendTwice(t *testing.T) {
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.token = "api-token"
+		s.methods["item.get"] = itemMethod
+	})
+	client := server.client(t, "", "", "api-token")
+
+	ctx := context.Background()
+
+	batch := client.Batch()
+	batch.Add("item.get", ItemGetParams{ItemIDs: []string{"0"}}, nil)
+
+	if err := batch.Send(ctx); err != nil || batch.Err() == nil {
+		t.Fatalf("Send() = %v, Err() = %v, want nil and an error", err, batch.Err())
+	}
+
+	// Only the calls added since are sent
+	batch.Add("item.get", ItemGetParams{ItemIDs: []string{"1"}}, nil)
+
+	if err := batch.Send(ctx); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	if err := batch.Send(ctx); err != nil {
+		t.Fatalf("Send() without calls failed: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if !reflect.DeepEqual(server.batches, []int{1, 1}) {
+		t.Errorf("batch sizes = %v, want [1 1]", server.batches)
+	}
+}
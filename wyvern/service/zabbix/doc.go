@@ -0,0 +1,4 @@
+package zabbix
+
+// package zabbix
+// - a client of the Zabbix JSON-RPC 2.0 API, configured by the Zabbix settings of rest.Config
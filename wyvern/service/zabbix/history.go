@@ -0,0 +1,123 @@
+package zabbix
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// HistoryGetter
+// - method to return a HistoryInterface
+// - a Zabbix client should implement this interface
+type HistoryGetter interface {
+	History() HistoryInterface
+}
+
+// HistoryInterface calls the history methods of the Zabbix API.
+type HistoryInterface interface {
+	Get(ctx context.Context, params HistoryGetParams) ([]History, error)
+	GetItemHistory(ctx context.Context, itemID string, from, to time.Time, limit int) ([]History, error)
+}
+
+// History is a value collected by an item.
+type History struct {
+	ItemID string `json:"itemid"`
+	Clock  string `json:"clock"`
+	NS     string `json:"ns,omitempty"`
+	Value  string `json:"value"`
+}
+
+// Time returns the time the value was collected at, from its clock and nanoseconds.
+func (h *History) Time() (time.Time, error) {
+	sec, err := strconv.ParseInt(h.Clock, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("zabbix: invalid clock %q of item %s", h.Clock, h.ItemID)
+	}
+
+	var nsec int64
+	if len(h.NS) > 0 {
+		if nsec, err = strconv.ParseInt(h.NS, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("zabbix: invalid ns %q of item %s", h.NS, h.ItemID)
+		}
+	}
+
+	return time.Unix(sec, nsec), nil
+}
+
+// HistoryGetParams are the parameters of history.get.
+type HistoryGetParams struct {
+	GetParams
+
+	// History is the value type of the items, which selects the history table: ValueTypeFloat,
+	// ValueTypeUnsigned, etc.
+	History int `json:"history"`
+
+	ItemIDs  []string `json:"itemids,omitempty"`
+	HostIDs  []string `json:"hostids,omitempty"`
+	TimeFrom int64    `json:"time_from,omitempty"`
+	TimeTill int64    `json:"time_till,omitempty"`
+}
+
+type history struct {
+	client *ZabbixClient
+}
+
+func newHistory(c *ZabbixClient) *history {
+	return &history{
+		client: c,
+	}
+}
+
+func (c *history) Get(ctx context.Context, params HistoryGetParams) (result []History, err error) {
+	params.setDefaults()
+
+	err = c.client.Call(ctx, "history.get", params, &result)
+
+	return
+}
+
+// GetItemHistory
+// - return the values of an item collected between from and to, both included, oldest first
+// - from and to are truncated to the second, a zero one leaves the range open on that side, a
+// limit <= 0 returns every value
+// - the value type of the item, which selects the history table, is looked up with item.get
+func (c *history) GetItemHistory(ctx context.Context, itemID string, from, to time.Time,
+	limit int) ([]History, error) {
+	items, err := newItems(c.client).Get(ctx, ItemGetParams{
+		GetParams: GetParams{Output: []string{"itemid", "value_type"}},
+		ItemIDs:   []string{itemID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("zabbix: item %s not found", itemID)
+	}
+
+	valueType, err := strconv.Atoi(items[0].ValueType)
+	if err != nil {
+		return nil, fmt.Errorf("zabbix: invalid value type %q of item %s", items[0].ValueType, itemID)
+	}
+
+	params := HistoryGetParams{
+		GetParams: GetParams{SortField: []string{"clock"}, SortOrder: SortOrderAsc},
+		History:   valueType,
+		ItemIDs:   []string{itemID},
+	}
+
+	if !from.IsZero() {
+		params.TimeFrom = from.Unix()
+	}
+
+	if !to.IsZero() {
+		params.TimeTill = to.Unix()
+	}
+
+	if limit > 0 {
+		params.Limit = limit
+	}
+
+	return c.Get(ctx, params)
+}
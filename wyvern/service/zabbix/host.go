@@ -0,0 +1,95 @@
+package zabbix
+
+import "context"
+
+// HostsGetter
+// - method to return a HostInterface
+// - a Zabbix client should implement this interface
+type HostsGetter interface {
+	Hosts() HostInterface
+}
+
+// HostInterface calls the host methods of the Zabbix API.
+type HostInterface interface {
+	Get(ctx context.Context, params HostGetParams) ([]Host, error)
+	List(ctx context.Context, opts ListOptions) ([]Host, error)
+}
+
+// Host is a Zabbix host.
+type Host struct {
+	HostID string `json:"hostid"`
+	Host   string `json:"host"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status,omitempty"`
+
+	// Groups is returned by selectGroups up to Zabbix 6.0, HostGroups by selectHostGroups since 6.2
+	Groups     []HostGroup `json:"groups,omitempty"`
+	HostGroups []HostGroup `json:"hostgroups,omitempty"`
+	Tags       []Tag       `json:"tags,omitempty"`
+}
+
+// HostGroup is a Zabbix host group.
+type HostGroup struct {
+	GroupID string `json:"groupid"`
+	Name    string `json:"name"`
+}
+
+// HostGetParams are the parameters of host.get.
+type HostGetParams struct {
+	GetParams
+
+	HostIDs  []string    `json:"hostids,omitempty"`
+	GroupIDs []string    `json:"groupids,omitempty"`
+	Tags     []TagFilter `json:"tags,omitempty"`
+
+	SelectGroups     interface{} `json:"selectGroups,omitempty"`
+	SelectHostGroups interface{} `json:"selectHostGroups,omitempty"`
+	SelectTags       interface{} `json:"selectTags,omitempty"`
+}
+
+type hosts struct {
+	client *ZabbixClient
+}
+
+func newHosts(c *ZabbixClient) *hosts {
+	return &hosts{
+		client: c,
+	}
+}
+
+func (c *hosts) Get(ctx context.Context, params HostGetParams) (result []Host, err error) {
+	params.setDefaults()
+
+	err = c.client.Call(ctx, "host.get", params, &result)
+
+	return
+}
+
+// List
+// - list the hosts matching the host group and tag of the options, ordered by id
+// - the host groups of the hosts are selected too
+func (c *hosts) List(ctx context.Context, opts ListOptions) ([]Host, error) {
+	params := HostGetParams{
+		GetParams:  opts.getParams("hostid"),
+		Tags:       opts.tags(),
+		SelectTags: OutputExtend,
+	}
+
+	if len(opts.HostGroup) > 0 {
+		groupIDs, err := c.client.groupIDs(ctx, opts.HostGroup)
+		if err != nil || len(groupIDs) == 0 {
+			return nil, err
+		}
+
+		params.GroupIDs = groupIDs
+	}
+
+	result, err := c.Get(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := opts.page(len(result))
+
+	return result[start:end], nil
+}
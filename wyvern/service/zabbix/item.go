@@ -0,0 +1,130 @@
+package zabbix
+
+import "context"
+
+// ItemsGetter
+// - method to return an ItemInterface
+// - a Zabbix client should implement this interface
+type ItemsGetter interface {
+	Items() ItemInterface
+}
+
+// ItemInterface calls the item methods of the Zabbix API.
+type ItemInterface interface {
+	Get(ctx context.Context, params ItemGetParams) ([]Item, error)
+	List(ctx context.Context, opts ListOptions) ([]Item, error)
+	GetByName(ctx context.Context, names []string) ([]Item, error)
+}
+
+// Value types of the items, also used to select the history table of history.get.
+const (
+	ValueTypeFloat     = 0
+	ValueTypeCharacter = 1
+	ValueTypeLog       = 2
+	ValueTypeUnsigned  = 3
+	ValueTypeText      = 4
+)
+
+// Item is a Zabbix item.
+type Item struct {
+	ItemID    string `json:"itemid"`
+	HostID    string `json:"hostid,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Key       string `json:"key_,omitempty"`
+	ValueType string `json:"value_type,omitempty"`
+	Units     string `json:"units,omitempty"`
+	Delay     string `json:"delay,omitempty"`
+	Status    string `json:"status,omitempty"`
+	State     string `json:"state,omitempty"`
+	LastClock string `json:"lastclock,omitempty"`
+	LastNs    string `json:"lastns,omitempty"`
+	LastValue string `json:"lastvalue,omitempty"`
+	PrevValue string `json:"prevvalue,omitempty"`
+	Tags      []Tag  `json:"tags,omitempty"`
+	Hosts     []Host `json:"hosts,omitempty"`
+}
+
+// ItemGetParams are the parameters of item.get.
+type ItemGetParams struct {
+	GetParams
+
+	ItemIDs  []string    `json:"itemids,omitempty"`
+	HostIDs  []string    `json:"hostids,omitempty"`
+	GroupIDs []string    `json:"groupids,omitempty"`
+	Host     string      `json:"host,omitempty"`
+	Tags     []TagFilter `json:"tags,omitempty"`
+
+	SelectHosts interface{} `json:"selectHosts,omitempty"`
+	SelectTags  interface{} `json:"selectTags,omitempty"`
+}
+
+type items struct {
+	client *ZabbixClient
+}
+
+func newItems(c *ZabbixClient) *items {
+	return &items{
+		client: c,
+	}
+}
+
+func (c *items) Get(ctx context.Context, params ItemGetParams) (result []Item, err error) {
+	params.setDefaults()
+
+	err = c.client.Call(ctx, "item.get", params, &result)
+
+	return
+}
+
+// List
+// - list the items matching the host, host group, tag and key pattern of the options, ordered by id
+// - the technical name of the host of the items is selected too
+func (c *items) List(ctx context.Context, opts ListOptions) ([]Item, error) {
+	params := ItemGetParams{
+		GetParams:   opts.getParams("itemid"),
+		Host:        opts.HostName,
+		Tags:        opts.tags(),
+		SelectHosts: []string{"hostid", "host"},
+	}
+
+	if len(opts.KeyPattern) > 0 {
+		params.Search = map[string]interface{}{"key_": opts.KeyPattern}
+		params.SearchWildcardsEnabled = true
+	}
+
+	if len(opts.HostGroup) > 0 {
+		groupIDs, err := c.client.groupIDs(ctx, opts.HostGroup)
+		if err != nil || len(groupIDs) == 0 {
+			return nil, err
+		}
+
+		params.GroupIDs = groupIDs
+	}
+
+	result, err := c.Get(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := opts.page(len(result))
+
+	return result[start:end], nil
+}
+
+// GetByName
+// - look up many items by name in a single call, ordered by id
+// - the names which are not found are missing from the result, instead of failing the call
+func (c *items) GetByName(ctx context.Context, names []string) ([]Item, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	return c.Get(ctx, ItemGetParams{
+		GetParams: GetParams{
+			Filter:    map[string]interface{}{"name": names},
+			SortField: []string{"itemid"},
+			SortOrder: SortOrderAsc,
+		},
+		SelectHosts: []string{"hostid", "host"},
+	})
+}
@@ -0,0 +1,106 @@
+package zabbix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes returned by the Zabbix API.
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+	ErrCodeApplication    = -32500
+)
+
+// ErrNotConfigured is returned by the calls of a client built from a config without ZabbixApiUrl.
+var ErrNotConfigured = errors.New("zabbix: the Zabbix API url is not configured")
+
+// Error is a JSON-RPC error returned by the Zabbix API.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+
+	// Method is the method of the failed call, it is not part of the response.
+	Method string `json:"-"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("zabbix: %s failed: %s", e.Method, strings.TrimSuffix(e.Message, "."))
+	if len(e.Data) > 0 {
+		msg += ": " + e.Data
+	}
+
+	return fmt.Sprintf("%s (code %d)", msg, e.Code)
+}
+
+// IsSessionExpired returns true if err tells the session used by the call is no longer valid.
+func IsSessionExpired(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	data := strings.ToLower(e.Data)
+
+	return strings.Contains(data, "re-login") || strings.Contains(data, "not authorised") ||
+		strings.Contains(data, "not authorized") || strings.Contains(data, "session terminated")
+}
+
+// IsErrorCode returns true if err is an *Error with the given code.
+func IsErrorCode(err error, code int) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Code == code
+}
+
+// request is a JSON-RPC 2.0 request, with the auth member of the Zabbix API.
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+	Auth    string      `json:"auth,omitempty"`
+	ID      uint64      `json:"id"`
+}
+
+// response is a JSON-RPC 2.0 response.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      uint64          `json:"id"`
+}
+
+// into returns the error of the response, or decodes its result into result.
+func (r *response) into(method string, result interface{}) error {
+	if r.Error != nil {
+		r.Error.Method = method
+		return r.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("zabbix: unable to decode the result of %s: %v", method, err)
+	}
+
+	return nil
+}
+
+// requiresAuth returns false for the methods which must be called without the auth member.
+func requiresAuth(method string) bool {
+	switch method {
+	case "apiinfo.version", "user.login", "user.checkAuthentication":
+		return false
+	}
+
+	return true
+}
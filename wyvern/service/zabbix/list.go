@@ -0,0 +1,88 @@
+package zabbix
+
+import (
+	"context"
+	"strings"
+)
+
+// ListOptions
+// - the filters of HostInterface.List and ItemInterface.List
+// - Offset and Limit page through the objects ordered by id, like the List calls of the ELMT
+// clients: the Zabbix API has no offset, the first Offset+Limit objects are fetched and the first
+// Offset of them dropped
+type ListOptions struct {
+	// HostName only keeps the items of this host, given by its technical name. Hosts ignore it.
+	HostName string
+
+	// HostGroup only keeps the hosts, or the items of the hosts, of this host group, given by name.
+	HostGroup string
+
+	// Tag only keeps the objects with this tag, given as name or name=value.
+	Tag string
+
+	// KeyPattern only keeps the items whose key matches this pattern, where * matches any string.
+	// Hosts ignore it.
+	// 示例: vfs.dev.*
+	KeyPattern string
+
+	Offset int
+	Limit  int
+}
+
+// getParams returns the parameters shared by host.get and item.get for the options.
+func (o ListOptions) getParams(sortField string) GetParams {
+	params := GetParams{SortField: []string{sortField}, SortOrder: SortOrderAsc}
+	if o.Limit > 0 {
+		params.Limit = o.Offset + o.Limit
+	}
+
+	return params
+}
+
+// tags returns the tag filter of the options, if any.
+func (o ListOptions) tags() []TagFilter {
+	if len(o.Tag) == 0 {
+		return nil
+	}
+
+	if i := strings.Index(o.Tag, "="); i >= 0 {
+		return []TagFilter{{Tag: o.Tag[:i], Value: o.Tag[i+1:], Operator: TagOperatorEquals}}
+	}
+
+	return []TagFilter{{Tag: o.Tag, Operator: TagOperatorExists}}
+}
+
+// page drops the first Offset of n objects, and returns the bounds of the page.
+func (o ListOptions) page(n int) (int, int) {
+	start := o.Offset
+	if start > n {
+		start = n
+	}
+
+	end := n
+	if o.Limit > 0 && start+o.Limit < end {
+		end = start + o.Limit
+	}
+
+	return start, end
+}
+
+// groupIDs returns the ids of the host groups named name, which may be none.
+func (c *ZabbixClient) groupIDs(ctx context.Context, name string) ([]string, error) {
+	var groups []HostGroup
+
+	err := c.Call(ctx, "hostgroup.get", GetParams{
+		Output: []string{"groupid", "name"},
+		Filter: map[string]interface{}{"name": name},
+	}, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(groups))
+	for i, group := range groups {
+		ids[i] = group.GroupID
+	}
+
+	return ids, nil
+}
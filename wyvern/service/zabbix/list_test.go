@@ -0,0 +1,331 @@
+package zabbix
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordParams returns a method answering result, which stores its params into params.
+func recordParams(params *map[string]interface{}, result interface{}) func(json.RawMessage) (interface{}, *Error) {
+	return func(raw json.RawMessage) (interface{}, *Error) {
+		*params = nil
+		if err := json.Unmarshal(raw, params); err != nil {
+			return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: err.Error()}
+		}
+
+		return result, nil
+	}
+}
+
+func jsonValue(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestHostsList(t *testing.T) {
+	hosts := []Host{{HostID: "1", Host: "a"}, {HostID: "2", Host: "b"}, {HostID: "3", Host: "c"}}
+
+	tests := []struct {
+		name       string
+		opts       ListOptions
+		wantParams map[string]interface{}
+		wantHosts  []string
+		noCall     bool
+	}{
+		{
+			name: "all",
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"hostid"}, "sortorder": "ASC", "selectTags": "extend",
+			},
+			wantHosts: []string{"1", "2", "3"},
+		},
+		{
+			name: "group, tag and page",
+			opts: ListOptions{HostGroup: "Linux servers", Tag: "env=prod", Offset: 1, Limit: 1},
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"hostid"}, "sortorder": "ASC", "selectTags": "extend",
+				"limit": 2, "groupids": []string{"2"},
+				"tags": []TagFilter{{Tag: "env", Value: "prod", Operator: TagOperatorEquals}},
+			},
+			wantHosts: []string{"2"},
+		},
+		{
+			name: "tag name",
+			opts: ListOptions{Tag: "env", Offset: 5},
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"hostid"}, "sortorder": "ASC", "selectTags": "extend",
+				"tags": []TagFilter{{Tag: "env", Operator: TagOperatorExists}},
+			},
+			wantHosts: []string{},
+		},
+		{
+			name:      "unknown group",
+			opts:      ListOptions{HostGroup: "unknown"},
+			wantHosts: []string{},
+			noCall:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params, groupParams map[string]interface{}
+
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.token = "api-token"
+				s.methods["host.get"] = recordParams(&params, hosts)
+				s.methods["hostgroup.get"] = func(raw json.RawMessage) (interface{}, *Error) {
+					if err := json.Unmarshal(raw, &groupParams); err != nil {
+						return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params."}
+					}
+
+					if groupParams["filter"].(map[string]interface{})["name"] == "Linux servers" {
+						return []HostGroup{{GroupID: "2", Name: "Linux servers"}}, nil
+					}
+
+					return []HostGroup{}, nil
+				}
+			})
+			client := server.client(t, "", "", "api-token")
+
+			result, err := client.Hosts().List(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Hosts().List() failed: %v", err)
+			}
+
+			ids := []string{}
+			for _, host := range result {
+				ids = append(ids, host.HostID)
+			}
+
+			if !reflect.DeepEqual(ids, tt.wantHosts) {
+				t.Errorf("hosts = %v, want %v", ids, tt.wantHosts)
+			}
+
+			if tt.noCall {
+				if params != nil {
+					t.Errorf("host.get called with %v", params)
+				}
+
+				return
+			}
+
+			if want := jsonValue(t, tt.wantParams); !reflect.DeepEqual(jsonValue(t, params), want) {
+				t.Errorf("host.get params = %v, want %v", params, want)
+			}
+		})
+	}
+}
+
+func TestItemsList(t *testing.T) {
+	items := []Item{{ItemID: "10", Key: "vfs.dev.read"}, {ItemID: "11", Key: "vfs.dev.write"}}
+
+	tests := []struct {
+		name       string
+		opts       ListOptions
+		wantParams map[string]interface{}
+	}{
+		{
+			name: "all",
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"itemid"}, "sortorder": "ASC",
+				"selectHosts": []string{"hostid", "host"},
+			},
+		},
+		{
+			name: "host, key pattern and limit",
+			opts: ListOptions{HostName: "web-1", KeyPattern: "vfs.dev.*", Limit: 10},
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"itemid"}, "sortorder": "ASC", "limit": 10,
+				"selectHosts": []string{"hostid", "host"}, "host": "web-1",
+				"search": map[string]string{"key_": "vfs.dev.*"}, "searchWildcardsEnabled": true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params map[string]interface{}
+
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.token = "api-token"
+				s.methods["item.get"] = recordParams(&params, items)
+			})
+			client := server.client(t, "", "", "api-token")
+
+			result, err := client.Items().List(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Items().List() failed: %v", err)
+			}
+
+			if len(result) != 2 || result[1].Key != "vfs.dev.write" {
+				t.Errorf("items = %+v", result)
+			}
+
+			if want := jsonValue(t, tt.wantParams); !reflect.DeepEqual(jsonValue(t, params), want) {
+				t.Errorf("item.get params = %v, want %v", params, want)
+			}
+		})
+	}
+}
+
+func TestItemsGetByName(t *testing.T) {
+	var params map[string]interface{}
+
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.token = "api-token"
+		s.methods["item.get"] = recordParams(&params, []Item{{ItemID: "10", Name: "CPU utilization"}})
+	})
+	client := server.client(t, "", "", "api-token")
+
+	ctx := context.Background()
+
+	items, err := client.Items().GetByName(ctx, []string{"CPU utilization", "Missing"})
+	if err != nil {
+		t.Fatalf("Items().GetByName() failed: %v", err)
+	}
+
+	if len(items) != 1 || items[0].ItemID != "10" {
+		t.Errorf("items = %+v", items)
+	}
+
+	want := jsonValue(t, map[string]interface{}{
+		"output": "extend", "sortfield": []string{"itemid"}, "sortorder": "ASC",
+		"filter":      map[string]interface{}{"name": []string{"CPU utilization", "Missing"}},
+		"selectHosts": []string{"hostid", "host"},
+	})
+	if !reflect.DeepEqual(jsonValue(t, params), want) {
+		t.Errorf("item.get params = %v, want %v", params, want)
+	}
+
+	if items, err := client.Items().GetByName(ctx, nil); err != nil || items != nil {
+		t.Errorf("Items().GetByName(nil) = %v, %v, want no call", items, err)
+	}
+
+	if calls := server.calls(); len(calls) != 1 {
+		t.Errorf("calls = %v, want a single item.get", calls)
+	}
+}
+
+func TestGetItemHistory(t *testing.T) {
+	from := time.Unix(1700000000, 500)
+	to := time.Unix(1700003600, 0)
+
+	tests := []struct {
+		name       string
+		valueType  string
+		from, to   time.Time
+		limit      int
+		wantParams map[string]interface{}
+		wantErr    string
+	}{
+		{
+			name:      "range and limit",
+			valueType: "3",
+			from:      from,
+			to:        to,
+			limit:     100,
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"clock"}, "sortorder": "ASC", "limit": 100,
+				"history": 3, "itemids": []string{"10"}, "time_from": 1700000000, "time_till": 1700003600,
+			},
+		},
+		{
+			name:      "open range",
+			valueType: "0",
+			wantParams: map[string]interface{}{
+				"output": "extend", "sortfield": []string{"clock"}, "sortorder": "ASC",
+				"history": 0, "itemids": []string{"10"},
+			},
+		},
+		{
+			name:    "unknown item",
+			wantErr: "zabbix: item 10 not found",
+		},
+		{
+			name:      "invalid value type",
+			valueType: "float",
+			wantErr:   `zabbix: invalid value type "float" of item 10`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params map[string]interface{}
+
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.token = "api-token"
+				s.methods["item.get"] = func(json.RawMessage) (interface{}, *Error) {
+					if len(tt.valueType) == 0 {
+						return []Item{}, nil
+					}
+
+					return []Item{{ItemID: "10", ValueType: tt.valueType}}, nil
+				}
+				s.methods["history.get"] = recordParams(&params, []History{
+					{ItemID: "10", Clock: "1700000001", NS: "250", Value: "1"},
+					{ItemID: "10", Clock: "1700000002", Value: "2"},
+				})
+			})
+			client := server.client(t, "", "", "api-token")
+
+			history, err := client.History().GetItemHistory(context.Background(), "10", tt.from, tt.to, tt.limit)
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GetItemHistory() = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GetItemHistory() failed: %v", err)
+			}
+
+			if len(history) != 2 {
+				t.Fatalf("history = %+v", history)
+			}
+
+			if clock, err := history[0].Time(); err != nil || !clock.Equal(time.Unix(1700000001, 250)) {
+				t.Errorf("Time() = %v, %v", clock, err)
+			}
+
+			if want := jsonValue(t, tt.wantParams); !reflect.DeepEqual(jsonValue(t, params), want) {
+				t.Errorf("history.get params = %v, want %v", params, want)
+			}
+		})
+	}
+}
+
+func TestHistoryTime(t *testing.T) {
+	tests := []struct {
+		history History
+		want    time.Time
+		wantErr bool
+	}{
+		{history: History{Clock: "1700000000"}, want: time.Unix(1700000000, 0)},
+		{history: History{Clock: "1700000000", NS: "999999999"}, want: time.Unix(1700000000, 999999999)},
+		{history: History{Clock: "soon"}, wantErr: true},
+		{history: History{Clock: "1700000000", NS: "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.history.Time()
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("%+v.Time() = %v, %v, want %v, error %v", tt.history, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package zabbix
+
+import "context"
+
+// ProblemsGetter
+// - method to return a ProblemInterface
+// - a Zabbix client should implement this interface
+type ProblemsGetter interface {
+	Problems() ProblemInterface
+}
+
+// ProblemInterface calls the problem methods of the Zabbix API.
+type ProblemInterface interface {
+	Get(ctx context.Context, params ProblemGetParams) ([]Problem, error)
+}
+
+// Problem is an open, or recently resolved, Zabbix problem.
+type Problem struct {
+	EventID      string `json:"eventid"`
+	Source       string `json:"source,omitempty"`
+	Object       string `json:"object,omitempty"`
+	ObjectID     string `json:"objectid,omitempty"`
+	Clock        string `json:"clock,omitempty"`
+	NS           string `json:"ns,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Severity     string `json:"severity,omitempty"`
+	Acknowledged string `json:"acknowledged,omitempty"`
+	Suppressed   string `json:"suppressed,omitempty"`
+	RClock       string `json:"r_clock,omitempty"`
+	REventID     string `json:"r_eventid,omitempty"`
+	Tags         []Tag  `json:"tags,omitempty"`
+}
+
+// ProblemGetParams are the parameters of problem.get.
+type ProblemGetParams struct {
+	GetParams
+
+	EventIDs   []string    `json:"eventids,omitempty"`
+	HostIDs    []string    `json:"hostids,omitempty"`
+	GroupIDs   []string    `json:"groupids,omitempty"`
+	ObjectIDs  []string    `json:"objectids,omitempty"`
+	Severities []int       `json:"severities,omitempty"`
+	Tags       []TagFilter `json:"tags,omitempty"`
+
+	// Recent also returns the recently resolved problems
+	Recent       bool  `json:"recent,omitempty"`
+	Acknowledged *bool `json:"acknowledged,omitempty"`
+	TimeFrom     int64 `json:"time_from,omitempty"`
+	TimeTill     int64 `json:"time_till,omitempty"`
+
+	SelectTags interface{} `json:"selectTags,omitempty"`
+}
+
+type problems struct {
+	client *ZabbixClient
+}
+
+func newProblems(c *ZabbixClient) *problems {
+	return &problems{
+		client: c,
+	}
+}
+
+func (c *problems) Get(ctx context.Context, params ProblemGetParams) (result []Problem, err error) {
+	params.setDefaults()
+
+	err = c.client.Call(ctx, "problem.get", params, &result)
+
+	return
+}
@@ -0,0 +1,66 @@
+package zabbix
+
+import "context"
+
+// TriggersGetter
+// - method to return a TriggerInterface
+// - a Zabbix client should implement this interface
+type TriggersGetter interface {
+	Triggers() TriggerInterface
+}
+
+// TriggerInterface calls the trigger methods of the Zabbix API.
+type TriggerInterface interface {
+	Get(ctx context.Context, params TriggerGetParams) ([]Trigger, error)
+}
+
+// Trigger is a Zabbix trigger.
+type Trigger struct {
+	TriggerID   string `json:"triggerid"`
+	Description string `json:"description,omitempty"`
+	Expression  string `json:"expression,omitempty"`
+	Priority    string `json:"priority,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Status      string `json:"status,omitempty"`
+	LastChange  string `json:"lastchange,omitempty"`
+	Tags        []Tag  `json:"tags,omitempty"`
+	Hosts       []Host `json:"hosts,omitempty"`
+}
+
+// TriggerGetParams are the parameters of trigger.get.
+type TriggerGetParams struct {
+	GetParams
+
+	TriggerIDs []string    `json:"triggerids,omitempty"`
+	HostIDs    []string    `json:"hostids,omitempty"`
+	GroupIDs   []string    `json:"groupids,omitempty"`
+	ItemIDs    []string    `json:"itemids,omitempty"`
+	Tags       []TagFilter `json:"tags,omitempty"`
+
+	// OnlyTrue returns only the triggers in the problem state or recently recovered
+	OnlyTrue          bool `json:"only_true,omitempty"`
+	Monitored         bool `json:"monitored,omitempty"`
+	MinSeverity       int  `json:"min_severity,omitempty"`
+	ExpandDescription bool `json:"expandDescription,omitempty"`
+
+	SelectHosts interface{} `json:"selectHosts,omitempty"`
+	SelectTags  interface{} `json:"selectTags,omitempty"`
+}
+
+type triggers struct {
+	client *ZabbixClient
+}
+
+func newTriggers(c *ZabbixClient) *triggers {
+	return &triggers{
+		client: c,
+	}
+}
+
+func (c *triggers) Get(ctx context.Context, params TriggerGetParams) (result []Trigger, err error) {
+	params.setDefaults()
+
+	err = c.client.Call(ctx, "trigger.get", params, &result)
+
+	return
+}
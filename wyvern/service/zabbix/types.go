@@ -0,0 +1,57 @@
+package zabbix
+
+// OutputExtend makes the get methods return every property of the objects.
+const OutputExtend = "extend"
+
+// Sort orders of the get methods.
+const (
+	SortOrderAsc  = "ASC"
+	SortOrderDesc = "DESC"
+)
+
+// GetParams are the parameters shared by the get methods of the Zabbix API.
+type GetParams struct {
+	// Output lists the properties to return, OutputExtend when nil.
+	Output interface{} `json:"output,omitempty"`
+
+	// Filter returns only the objects whose properties exactly match the given values.
+	Filter map[string]interface{} `json:"filter,omitempty"`
+
+	// Search returns only the objects whose properties contain the given values.
+	Search                 map[string]interface{} `json:"search,omitempty"`
+	SearchByAny            bool                   `json:"searchByAny,omitempty"`
+	SearchWildcardsEnabled bool                   `json:"searchWildcardsEnabled,omitempty"`
+
+	SortField []string `json:"sortfield,omitempty"`
+	SortOrder string   `json:"sortorder,omitempty"`
+	Limit     int      `json:"limit,omitempty"`
+}
+
+func (p *GetParams) setDefaults() {
+	if p.Output == nil {
+		p.Output = OutputExtend
+	}
+}
+
+// Tag is a tag of a host, an item, a trigger or a problem.
+type Tag struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// Tag filter operators.
+const (
+	TagOperatorContains  = "0"
+	TagOperatorEquals    = "1"
+	TagOperatorNotLike   = "2"
+	TagOperatorNotEqual  = "3"
+	TagOperatorExists    = "4"
+	TagOperatorNotExists = "5"
+)
+
+// TagFilter selects the objects by tag.
+type TagFilter struct {
+	Tag      string `json:"tag"`
+	Value    string `json:"value,omitempty"`
+	Operator string `json:"operator,omitempty"`
+}
@@ -0,0 +1,356 @@
+package zabbix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opsdata/common-base/pkg/runtime"
+	"github.com/opsdata/common-base/pkg/scheme"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// ZabbixInterface
+// - hold the methods of the Zabbix JSON-RPC API
+// - Call gives access to any method, the getters to the typed ones
+type ZabbixInterface interface {
+	RESTClient() rest.Interface
+	Call(ctx context.Context, method string, params interface{}, result interface{}) error
+	Login(ctx context.Context) error
+	Logout(ctx context.Context) error
+	Batch() *Batch
+	HostsGetter
+	ItemsGetter
+	HistoryGetter
+	TriggersGetter
+	ProblemsGetter
+}
+
+// Credentials authenticate the calls of a ZabbixClient, either through user.login with User and
+// Password, or with the API token Token.
+type Credentials struct {
+	User     string
+	Password string
+	Token    string
+}
+
+/*
+ * ZabbixClient:
+ * - be used to call the Zabbix JSON-RPC API configured by rest.Config.ZabbixApiUrl
+ * - implement the ZabbixInterface interface
+ * - with user credentials, it logs in on the first call and again when the session expires
+ */
+
+type ZabbixClient struct {
+	restClient  rest.Interface
+	credentials Credentials
+
+	// err is returned by every call, it is set when the Zabbix API is not configured
+	err error
+
+	// batchSize is the default maximum number of calls of the requests sent by a Batch
+	batchSize int
+
+	nextID uint64
+
+	mu      sync.Mutex
+	session string
+
+	// legacyLogin is set once the server rejected the username parameter of user.login
+	legacyLogin bool
+}
+
+var _ ZabbixInterface = &ZabbixClient{}
+
+func (c *ZabbixClient) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+
+	return c.restClient
+}
+
+func (c *ZabbixClient) Hosts() HostInterface {
+	return newHosts(c)
+}
+
+func (c *ZabbixClient) Items() ItemInterface {
+	return newItems(c)
+}
+
+func (c *ZabbixClient) History() HistoryInterface {
+	return newHistory(c)
+}
+
+func (c *ZabbixClient) Triggers() TriggerInterface {
+	return newTriggers(c)
+}
+
+func (c *ZabbixClient) Problems() ProblemInterface {
+	return newProblems(c)
+}
+
+// Call
+// - call a method of the Zabbix API and decode its result into result, unless it is nil
+// - a failed call returns an *Error, which carries the JSON-RPC error code
+func (c *ZabbixClient) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	if !requiresAuth(method) {
+		return c.call(ctx, method, params, "", result)
+	}
+
+	auth, err := c.auth(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.call(ctx, method, params, auth, result)
+	if IsSessionExpired(err) && len(c.credentials.Token) == 0 {
+		c.invalidate(auth)
+
+		if auth, err = c.auth(ctx); err != nil {
+			return err
+		}
+
+		err = c.call(ctx, method, params, auth, result)
+	}
+
+	return err
+}
+
+// Login opens a new session with user.login. It is not needed with an API token.
+func (c *ZabbixClient) Login(ctx context.Context) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	if len(c.credentials.Token) > 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.login(ctx)
+}
+
+// Logout closes the current session, if any.
+func (c *ZabbixClient) Logout(ctx context.Context) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.mu.Lock()
+	session := c.session
+	c.session = ""
+	c.mu.Unlock()
+
+	if len(session) == 0 {
+		return nil
+	}
+
+	return c.call(ctx, "user.logout", []string{}, session, nil)
+}
+
+// login must be called with c.mu held.
+func (c *ZabbixClient) login(ctx context.Context) error {
+	if len(c.credentials.User) == 0 {
+		return fmt.Errorf("zabbix: neither an API token nor a user is configured")
+	}
+
+	userParam := "username"
+	if c.legacyLogin {
+		userParam = "user"
+	}
+
+	var session string
+
+	err := c.call(ctx, "user.login", map[string]string{
+		userParam:  c.credentials.User,
+		"password": c.credentials.Password,
+	}, "", &session)
+
+	// Zabbix versions older than 5.4 name the parameter user
+	if e, ok := err.(*Error); ok && !c.legacyLogin && e.Code == ErrCodeInvalidParams &&
+		strings.Contains(e.Data, "username") {
+		c.legacyLogin = true
+
+		return c.login(ctx)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.session = session
+
+	return nil
+}
+
+// auth returns the value of the auth member of the requests, logging in if needed.
+func (c *ZabbixClient) auth(ctx context.Context) (string, error) {
+	if len(c.credentials.Token) > 0 {
+		return c.credentials.Token, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.session) == 0 {
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	return c.session, nil
+}
+
+// invalidate forgets the session, unless another call already replaced it.
+func (c *ZabbixClient) invalidate(session string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == session {
+		c.session = ""
+	}
+}
+
+func (c *ZabbixClient) call(ctx context.Context, method string, params interface{}, auth string, result interface{}) error {
+	req := c.newRequest(method, params, auth)
+
+	body, err := c.post(ctx, method, req)
+	if err != nil {
+		return err
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("zabbix: invalid JSON-RPC response to %s: %v", method, err)
+	}
+
+	if resp.ID != req.ID {
+		return fmt.Errorf("zabbix: JSON-RPC response id %d does not match request id %d", resp.ID, req.ID)
+	}
+
+	return resp.into(method, result)
+}
+
+// post sends a JSON-RPC request or batch and returns the body of the response.
+func (c *ZabbixClient) post(ctx context.Context, method string, body interface{}) ([]byte, error) {
+	// Body only sets the Content-Type of struct bodies
+	result := c.restClient.Post().
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(ctx)
+
+	data, err := result.Raw()
+	if err == nil {
+		return data, nil
+	}
+
+	// The RESTClient returns the body of a failed response as the error, which may be empty
+	if code := result.StatusCode(); code != 0 && code != http.StatusOK {
+		msg := strings.TrimSpace(err.Error())
+		if len(msg) == 0 {
+			msg = http.StatusText(code)
+		}
+
+		return nil, fmt.Errorf("zabbix: %s failed: HTTP %d: %s", method, code, msg)
+	}
+
+	return nil, err
+}
+
+func (c *ZabbixClient) newRequest(method string, params interface{}, auth string) request {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	return request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		Auth:    auth,
+		ID:      atomic.AddUint64(&c.nextID, 1),
+	}
+}
+
+/*
+ * Methods to initiate a new ZabbixClient:
+ * - New
+ * - NewForConfig, NewForConfigOrDie
+ */
+
+// New creates a new ZabbixClient for the given RESTClient, which must send its requests to the
+// JSON-RPC endpoint, such as http://zabbix.example.com/api_jsonrpc.php.
+func New(c rest.Interface, credentials Credentials) *ZabbixClient {
+	return &ZabbixClient{restClient: c, credentials: credentials, batchSize: DefaultBatchSize}
+}
+
+// NewForConfig
+// - create a new ZabbixClient from the Zabbix settings of the config
+// - when ZabbixApiUrl is empty, the client is returned anyway and every call fails with ErrNotConfigured
+func NewForConfig(c *rest.Config) (*ZabbixClient, error) {
+	if len(c.ZabbixApiUrl) == 0 {
+		return &ZabbixClient{err: ErrNotConfigured}, nil
+	}
+
+	config := *c
+	setConfigDefaults(&config)
+
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	zc := New(client, Credentials{
+		User:     c.ZabbixApiUser,
+		Password: c.ZabbixApiPass,
+		Token:    c.ZabbixApiToken,
+	})
+
+	if c.ZabbixBatchSize > 0 {
+		zc.batchSize = c.ZabbixBatchSize
+	}
+
+	return zc, nil
+}
+
+func NewForConfigOrDie(c *rest.Config) *ZabbixClient {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+
+	return client
+}
+
+// setConfigDefaults points the config to the Zabbix API. The credentials and TLS settings of the
+// ELMT server are dropped, Zabbix authenticates the calls in their body.
+func setConfigDefaults(config *rest.Config) {
+	config.Host = config.ZabbixApiUrl
+	config.APIPath = ""
+	config.GroupVersion = &scheme.GroupVersion{Group: "zabbix"}
+	config.Negotiator = runtime.NewSimpleClientNegotiator()
+
+	config.Username = ""
+	config.Password = ""
+	config.SecretID = ""
+	config.SecretKey = ""
+	config.BearerToken = ""
+	config.BearerTokenFile = ""
+	config.TLSClientConfig = rest.TLSClientConfig{}
+	config.Dial = nil
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultUserAgent()
+	}
+}
@@ -0,0 +1,665 @@
+package zabbix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opsdata/elmt-sdk/rest"
+)
+
+// sessionExpired is the error data returned by Zabbix for a call using an unknown session.
+const sessionExpired = "Session terminated, re-login, please."
+
+// serverRequest is a JSON-RPC request as received by the stand-in server.
+type serverRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Auth    string          `json:"auth"`
+	ID      uint64          `json:"id"`
+}
+
+// serverResponse is a JSON-RPC response as sent by the stand-in server.
+type serverResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *Error      `json:"error,omitempty"`
+	ID      uint64      `json:"id"`
+}
+
+/*
+ * jsonRPCServer:
+ * - stand in for the JSON-RPC endpoint of the Zabbix API
+ * - user.login opens a session, the other calls need a session or the API token
+ * - methods answers the other calls, an unknown method fails with ErrCodeMethodNotFound
+ */
+
+type jsonRPCServer struct {
+	*httptest.Server
+
+	user, password, token string
+
+	// legacy rejects the username parameter of user.login, as Zabbix versions older than 5.4
+	legacy bool
+
+	// reverse sends the responses of a batch in the reverse order of its requests
+	reverse bool
+
+	// raw, if set, replaces the JSON-RPC handling
+	raw http.HandlerFunc
+
+	methods map[string]func(params json.RawMessage) (interface{}, *Error)
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	logins   int
+	requests []serverRequest
+	batches  []int
+	paths    []string
+}
+
+func newJSONRPCServer(t *testing.T, configure func(s *jsonRPCServer)) *jsonRPCServer {
+	t.Helper()
+
+	s := &jsonRPCServer{
+		user:     "Admin",
+		password: "zabbix",
+		sessions: map[string]bool{},
+		methods:  map[string]func(params json.RawMessage) (interface{}, *Error){},
+	}
+	if configure != nil {
+		configure(s)
+	}
+
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jsonRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.raw != nil {
+		s.raw(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		var reqs []serverRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			_ = json.NewEncoder(w).Encode(serverResponse{JSONRPC: "2.0", Error: &Error{Code: ErrCodeParse, Message: "Parse error."}})
+			return
+		}
+
+		s.mu.Lock()
+		s.batches = append(s.batches, len(reqs))
+		s.mu.Unlock()
+
+		resps := make([]serverResponse, len(reqs))
+		for i, req := range reqs {
+			resps[i] = s.handle(req)
+		}
+
+		if s.reverse {
+			for i, j := 0, len(resps)-1; i < j; i, j = i+1, j-1 {
+				resps[i], resps[j] = resps[j], resps[i]
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(resps)
+
+		return
+	}
+
+	var req serverRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		_ = json.NewEncoder(w).Encode(serverResponse{JSONRPC: "2.0", Error: &Error{Code: ErrCodeParse, Message: "Parse error."}})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(s.handle(req))
+}
+
+func (s *jsonRPCServer) handle(req serverRequest) serverResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req)
+
+	resp := serverResponse{JSONRPC: "2.0", ID: req.ID}
+
+	switch {
+	case req.Method == "user.login":
+		resp.Result, resp.Error = s.login(req)
+	case !requiresAuth(req.Method):
+		resp.Result = "6.0.0"
+	case len(s.token) > 0 && req.Auth == s.token, s.sessions[req.Auth]:
+		if req.Method == "user.logout" {
+			delete(s.sessions, req.Auth)
+			resp.Result = true
+
+			break
+		}
+
+		method, ok := s.methods[req.Method]
+		if !ok {
+			resp.Error = &Error{Code: ErrCodeMethodNotFound, Message: "Method not found.", Data: fmt.Sprintf("Incorrect API %q.", req.Method)}
+			break
+		}
+
+		resp.Result, resp.Error = method(req.Params)
+	default:
+		resp.Error = &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: sessionExpired}
+	}
+
+	return resp
+}
+
+// login must be called with s.mu held.
+func (s *jsonRPCServer) login(req serverRequest) (interface{}, *Error) {
+	var params map[string]string
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: err.Error()}
+	}
+
+	user, ok := params["username"]
+	if s.legacy {
+		if ok {
+			return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: `Invalid parameter "/": unexpected parameter "username".`}
+		}
+
+		user = params["user"]
+	}
+
+	if user != s.user || params["password"] != s.password {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: "Incorrect user name or password or account is temporarily blocked."}
+	}
+
+	s.logins++
+	session := fmt.Sprintf("session-%d", s.logins)
+	s.sessions[session] = true
+
+	return session, nil
+}
+
+// expire terminates every session.
+func (s *jsonRPCServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = map[string]bool{}
+}
+
+// calls returns the methods received, in order.
+func (s *jsonRPCServer) calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	methods := make([]string, len(s.requests))
+	for i, req := range s.requests {
+		methods[i] = req.Method
+	}
+
+	return methods
+}
+
+func (s *jsonRPCServer) loginCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+func (s *jsonRPCServer) lastRequest() serverRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[len(s.requests)-1]
+}
+
+// client returns a ZabbixClient for the server, configured as by a kubeconfig-like ELMT config.
+func (s *jsonRPCServer) client(t *testing.T, user, password, token string) *ZabbixClient {
+	t.Helper()
+
+	client, err := NewForConfig(&rest.Config{
+		Host:           "http://elmt.example.com",
+		BearerToken:    "elmt-token",
+		ZabbixApiUrl:   s.URL + "/api_jsonrpc.php",
+		ZabbixApiUser:  user,
+		ZabbixApiPass:  password,
+		ZabbixApiToken: token,
+	})
+	if err != nil {
+		t.Fatalf("NewForConfig() failed: %v", err)
+	}
+
+	return client
+}
+
+func hostsMethod(hosts ...Host) func(json.RawMessage) (interface{}, *Error) {
+	return func(json.RawMessage) (interface{}, *Error) {
+		return hosts, nil
+	}
+}
+
+func TestNewForConfigNotConfigured(t *testing.T) {
+	client, err := NewForConfig(&rest.Config{Host: "http://elmt.example.com"})
+	if err != nil {
+		t.Fatalf("NewForConfig() failed: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := client.Call(ctx, "apiinfo.version", nil, nil); err != ErrNotConfigured {
+		t.Errorf("Call() = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if err := client.Login(ctx); err != ErrNotConfigured {
+		t.Errorf("Login() = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if _, err := client.Hosts().Get(ctx, HostGetParams{}); err != ErrNotConfigured {
+		t.Errorf("Hosts().Get() = %v, want %v", err, ErrNotConfigured)
+	}
+
+	if err := client.Batch().Send(ctx); err != nil {
+		t.Errorf("empty Batch().Send() = %v, want nil", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		legacy    bool
+		user      string
+		password  string
+		wantCalls []string
+		wantParam string
+		wantErr   string
+	}{
+		{
+			name:      "username",
+			user:      "Admin",
+			password:  "zabbix",
+			wantCalls: []string{"user.login", "host.get", "host.get"},
+			wantParam: "username",
+		},
+		{
+			name:      "fallback to user",
+			legacy:    true,
+			user:      "Admin",
+			password:  "zabbix",
+			wantCalls: []string{"user.login", "user.login", "host.get", "host.get"},
+			wantParam: "user",
+		},
+		{
+			name:      "wrong password",
+			user:      "Admin",
+			password:  "wrong",
+			wantCalls: []string{"user.login"},
+			wantErr:   "zabbix: user.login failed: Invalid params: Incorrect user name or password",
+		},
+		{
+			name:    "no user",
+			wantErr: "neither an API token nor a user is configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.legacy = tt.legacy
+				s.methods["host.get"] = hostsMethod(Host{HostID: "10084", Host: "Zabbix server"})
+			})
+			client := server.client(t, tt.user, tt.password, "")
+
+			ctx := context.Background()
+
+			for i := 0; i < 2; i++ {
+				hosts, err := client.Hosts().Get(ctx, HostGetParams{})
+				if len(tt.wantErr) > 0 {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("Hosts().Get() = %v, want an error containing %q", err, tt.wantErr)
+					}
+
+					break
+				}
+
+				if err != nil {
+					t.Fatalf("Hosts().Get() failed: %v", err)
+				}
+
+				if len(hosts) != 1 || hosts[0].HostID != "10084" {
+					t.Errorf("Hosts().Get() = %+v", hosts)
+				}
+			}
+
+			if calls := server.calls(); len(calls) != len(tt.wantCalls) || len(calls) > 0 && !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			if len(tt.wantParam) == 0 {
+				return
+			}
+
+			server.mu.Lock()
+			login := server.requests[len(tt.wantCalls)-3]
+			call := server.requests[len(tt.wantCalls)-1]
+			path := server.paths[0]
+			server.mu.Unlock()
+
+			var params map[string]string
+			if err := json.Unmarshal(login.Params, &params); err != nil {
+				t.Fatal(err)
+			}
+
+			if params[tt.wantParam] != tt.user || len(params) != 2 {
+				t.Errorf("user.login params = %v, want %s and password", params, tt.wantParam)
+			}
+
+			if len(login.Auth) > 0 {
+				t.Errorf("user.login sent auth %q", login.Auth)
+			}
+
+			if call.Auth != "session-1" {
+				t.Errorf("host.get auth = %q, want session-1", call.Auth)
+			}
+
+			if path != "/api_jsonrpc.php" {
+				t.Errorf("request path = %s, want /api_jsonrpc.php", path)
+			}
+		})
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.methods["host.get"] = hostsMethod(Host{HostID: "10084"})
+	})
+	client := server.client(t, "Admin", "zabbix", "")
+
+	ctx := context.Background()
+
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	server.expire()
+
+	if _, err := client.Hosts().Get(ctx, HostGetParams{}); err != nil {
+		t.Fatalf("Hosts().Get() after expiry failed: %v", err)
+	}
+
+	want := []string{"user.login", "host.get", "user.login", "host.get"}
+	if calls := server.calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	if auth := server.lastRequest().Auth; auth != "session-2" {
+		t.Errorf("retried call auth = %q, want session-2", auth)
+	}
+
+	// A session expiring again right after the new login is reported, not retried forever
+	server.mu.Lock()
+	server.methods["host.get"] = func(json.RawMessage) (interface{}, *Error) {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: sessionExpired}
+	}
+	server.mu.Unlock()
+
+	_, err := client.Hosts().Get(ctx, HostGetParams{})
+	if !IsSessionExpired(err) {
+		t.Errorf("Hosts().Get() = %v, want a session expired error", err)
+	}
+
+	if logins := server.loginCount(); logins != 3 {
+		t.Errorf("%d logins, want 3", logins)
+	}
+
+	if err := client.Logout(ctx); err != nil {
+		t.Errorf("Logout() failed: %v", err)
+	}
+
+	if last := server.lastRequest(); last.Method != "user.logout" || last.Auth != "session-3" {
+		t.Errorf("last call = %s with auth %q, want user.logout with session-3", last.Method, last.Auth)
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.token = "api-token"
+		s.methods["host.get"] = hostsMethod(Host{HostID: "10084"})
+	})
+
+	ctx := context.Background()
+
+	client := server.client(t, "Admin", "zabbix", "api-token")
+
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	if _, err := client.Hosts().Get(ctx, HostGetParams{}); err != nil {
+		t.Fatalf("Hosts().Get() failed: %v", err)
+	}
+
+	if calls := server.calls(); !reflect.DeepEqual(calls, []string{"host.get"}) {
+		t.Errorf("calls = %v, want host.get only", calls)
+	}
+
+	if auth := server.lastRequest().Auth; auth != "api-token" {
+		t.Errorf("auth = %q, want api-token", auth)
+	}
+
+	// A revoked token is not retried with user.login
+	client = server.client(t, "Admin", "zabbix", "revoked-token")
+
+	if _, err := client.Hosts().Get(ctx, HostGetParams{}); !IsSessionExpired(err) {
+		t.Errorf("Hosts().Get() with a revoked token = %v, want a session expired error", err)
+	}
+
+	if logins := server.loginCount(); logins != 0 {
+		t.Errorf("%d logins with an API token, want 0", logins)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		handler      http.HandlerFunc
+		wantCode     int
+		wantErr      string
+		wantExpired  bool
+		wantRPCError bool
+	}{
+		{
+			name:         "application error",
+			method:       "item.get",
+			wantCode:     ErrCodeInvalidParams,
+			wantErr:      `zabbix: item.get failed: Invalid params: Invalid parameter "/": unexpected parameter "bogus". (code -32602)`,
+			wantRPCError: true,
+		},
+		{
+			name:         "method not found",
+			method:       "bogus.get",
+			wantCode:     ErrCodeMethodNotFound,
+			wantErr:      `zabbix: bogus.get failed: Method not found: Incorrect API "bogus.get". (code -32601)`,
+			wantRPCError: true,
+		},
+		{
+			name:   "id mismatch",
+			method: "apiinfo.version",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":"6.0.0","id":4242}`))
+			},
+			wantErr: "zabbix: JSON-RPC response id 4242 does not match request id 1",
+		},
+		{
+			name:   "invalid response",
+			method: "apiinfo.version",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`<html>`))
+			},
+			wantErr: "zabbix: invalid JSON-RPC response to apiinfo.version",
+		},
+		{
+			name:   "http error",
+			method: "apiinfo.version",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantErr: "zabbix: apiinfo.version failed: HTTP 502: Bad Gateway",
+		},
+		{
+			name:   "undecodable result",
+			method: "apiinfo.version",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":{"version":6},"id":1}`))
+			},
+			wantErr: "zabbix: unable to decode the result of apiinfo.version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+				s.token = "api-token"
+				s.methods["item.get"] = func(json.RawMessage) (interface{}, *Error) {
+					return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params.", Data: `Invalid parameter "/": unexpected parameter "bogus".`}
+				}
+				s.raw = tt.handler
+			})
+
+			client := server.client(t, "", "", "api-token")
+
+			var result string
+
+			err := client.Call(context.Background(), tt.method, nil, &result)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Call(%s) = %v, want an error containing %q", tt.method, err, tt.wantErr)
+			}
+
+			e, ok := err.(*Error)
+			if ok != tt.wantRPCError {
+				t.Fatalf("Call(%s) returned %T, want an *Error: %v", tt.method, err, tt.wantRPCError)
+			}
+
+			if !ok {
+				return
+			}
+
+			if e.Method != tt.method || !IsErrorCode(err, tt.wantCode) || IsErrorCode(err, ErrCodeInternal) {
+				t.Errorf("error = %+v, want method %s and code %d", e, tt.method, tt.wantCode)
+			}
+
+			if IsSessionExpired(err) != tt.wantExpired {
+				t.Errorf("IsSessionExpired(%v) = %v", err, !tt.wantExpired)
+			}
+		})
+	}
+}
+
+func TestIsSessionExpired(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &Error{Code: ErrCodeInvalidParams, Data: "Session terminated, re-login, please."}, want: true},
+		{err: &Error{Code: ErrCodeInvalidParams, Data: "Not authorised."}, want: true},
+		{err: &Error{Code: ErrCodeApplication, Data: "Not authorized."}, want: true},
+		{err: fmt.Errorf("wrapped: %w", &Error{Data: "Session terminated, re-login, please."}), want: true},
+		{err: &Error{Code: ErrCodeInvalidParams, Data: "Incorrect user name or password or account is temporarily blocked."}},
+		{err: fmt.Errorf("Session terminated, re-login, please.")},
+		{err: nil},
+	}
+
+	for _, tt := range tests {
+		if got := IsSessionExpired(tt.err); got != tt.want {
+			t.Errorf("IsSessionExpired(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestConcurrentSessionExpiry checks that the calls failing on the same expired session log in
+// only once: a call invalidating the expired session after another one logged in again must not
+// drop the new session. Run with -race.
+func TestConcurrentSessionExpiry(t *testing.T) {
+	server := newJSONRPCServer(t, func(s *jsonRPCServer) {
+		s.methods["host.get"] = hostsMethod(Host{HostID: "10084"})
+	})
+	client := server.client(t, "Admin", "zabbix", "")
+
+	ctx := context.Background()
+
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	server.expire()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 20)
+	)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := client.Hosts().Get(ctx, HostGetParams{})
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Hosts().Get() failed: %v", err)
+		}
+	}
+
+	if logins := server.loginCount(); logins != 2 {
+		t.Errorf("%d logins, want 2: the new session was invalidated by a call using the expired one", logins)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	client := New(nil, Credentials{User: "Admin"})
+	client.session = "session-2"
+
+	client.invalidate("session-1")
+
+	if client.session != "session-2" {
+		t.Errorf("invalidate(session-1) dropped session-2")
+	}
+
+	client.invalidate("session-2")
+
+	if client.session != "" {
+		t.Errorf("invalidate(session-2) kept %q", client.session)
+	}
+}